@@ -1,31 +1,67 @@
 package main
 
 import (
+	"automobile36/internal/clock"
+	"automobile36/internal/config"
 	"automobile36/internal/db"
+	"automobile36/internal/digest"
 	"automobile36/internal/modules/sessions"
-	"database/sql"
+	"automobile36/internal/reminders"
+	"automobile36/internal/utils"
+	"automobile36/internal/waitlist"
+	"flag"
 	"log"
 	"net/http"
+	"os"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/PaulSonOfLars/gotgbot/v2"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext"
 )
 
-var database *sql.DB
-
 func main() {
-	token := "TELEGRAM_TOKEN"
+	configPath := flag.String("config", os.Getenv("BOT_CONFIG"), "path to the YAML config file")
+	flag.Parse()
 
-	db.Init()
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		panic("failed to load config: " + err.Error())
+	}
+
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(cfg, flag.Args()[1:]); err != nil {
+			log.Fatalln("migration failed:", err.Error())
+		}
+		return
+	}
+	if err := cfg.ValidateBot(); err != nil {
+		panic("invalid config: " + err.Error())
+	}
+
+	storage, err := openStorage(cfg, true)
+	if err != nil {
+		panic("failed to open storage: " + err.Error())
+	}
 	defer func() {
-		err := database.Close()
+		err := storage.Close()
 		if err != nil {
 			panic("Error: " + err.Error())
 		}
 	}()
 
-	b, err := gotgbot.NewBot(token, &gotgbot.BotOpts{
+	go func() {
+		for range time.Tick(10 * time.Minute) {
+			if err := storage.DeleteExpiredSessions(); err != nil {
+				log.Println("failed to delete expired sessions:", err.Error())
+			}
+		}
+	}()
+
+	utils.Init(cfg, storage)
+	sessions.Init(cfg, storage)
+
+	b, err := gotgbot.NewBot(cfg.Token, &gotgbot.BotOpts{
 		Client: http.Client{},
 		DefaultRequestOpts: &gotgbot.RequestOpts{
 			Timeout: gotgbot.DefaultTimeout,
@@ -50,6 +86,12 @@ func main() {
 	sessions.LoadRegisterHandlers(dp)
 	sessions.LoadMenuHandlers(dp)
 	sessions.LoadRecordsHandlers(dp)
+	sessions.LoadStaffHandlers(dp)
+
+	go reminders.New(b, storage, clock.System, cfg.Reminders).Run()
+	go digest.New(b, storage, clock.System, cfg).Run()
+	go waitlist.New(b, storage, clock.System, cfg).Run()
+	go sessions.ResumeBroadcasts(b)
 
 	err = updater.StartPolling(b, &ext.PollingOpts{
 		DropPendingUpdates: true,
@@ -67,3 +109,18 @@ func main() {
 
 	updater.Idle()
 }
+
+// openStorage подключается к базе, выбранной в конфиге. Если migrate установлен,
+// применяются миграции и заполняется каталог.
+func openStorage(cfg *config.Config, migrate bool) (*db.Store, error) {
+	switch {
+	case cfg.DB.Driver == "postgres" && migrate:
+		return db.NewPostgres(cfg.DB.DSN, cfg.Shop.Location)
+	case cfg.DB.Driver == "postgres":
+		return db.OpenPostgres(cfg.DB.DSN, cfg.Shop.Location)
+	case migrate:
+		return db.NewSQLite(cfg.DB.Path, cfg.Shop.Location)
+	default:
+		return db.OpenSQLite(cfg.DB.Path, cfg.Shop.Location)
+	}
+}
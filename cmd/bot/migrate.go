@@ -0,0 +1,55 @@
+package main
+
+import (
+	"automobile36/internal/config"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// runMigrate выполняет подкоманду migrate:
+//
+//	migrate [up]     применить все новые миграции
+//	migrate down [N] откатить N последних миграций (по умолчанию одну)
+//	migrate status   показать текущую версию схемы
+func runMigrate(cfg *config.Config, args []string) error {
+	storage, err := openStorage(cfg, false)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		if err := storage.Migrate(); err != nil {
+			return err
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		if err := storage.Rollback(steps); err != nil {
+			return err
+		}
+	case "status":
+	default:
+		return errors.New("usage: migrate [up | down [N] | status]")
+	}
+
+	version, err := storage.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	fmt.Println("schema version:", version)
+
+	return nil
+}
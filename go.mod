@@ -3,8 +3,13 @@ module automobile36
 go 1.20
 
 require (
-	github.com/PaulSonOfLars/gotgbot/v2 v2.0.0-rc.16 // indirect
-	github.com/mattn/go-sqlite3 v1.14.16 // indirect
-	github.com/patrickmn/go-cache v2.1.0+incompatible // indirect
-	gopkg.in/telebot.v3 v3.1.3 // indirect
+	github.com/PaulSonOfLars/gotgbot/v2 v2.0.0-rc.16
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.16
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/kr/pretty v0.3.0 // indirect
+	gopkg.in/check.v1 v1.0.0-20190902080502-41f04d3bba15 // indirect
 )
@@ -0,0 +1,138 @@
+// Package broadcast рассылает сообщения клиентам, не превышая ограничений Telegram.
+package broadcast
+
+import (
+	"automobile36/internal/config"
+	"automobile36/internal/db"
+	"automobile36/internal/sender"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+)
+
+const (
+	// floodWait - пауза после ответа 429, если Telegram не сообщил, сколько ждать.
+	floodWait = 5 * time.Second
+	// maxRetries - сколько раз повторять отправку одному клиенту после ответа 429,
+	// прежде чем отметить её ошибкой и перейти к следующему.
+	maxRetries = 3
+)
+
+// Broadcaster рассылает сообщения через один sender. Бот должен держать один
+// Broadcaster, чтобы рассылки шли по очереди.
+type Broadcaster struct {
+	sender   sender.PhotoSender
+	repo     db.BroadcastRepository
+	interval time.Duration
+	// sleep - пауза между отправками; тесты подменяют её, чтобы не ждать.
+	sleep func(time.Duration)
+
+	// sending - рассылки отправляются по очереди, чтобы вместе не превышать rate.
+	sending sync.Mutex
+}
+
+func New(sender sender.PhotoSender, repo db.BroadcastRepository, cfg config.Broadcast) *Broadcaster {
+	return &Broadcaster{
+		sender:   sender,
+		repo:     repo,
+		interval: time.Second / time.Duration(cfg.Rate),
+		sleep:    time.Sleep,
+	}
+}
+
+// SeasonStart возвращает начало сезона смены шин, в который попадает t:
+// весенний сезон начинается 1 марта, осенний - 1 сентября.
+func SeasonStart(t time.Time) time.Time {
+	year, month := t.Year(), time.March
+	switch {
+	case t.Month() >= time.September:
+		month = time.September
+	case t.Month() < time.March:
+		year, month = year-1, time.September
+	}
+
+	return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+}
+
+// Send отправляет рассылку b одному клиенту.
+func (s *Broadcaster) Send(chatId int64, b db.Broadcast) error {
+	var err error
+	if b.PhotoId != "" {
+		_, err = s.sender.SendPhoto(chatId, b.PhotoId, &gotgbot.SendPhotoOpts{Caption: b.Text})
+	} else {
+		_, err = s.sender.SendMessage(chatId, b.Text, nil)
+	}
+
+	return err
+}
+
+// Run отправляет рассылку всем, кому она ещё не отправлена, не чаще rate сообщений
+// в секунду, и отмечает её завершённой. Прерванную рассылку можно продолжить повторным Run.
+func (s *Broadcaster) Run(b db.Broadcast) (db.BroadcastStats, error) {
+	s.sending.Lock()
+	defer s.sending.Unlock()
+
+	recipients, err := s.repo.PendingRecipients(b.Id)
+	if err != nil {
+		return db.BroadcastStats{}, err
+	}
+
+	for _, userId := range recipients {
+		status := db.DeliveryDelivered
+		for attempt := 0; ; attempt++ {
+			s.sleep(s.interval)
+			err := s.Send(userId, b)
+			if err == nil {
+				break
+			}
+			if wait, ok := retryAfter(err); ok && attempt < maxRetries {
+				s.sleep(wait)
+				continue
+			}
+			status = deliveryStatus(err)
+			log.Printf("failed to send broadcast %d to %d: %s", b.Id, userId, err)
+			break
+		}
+
+		if err := s.repo.SetDeliveryStatus(b.Id, userId, status); err != nil {
+			return db.BroadcastStats{}, err
+		}
+	}
+
+	if err := s.repo.FinishBroadcast(b.Id); err != nil {
+		return db.BroadcastStats{}, err
+	}
+
+	return s.repo.BroadcastStats(b.Id)
+}
+
+// retryAfter сообщает, что Telegram попросил подождать (ответ 429), и сколько.
+func retryAfter(err error) (time.Duration, bool) {
+	var tgErr *gotgbot.TelegramError
+	if !errors.As(err, &tgErr) || tgErr.Code != 429 {
+		return 0, false
+	}
+
+	var seconds int
+	_, after, _ := strings.Cut(tgErr.Description, "retry after ")
+	if _, err := fmt.Sscanf(after, "%d", &seconds); err != nil || seconds <= 0 {
+		return floodWait, true
+	}
+
+	return time.Duration(seconds) * time.Second, true
+}
+
+// deliveryStatus отличает клиентов, заблокировавших бота или удаливших аккаунт, от прочих ошибок.
+func deliveryStatus(err error) string {
+	var tgErr *gotgbot.TelegramError
+	if errors.As(err, &tgErr) && (tgErr.Code == 403 || strings.Contains(tgErr.Description, "chat not found")) {
+		return db.DeliveryBlocked
+	}
+
+	return db.DeliveryFailed
+}
@@ -0,0 +1,130 @@
+package broadcast
+
+import (
+	"automobile36/internal/config"
+	"automobile36/internal/db"
+	"testing"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+)
+
+// fakeSender отвечает каждому клиенту ошибками из errs по очереди, а когда они
+// кончаются - успехом.
+type fakeSender struct {
+	errs  map[int64][]error
+	calls map[int64]int
+}
+
+func (f *fakeSender) SendMessage(chatId int64, text string, opts *gotgbot.SendMessageOpts) (*gotgbot.Message, error) {
+	f.calls[chatId]++
+	if errs := f.errs[chatId]; len(errs) > 0 {
+		f.errs[chatId] = errs[1:]
+		return nil, errs[0]
+	}
+
+	return &gotgbot.Message{}, nil
+}
+
+func (f *fakeSender) SendPhoto(chatId int64, photo gotgbot.InputFile, opts *gotgbot.SendPhotoOpts) (*gotgbot.Message, error) {
+	return f.SendMessage(chatId, "", nil)
+}
+
+// fakeRepo отдаёт получателей по порядку и запоминает их статусы доставки.
+type fakeRepo struct {
+	db.BroadcastRepository
+	recipients []int64
+	statuses   map[int64]string
+	finished   bool
+}
+
+func (r *fakeRepo) PendingRecipients(id int64) ([]int64, error) {
+	return r.recipients, nil
+}
+
+func (r *fakeRepo) SetDeliveryStatus(id, userId int64, status string) error {
+	r.statuses[userId] = status
+	return nil
+}
+
+func (r *fakeRepo) FinishBroadcast(id int64) error {
+	r.finished = true
+	return nil
+}
+
+func (r *fakeRepo) BroadcastStats(id int64) (db.BroadcastStats, error) {
+	return db.BroadcastStats{}, nil
+}
+
+func tooManyRequests(description string) error {
+	return &gotgbot.TelegramError{Method: "sendMessage", Code: 429, Description: description}
+}
+
+func TestRun(t *testing.T) {
+	const (
+		flooded = iota + 1
+		blocked
+		failed
+		exhausted
+		delivered
+	)
+	floodErr := tooManyRequests("Too Many Requests: retry after 7")
+	sender := &fakeSender{
+		errs: map[int64][]error{
+			flooded: {floodErr},
+			blocked: {&gotgbot.TelegramError{Method: "sendMessage", Code: 403, Description: "Forbidden: bot was blocked by the user"}},
+			failed:  {&gotgbot.TelegramError{Method: "sendMessage", Code: 400, Description: "Bad Request: message is too long"}},
+			// Без retry_after бот ждёт floodWait.
+			exhausted: {floodErr, floodErr, tooManyRequests("Too Many Requests"), floodErr, floodErr},
+		},
+		calls: make(map[int64]int),
+	}
+	repo := &fakeRepo{
+		recipients: []int64{flooded, blocked, failed, exhausted, delivered},
+		statuses:   make(map[int64]string),
+	}
+
+	s := New(sender, repo, config.Broadcast{Rate: 10})
+	var waits []time.Duration
+	s.sleep = func(d time.Duration) {
+		if d != s.interval {
+			waits = append(waits, d)
+		}
+	}
+	if _, err := s.Run(db.Broadcast{Id: 1, Text: "Сезон шиномонтажа"}); err != nil {
+		t.Fatalf("Run(): %s", err)
+	}
+
+	tests := []struct {
+		userId int64
+		status string
+		calls  int
+	}{
+		{flooded, db.DeliveryDelivered, 2},
+		{blocked, db.DeliveryBlocked, 1},
+		{failed, db.DeliveryFailed, 1},
+		{exhausted, db.DeliveryFailed, maxRetries + 1},
+		{delivered, db.DeliveryDelivered, 1},
+	}
+	for _, tt := range tests {
+		if got := repo.statuses[tt.userId]; got != tt.status {
+			t.Errorf("status of %d = %q, want %q", tt.userId, got, tt.status)
+		}
+		if got := sender.calls[tt.userId]; got != tt.calls {
+			t.Errorf("sends to %d = %d, want %d", tt.userId, got, tt.calls)
+		}
+	}
+
+	want := []time.Duration{7 * time.Second, 7 * time.Second, 7 * time.Second, floodWait}
+	if len(waits) != len(want) {
+		t.Fatalf("waits after 429 = %v, want %v", waits, want)
+	}
+	for i := range want {
+		if waits[i] != want[i] {
+			t.Fatalf("waits after 429 = %v, want %v", waits, want)
+		}
+	}
+	if !repo.finished {
+		t.Error("broadcast was not finished")
+	}
+}
@@ -0,0 +1,19 @@
+// Package clock отделяет фоновые задачи от системных часов, чтобы их можно
+// было проверять в любой момент времени.
+package clock
+
+import "time"
+
+// Clock - источник текущего времени.
+type Clock interface {
+	Now() time.Time
+}
+
+type system struct{}
+
+func (system) Now() time.Time {
+	return time.Now()
+}
+
+// System - обычные системные часы.
+var System Clock = system{}
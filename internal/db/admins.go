@@ -0,0 +1,101 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Роли сотрудников по убыванию прав: владелец назначает администраторов,
+// менеджер управляет записями и графиком, мастер работает с записями на месте.
+const (
+	RoleOwner    = "owner"
+	RoleManager  = "manager"
+	RoleMechanic = "mechanic"
+)
+
+var roleRank = map[string]int{
+	RoleMechanic: 1,
+	RoleManager:  2,
+	RoleOwner:    3,
+}
+
+// ValidRole сообщает, известна ли роль role.
+func ValidRole(role string) bool {
+	return roleRank[role] > 0
+}
+
+// RoleAllows сообщает, достаточно ли роли have для действия, требующего роль need.
+// Пустая роль (не сотрудник) не позволяет ничего.
+func RoleAllows(have, need string) bool {
+	return roleRank[have] > 0 && roleRank[have] >= roleRank[need]
+}
+
+type Admin struct {
+	UserId  int64
+	Role    string
+	AddedBy int64
+	AddedAt int64
+}
+
+// GetRole возвращает роль пользователя userId или пустую строку, если он не сотрудник.
+func (s *Store) GetRole(userId int64) (string, error) {
+	var role string
+	err := s.db.QueryRow(`SELECT role FROM admins WHERE user_id=?`, userId).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get role: %w", err)
+	}
+
+	return role, nil
+}
+
+func (s *Store) SetAdmin(userId int64, role string, addedBy int64) error {
+	q := `INSERT INTO admins (user_id, role, added_by, added_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET role=excluded.role, added_by=excluded.added_by, added_at=excluded.added_at`
+
+	if _, err := s.db.Exec(q, userId, role, addedBy, time.Now().Unix()); err != nil {
+		return fmt.Errorf("failed to save admin: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) RemoveAdmin(userId int64) (bool, error) {
+	res, err := s.db.Exec(`DELETE FROM admins WHERE user_id=?`, userId)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove admin: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to remove admin: %w", err)
+	}
+
+	return n > 0, nil
+}
+
+func (s *Store) Admins() ([]Admin, error) {
+	rows, err := s.db.Query(`SELECT user_id, role, added_by, added_at FROM admins ORDER BY added_at, user_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get admins: %w", err)
+	}
+	defer rows.Close()
+
+	var admins []Admin
+	for rows.Next() {
+		var a Admin
+		if err := rows.Scan(&a.UserId, &a.Role, &a.AddedBy, &a.AddedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		admins = append(admins, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get admins: %w", err)
+	}
+
+	return admins, nil
+}
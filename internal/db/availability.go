@@ -0,0 +1,123 @@
+package db
+
+import (
+	"automobile36/internal/schedule"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// querier - общее у *sql.DB и *sql.Tx, чтобы проверять занятость и внутри транзакции.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// Booking - интервал [Start, End), на который занят пост Bay. Bay = 0 - заняты все посты,
+// HeldBay - время придержано за листом ожидания на любом свободном посту.
+type Booking struct {
+	Start, End int64
+	Bay        int
+}
+
+// HeldBay - пост предложенного из листа ожидания времени: он не выбран, но один из постов занят.
+const HeldBay = -1
+
+func (s *Store) Bookings(from, to, exclude, now int64) ([]Booking, error) {
+	return bookings(s.db, from, to, exclude, now)
+}
+
+// bookings возвращает все действующие записи, кроме записи exclude, блокировки сотрудников
+// и не истёкшие к now предложения листа ожидания, пересекающиеся с интервалом [from, to).
+func bookings(qr querier, from, to int64, exclude int64, now int64) ([]Booking, error) {
+	q := `SELECT datetime, datetime + duration*60, bay FROM records WHERE datetime < ? AND datetime + duration*60 > ? AND id != ? AND ` + activeCondition + `
+		UNION ALL
+		SELECT start_at, end_at, bay FROM slot_blocks WHERE start_at < ? AND end_at > ?
+		UNION ALL
+		SELECT offer_datetime, offer_datetime + duration*60, ` + strconv.Itoa(HeldBay) + ` FROM waitlist
+		WHERE status = '` + WaitlistOffered + `' AND offer_expires_at > ? AND offer_datetime < ? AND offer_datetime + duration*60 > ?`
+
+	rows, err := qr.Query(q, to, from, exclude, to, from, now, to, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bookings: %w", err)
+	}
+	defer rows.Close()
+
+	var res []Booking
+	for rows.Next() {
+		var bk Booking
+		if err := rows.Scan(&bk.Start, &bk.End, &bk.Bay); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		res = append(res, bk)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get bookings: %w", err)
+	}
+
+	return res, nil
+}
+
+// FreeBay возвращает номер первого поста, свободного на всём интервале [start, end), или 0.
+// Придержанное время занимает первые свободные посты.
+func FreeBay(busy []Booking, start, end int64, bays int) int {
+	taken := make(map[int]bool)
+	held := 0
+	for _, bk := range busy {
+		if bk.Start < end && start < bk.End {
+			switch bk.Bay {
+			case 0:
+				return 0
+			case HeldBay:
+				held++
+			default:
+				taken[bk.Bay] = true
+			}
+		}
+	}
+
+	for bay := 1; bay <= bays; bay++ {
+		if taken[bay] {
+			continue
+		}
+		if held == 0 {
+			return bay
+		}
+		held--
+	}
+
+	return 0
+}
+
+// SlotStart возвращает момент начала слота slot в день date по часам date.Location().
+func SlotStart(date time.Time, slot schedule.Clock) time.Time {
+	d := time.Duration(slot)
+	return time.Date(date.Year(), date.Month(), date.Day(), int(d.Hours()), int(d.Minutes())%60, 0, 0, date.Location())
+}
+
+// GetAllTimes возвращает слоты дня date (полночь по часам мастерской) по графику sch,
+// с которых можно начать работу длительностью duration хотя бы на одном из bays постов.
+// Запись exclude (переносимая) пост не занимает. Слоты, начавшиеся к моменту now, не возвращаются.
+func GetAllTimes(records RecordRepository, sch *schedule.Schedule, bays int, date time.Time, duration time.Duration, exclude int64, now time.Time) ([]string, error) {
+	busy, err := records.Bookings(date.Unix(), date.AddDate(0, 0, 1).Unix(), exclude, now.Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	var times []string
+	for _, slot := range sch.Slots(date.Weekday()) {
+		start := SlotStart(date, slot).Unix()
+		if start <= now.Unix() {
+			continue
+		}
+		if !sch.Fits(date.Weekday(), slot, duration) {
+			continue
+		}
+		if FreeBay(busy, start, start+int64(duration.Seconds()), bays) == 0 {
+			continue
+		}
+		times = append(times, slot.String())
+	}
+
+	return times, nil
+}
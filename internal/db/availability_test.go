@@ -0,0 +1,144 @@
+package db_test
+
+import (
+	"automobile36/internal/db"
+	"automobile36/internal/db/dbtest"
+	"automobile36/internal/schedule"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testSchedule - понедельник с 09:00 до 21:00 по полтора часа, воскресенье выходной.
+func testSchedule() *schedule.Schedule {
+	return &schedule.Schedule{
+		SlotMinutes: 90,
+		Days: map[string]schedule.Day{
+			"monday": {Open: schedule.Clock(9 * time.Hour), Close: schedule.Clock(21 * time.Hour)},
+		},
+	}
+}
+
+func TestGetAllTimes(t *testing.T) {
+	loc := dbtest.Location
+	// Полночь понедельника по Москве - это ещё воскресенье по UTC.
+	monday := time.Date(2026, 10, 19, 0, 0, 0, 0, loc)
+	all := []string{"09:00", "10:30", "12:00", "13:30", "15:00", "16:30", "18:00", "19:30"}
+
+	tests := []struct {
+		name string
+		date time.Time
+		now  time.Time
+		want []string
+	}{
+		{"day before, 23:59", monday, time.Date(2026, 10, 18, 23, 59, 0, 0, loc), all},
+		{"local midnight", monday, monday, all},
+		{"before slot", monday, time.Date(2026, 10, 19, 10, 29, 0, 0, loc), all[1:]},
+		{"slot just started", monday, time.Date(2026, 10, 19, 10, 30, 0, 0, loc), all[2:]},
+		{"last slot started", monday, time.Date(2026, 10, 19, 19, 31, 0, 0, loc), nil},
+		{"day off", monday.AddDate(0, 0, -1), time.Date(2026, 10, 17, 12, 0, 0, 0, loc), nil},
+	}
+	dbtest.Each(t, func(t *testing.T, s *db.Store) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got, err := db.GetAllTimes(s, testSchedule(), 1, tt.date, time.Hour, 0, tt.now)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("GetAllTimes(%s, now %s) = %v, want %v", tt.date, tt.now, got, tt.want)
+				}
+			})
+		}
+	})
+}
+
+func TestGetAllTimesBooked(t *testing.T) {
+	loc := dbtest.Location
+	monday := time.Date(2026, 10, 19, 0, 0, 0, 0, loc)
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, loc)
+
+	dbtest.Each(t, func(t *testing.T, s *db.Store) {
+		// Запись в воскресенье вечером не должна задеть понедельник, хотя по UTC это одни сутки.
+		var records []db.Record
+		for _, at := range []time.Time{
+			time.Date(2026, 10, 19, 9, 0, 0, 0, loc),
+			time.Date(2026, 10, 18, 22, 0, 0, 0, loc),
+		} {
+			r, err := s.SaveRecord(1, 0, at.Unix(), 0, time.Hour, 1, now.Unix())
+			if err != nil {
+				t.Fatalf("SaveRecord(%s): %s", at, err)
+			}
+			records = append(records, r)
+		}
+
+		got, err := db.GetAllTimes(s, testSchedule(), 1, monday, time.Hour, 0, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"10:30", "12:00", "13:30", "15:00", "16:30", "18:00", "19:30"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GetAllTimes() = %v, want %v", got, want)
+		}
+
+		got, err = db.GetAllTimes(s, testSchedule(), 2, monday, time.Hour, 0, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 8 || got[0] != "09:00" {
+			t.Errorf("GetAllTimes() with two bays = %v, want all slots", got)
+		}
+
+		// Переносимая запись своё время не занимает: его можно выбрать, и MoveRecord согласится.
+		got, err = db.GetAllTimes(s, testSchedule(), 1, monday, time.Hour, records[0].Id, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 8 || got[0] != "09:00" {
+			t.Errorf("GetAllTimes() excluding the moved record = %v, want all slots", got)
+		}
+		if _, err := s.MoveRecord(records[0].Id, 1, records[0].Datetime+30*60, 1, now.Unix()); err != nil {
+			t.Errorf("MoveRecord() over its own time: %s", err)
+		}
+	})
+}
+
+func TestHeldOffer(t *testing.T) {
+	loc := dbtest.Location
+	at := time.Date(2026, 10, 19, 9, 0, 0, 0, loc).Unix()
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, loc).Unix()
+	expires := now + 15*60
+
+	dbtest.Each(t, func(t *testing.T, s *db.Store) {
+		e, err := s.AddWaitlist(db.WaitlistEntry{UserId: 1, Day: at, To: schedule.Clock(24 * time.Hour), Duration: time.Hour}, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.OfferWaitlist(e.Id, at, expires); err != nil {
+			t.Fatal(err)
+		}
+
+		busy, err := s.Bookings(at, at+3600, 0, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(busy) != 1 || busy[0].Bay != db.HeldBay {
+			t.Fatalf("Bookings() before expiry = %v, want one held bay", busy)
+		}
+		if _, err := s.SaveRecord(2, 0, at, 0, time.Hour, 1, expires-1); !errors.Is(err, db.ErrSlotTaken) {
+			t.Fatalf("SaveRecord() over held slot: err = %v, want ErrSlotTaken", err)
+		}
+		// Предложение держит первый свободный пост, запись встаёт на второй.
+		if r, err := s.SaveRecord(2, 0, at, 0, time.Hour, 2, now); err != nil || r.Bay != 2 {
+			t.Fatalf("SaveRecord() with a spare bay = bay %d, %v; want bay 2", r.Bay, err)
+		}
+
+		if busy, err := s.Bookings(at, at+3600, 0, expires); err != nil || len(busy) != 1 || busy[0].Bay != 2 {
+			t.Fatalf("Bookings() after expiry = %v, %v; want only the record", busy, err)
+		}
+		if r, err := s.SaveRecord(3, 0, at, 0, time.Hour, 2, expires); err != nil || r.Bay != 1 {
+			t.Fatalf("SaveRecord() after expiry = bay %d, %v; want bay 1", r.Bay, err)
+		}
+	})
+}
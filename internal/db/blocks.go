@@ -0,0 +1,66 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// SlotBlock - интервал [Start, End), в который сотрудники закрыли запись на пост Bay
+// (0 - на все посты), например когда мастера нет на месте.
+type SlotBlock struct {
+	Id     int64
+	Start  int64
+	End    int64
+	Bay    int
+	Reason string
+}
+
+func (s *Store) AddSlotBlock(start, end int64, bay int, reason string, createdBy int64) (SlotBlock, error) {
+	q := `INSERT INTO slot_blocks (start_at, end_at, bay, reason, created_by, created_at) VALUES (?, ?, ?, ?, ?, ?) RETURNING id`
+
+	var id int64
+	if err := s.db.QueryRow(q, start, end, bay, reason, createdBy, time.Now().Unix()).Scan(&id); err != nil {
+		return SlotBlock{}, fmt.Errorf("failed to save slot block: %w", err)
+	}
+
+	return SlotBlock{Id: id, Start: start, End: end, Bay: bay, Reason: reason}, nil
+}
+
+func (s *Store) RemoveSlotBlock(id int64) (bool, error) {
+	res, err := s.db.Exec(`DELETE FROM slot_blocks WHERE id=?`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove slot block: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to remove slot block: %w", err)
+	}
+
+	return n > 0, nil
+}
+
+// SlotBlocks возвращает блокировки, пересекающиеся с интервалом [from, to).
+func (s *Store) SlotBlocks(from, to int64) ([]SlotBlock, error) {
+	q := `SELECT id, start_at, end_at, bay, reason FROM slot_blocks WHERE start_at < ? AND end_at > ? ORDER BY start_at, bay`
+
+	rows, err := s.db.Query(q, to, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get slot blocks: %w", err)
+	}
+	defer rows.Close()
+
+	var blocks []SlotBlock
+	for rows.Next() {
+		var b SlotBlock
+		if err := rows.Scan(&b.Id, &b.Start, &b.End, &b.Bay, &b.Reason); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		blocks = append(blocks, b)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get slot blocks: %w", err)
+	}
+
+	return blocks, nil
+}
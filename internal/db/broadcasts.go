@@ -0,0 +1,220 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Аудитории рассылки.
+const (
+	AudienceAll = "all"
+	// AudienceStorage - клиенты, чьи шины сейчас на хранении.
+	AudienceStorage = "storage"
+	// AudienceNotBooked - клиенты без записей в текущем сезоне.
+	AudienceNotBooked = "not_booked"
+)
+
+func ValidAudience(audience string) bool {
+	return audience == AudienceAll || audience == AudienceStorage || audience == AudienceNotBooked
+}
+
+// Статусы доставки рассылки одному клиенту.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	// DeliveryBlocked - клиент заблокировал бота или удалил аккаунт.
+	DeliveryBlocked = "blocked"
+	DeliveryFailed  = "failed"
+)
+
+// Broadcast - рассылка клиентам: текст или фото с подписью.
+type Broadcast struct {
+	Id   int64
+	Text string
+	// PhotoId - Telegram file_id фото; пусто, если рассылка текстовая.
+	PhotoId    string
+	Audience   string
+	CreatedBy  int64
+	CreatedAt  int64
+	FinishedAt int64
+}
+
+// BroadcastStats - сколько сообщений рассылки в каждом статусе доставки.
+type BroadcastStats struct {
+	Pending   int
+	Delivered int
+	Blocked   int
+	Failed    int
+}
+
+// AudienceUsers возвращает клиентов аудитории audience. Сезон для AudienceNotBooked
+// начинается в seasonStart.
+func (s *Store) AudienceUsers(audience string, seasonStart int64) ([]int64, error) {
+	q := `SELECT DISTINCT u.user_id FROM users u`
+	var args []any
+	switch audience {
+	case AudienceAll:
+	case AudienceStorage:
+		q += ` WHERE EXISTS (SELECT 1 FROM tire_storage t WHERE t.checked_out_at IS NULL
+			AND (t.user_id = u.user_id OR t.user_id = 0 AND t.phone = u.phone_number))`
+	case AudienceNotBooked:
+		q += ` WHERE NOT EXISTS (SELECT 1 FROM records r WHERE r.user_id = u.user_id
+			AND r.datetime >= ? AND r.` + activeCondition + `)`
+		args = append(args, seasonStart)
+	default:
+		return nil, fmt.Errorf("unknown audience %q", audience)
+	}
+	q += ` ORDER BY u.user_id`
+
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audience: %w", err)
+	}
+
+	return scanIds(rows)
+}
+
+func scanIds(rows *sql.Rows) ([]int64, error) {
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rows: %w", err)
+	}
+
+	return ids, nil
+}
+
+// CreateBroadcast сохраняет рассылку и ставит её в очередь для recipients.
+func (s *Store) CreateBroadcast(b Broadcast, recipients []int64) (Broadcast, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return Broadcast{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	b.CreatedAt, b.FinishedAt = time.Now().Unix(), 0
+	q := `INSERT INTO broadcasts (text, photo_id, audience, created_by, created_at) VALUES (?, ?, ?, ?, ?) RETURNING id`
+	if err := tx.QueryRow(q, b.Text, b.PhotoId, b.Audience, b.CreatedBy, b.CreatedAt).Scan(&b.Id); err != nil {
+		return Broadcast{}, fmt.Errorf("failed to save broadcast: %w", err)
+	}
+
+	for _, userId := range recipients {
+		q := `INSERT INTO broadcast_deliveries (broadcast_id, user_id, status) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`
+		if _, err := tx.Exec(q, b.Id, userId, DeliveryPending); err != nil {
+			return Broadcast{}, fmt.Errorf("failed to save broadcast recipient: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Broadcast{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return b, nil
+}
+
+// UnfinishedBroadcasts возвращает рассылки, прерванные до завершения, например перезапуском бота.
+func (s *Store) UnfinishedBroadcasts() ([]Broadcast, error) {
+	q := `SELECT id, text, photo_id, audience, created_by, created_at FROM broadcasts WHERE finished_at IS NULL ORDER BY id`
+
+	rows, err := s.db.Query(q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get broadcasts: %w", err)
+	}
+	defer rows.Close()
+
+	var broadcasts []Broadcast
+	for rows.Next() {
+		var b Broadcast
+		if err := rows.Scan(&b.Id, &b.Text, &b.PhotoId, &b.Audience, &b.CreatedBy, &b.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		broadcasts = append(broadcasts, b)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get broadcasts: %w", err)
+	}
+
+	return broadcasts, nil
+}
+
+// PendingRecipients возвращает клиентов, которым рассылка id ещё не отправлена.
+func (s *Store) PendingRecipients(id int64) ([]int64, error) {
+	q := `SELECT user_id FROM broadcast_deliveries WHERE broadcast_id=? AND status=? ORDER BY user_id`
+
+	rows, err := s.db.Query(q, id, DeliveryPending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get broadcast recipients: %w", err)
+	}
+
+	return scanIds(rows)
+}
+
+func (s *Store) SetDeliveryStatus(id, userId int64, status string) error {
+	q := `UPDATE broadcast_deliveries SET status=?, sent_at=? WHERE broadcast_id=? AND user_id=?`
+
+	if _, err := s.db.Exec(q, status, time.Now().Unix(), id, userId); err != nil {
+		return fmt.Errorf("failed to set delivery status: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) FinishBroadcast(id int64) error {
+	if _, err := s.db.Exec(`UPDATE broadcasts SET finished_at=? WHERE id=?`, time.Now().Unix(), id); err != nil {
+		return fmt.Errorf("failed to finish broadcast: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) BroadcastStats(id int64) (BroadcastStats, error) {
+	q := `SELECT status, COUNT(*) FROM broadcast_deliveries WHERE broadcast_id=? GROUP BY status`
+
+	rows, err := s.db.Query(q, id)
+	if err != nil {
+		return BroadcastStats{}, fmt.Errorf("failed to get broadcast stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats BroadcastStats
+	for rows.Next() {
+		var (
+			status string
+			n      int
+		)
+		if err := rows.Scan(&status, &n); err != nil {
+			return BroadcastStats{}, fmt.Errorf("failed to scan row: %w", err)
+		}
+		stats.add(status, n)
+	}
+
+	if err = rows.Err(); err != nil {
+		return BroadcastStats{}, fmt.Errorf("failed to get broadcast stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+func (st *BroadcastStats) add(status string, n int) {
+	switch status {
+	case DeliveryPending:
+		st.Pending += n
+	case DeliveryDelivered:
+		st.Delivered += n
+	case DeliveryBlocked:
+		st.Blocked += n
+	case DeliveryFailed:
+		st.Failed += n
+	}
+}
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"fmt"
+)
+
+// ClosedDay - день, в который мастерская не работает (праздник, санитарный день, отпуск).
+// Day - unix-метка полуночи дня в часовом поясе мастерской.
+type ClosedDay struct {
+	Day    int64
+	Reason string
+}
+
+func (s *Store) AddClosedDay(day int64, reason string) error {
+	q := `INSERT INTO closed_days (day, reason) VALUES (?, ?) ON CONFLICT(day) DO UPDATE SET reason=excluded.reason`
+
+	_, err := s.db.Exec(q, day, reason)
+	if err != nil {
+		return fmt.Errorf("failed to save closed day: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) RemoveClosedDay(day int64) (bool, error) {
+	q := `DELETE FROM closed_days WHERE day=?`
+
+	res, err := s.db.Exec(q, day)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove closed day: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to remove closed day: %w", err)
+	}
+
+	return n > 0, nil
+}
+
+// GetClosedDays возвращает закрытые дни в полуинтервале [from, to).
+func (s *Store) GetClosedDays(from, to int64) ([]ClosedDay, error) {
+	q := `SELECT day, reason FROM closed_days WHERE day >= ? AND day < ? ORDER BY day`
+
+	rows, err := s.db.Query(q, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get closed days: %w", err)
+	}
+	defer rows.Close()
+
+	var days []ClosedDay
+	for rows.Next() {
+		var d ClosedDay
+		err = rows.Scan(&d.Day, &d.Reason)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		days = append(days, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get closed days: %w", err)
+	}
+
+	return days, nil
+}
@@ -3,52 +3,94 @@ package db
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
-const (
-	dbPath = "data/sqlite/sqlite.db"
-)
+// Store - хранилище бота поверх database/sql.
+type Store struct {
+	db *conn
+	// location - часовой пояс мастерской. Все даты в базе - настоящие unix-метки,
+	// а полночь дня считается в этом поясе.
+	location *time.Location
+}
 
-var db *sql.DB
+var _ Storage = (*Store)(nil)
 
-func Init() {
-	var err error
-	db, err = sql.Open("sqlite3", dbPath)
+// OpenSQLite открывает базу SQLite по пути dbPath, не применяя миграций.
+func OpenSQLite(dbPath string, loc *time.Location) (*Store, error) {
+	// _txlock=immediate берёт блокировку на запись в начале транзакции, чтобы две
+	// параллельные записи не прочитали одну и ту же занятость.
+	db, err := sql.Open("sqlite3", dbPath+"?_txlock=immediate&_busy_timeout=5000")
 	if err != nil {
-		panic(fmt.Errorf("failed to open database: %w", err))
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	q := `CREATE TABLE IF NOT EXISTS users (id INTEGER PRIMARY KEY AUTOINCREMENT, user_id INTEGER, name TEXT, phone_number INTEGER);
-		CREATE TABLE IF NOT EXISTS records (id INTEGER PRIMARY KEY AUTOINCREMENT, user_id INTEGER, datetime INTEGER)`
-	_, err = db.Exec(q)
+	return &Store{db: &conn{DB: db, dialect: DialectSQLite}, location: loc}, nil
+}
+
+// NewSQLite открывает базу SQLite по пути dbPath, применяет миграции и заполняет
+// пустой каталог услуг. Если миграции не применились, база закрывается и возвращается ошибка.
+func NewSQLite(dbPath string, loc *time.Location) (*Store, error) {
+	s, err := OpenSQLite(dbPath, loc)
 	if err != nil {
-		log.Printf("failed to create table: %s", err)
+		return nil, err
+	}
+	if err := s.prepare(); err != nil {
+		return nil, err
 	}
+
+	return s, nil
 }
 
-func GetInfo(userId int) (string, int, error) {
+// prepare применяет миграции и заполняет пустой каталог. При ошибке база закрывается.
+func (s *Store) prepare() error {
+	if err := s.Migrate(); err != nil {
+		s.Close()
+		return err
+	}
+
+	if err := s.seedServices(); err != nil {
+		s.Close()
+		return fmt.Errorf("failed to seed services: %w", err)
+	}
+	if err := s.seedPrices(); err != nil {
+		s.Close()
+		return fmt.Errorf("failed to seed prices: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) GetInfo(userId int64) (string, string, error) {
 	q := `SELECT name, phone_number FROM users WHERE user_id=?`
 
 	var (
 		name   string
-		number int
+		number sql.NullString
 	)
-	err := db.QueryRow(q, userId).Scan(&name, &number)
+	err := s.db.QueryRow(q, userId).Scan(&name, &number)
 	if err != nil {
 		fmt.Printf("failed to check if row exists: %v", err)
-		return "", 0, err
+		return "", "", err
 	}
 
-	return name, number, nil
+	return name, number.String, nil
 }
 
-func SaveUser(userId int, name string, number int) error {
+func (s *Store) SaveUser(userId int64, name string, number string) error {
 	q := `INSERT INTO users (user_id, name, phone_number) VALUES (?, ?, ?)`
 
-	_, err := db.Exec(q, userId, name, number)
+	_, err := s.db.Exec(q, userId, name, number)
 	if err != nil {
 		return fmt.Errorf("failed to save data: %w", err)
 	}
@@ -56,80 +98,128 @@ func SaveUser(userId int, name string, number int) error {
 	return nil
 }
 
-func SaveRecord(userId int64, datetime int64) error {
-	q := `INSERT INTO records (user_id, datetime) VALUES (?, ?)`
+// SaveRecord сохраняет запись на услугу serviceId длительностью duration.
+// Занятость на момент now перепроверяется внутри транзакции.
+func (s *Store) SaveRecord(userId, vehicleId int64, datetime int64, serviceId int64, duration time.Duration, bays int, now int64) (Record, error) {
+	return s.saveRecord(Record{
+		UserId:    userId,
+		Datetime:  datetime,
+		ServiceId: serviceId,
+		Duration:  duration,
+		Status:    StatusPending,
+		Vehicle:   Vehicle{Id: vehicleId, UserId: userId},
+	}, bays, now)
+}
 
-	_, err := db.Exec(q, userId, datetime)
+// SaveWalkIn сохраняет запись, созданную сотрудниками за клиента без Telegram.
+// Такая запись сразу подтверждена.
+func (s *Store) SaveWalkIn(name, phone string, datetime int64, serviceId int64, duration time.Duration, bays int, now int64) (Record, error) {
+	return s.saveRecord(Record{
+		Datetime:    datetime,
+		ServiceId:   serviceId,
+		Duration:    duration,
+		Status:      StatusConfirmed,
+		ClientName:  name,
+		ClientPhone: phone,
+	}, bays, now)
+}
+
+// saveRecord сохраняет record на первый свободный пост и возвращает её с id и номером поста.
+func (s *Store) saveRecord(record Record, bays int, now int64) (Record, error) {
+	tx, err := s.db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to save data: %w", err)
+		return Record{}, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	return nil
+	if err := insertRecord(tx, &record, bays, now); err != nil {
+		return Record{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Record{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return record, nil
 }
 
-func GetAllRecords(userId int64) ([]int, error) {
-	q := `SELECT datetime FROM records WHERE user_id=? AND datetime>strftime('%s', 'now') ORDER BY datetime`
+// insertRecord в транзакции t сохраняет record на первый свободный пост и
+// заполняет её id и номер поста. Занятость считается на момент now.
+// Если свободного поста нет - ErrSlotTaken.
+func insertRecord(t *tx, record *Record, bays int, now int64) error {
+	if err := t.lockRecords(); err != nil {
+		return fmt.Errorf("failed to lock records: %w", err)
+	}
 
-	rows, err := db.Query(q, userId)
+	end := record.Datetime + int64(record.Duration.Seconds())
+	busy, err := bookings(t, record.Datetime, end, 0, now)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get records: %w", err)
+		return err
 	}
-	defer rows.Close()
-
-	var datetimes []int
-	for rows.Next() {
-		var datetime int
-		err = rows.Scan(&datetime)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
-		}
-		datetimes = append(datetimes, datetime)
+	record.Bay = FreeBay(busy, record.Datetime, end, bays)
+	if record.Bay == 0 {
+		return ErrSlotTaken
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("failed to get records: %w", err)
+	q := `INSERT INTO records (user_id, datetime, bay, service_id, duration, status, client_name, client_phone, vehicle_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`
+
+	var (
+		name, phone sql.NullString
+		vehicleId   sql.NullInt64
+	)
+	if record.WalkIn() {
+		name = sql.NullString{String: record.ClientName, Valid: true}
+		phone = sql.NullString{String: record.ClientPhone, Valid: true}
+	}
+	if record.Vehicle.Id != 0 {
+		vehicleId = sql.NullInt64{Int64: record.Vehicle.Id, Valid: true}
+	}
+	err = t.QueryRow(
+		q, record.UserId, record.Datetime, record.Bay, record.ServiceId, int(record.Duration.Minutes()), record.Status, name, phone, vehicleId,
+	).Scan(&record.Id)
+	if isUniqueViolation(err) {
+		return ErrSlotTaken
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save data: %w", err)
 	}
 
-	return datetimes, nil
+	return logStatus(t, record.Id, record.Status)
 }
 
-func GetAllTimes(result int64) ([]string, error) {
-	q := `WITH available_times(available_time) AS (
-    VALUES (9*60*60), (10.5*60*60), (12*60*60), (13.5*60*60), (15*60*60), (16.5*60*60), (18*60*60), (19.5*60*60)
-)
-
-SELECT substr(time(available_time, 'unixepoch'), 0, 6)
-FROM available_times
-WHERE available_time not in (SELECT datetime - $1 FROM records WHERE datetime >= $1 AND datetime <= $1 + 24*60*60)
-AND ((available_time > CAST(strftime('%s', 'now') AS INTEGER) - CAST(strftime('%s', date('now')) AS INTEGER)) OR (CAST(strftime('%s', date('now')) AS INTEGER) != $1));`
+// GetAllRecords возвращает записи пользователя, которые начнутся после now, кроме
+// отменённых им самим, и записи, работы по которым ещё идут.
+func (s *Store) GetAllRecords(userId, now int64) ([]Record, error) {
+	q := recordQuery + ` WHERE r.user_id=? AND r.status != '` + StatusCancelledByClient + `'
+		AND (r.datetime > ? OR r.status IN ('` + StatusArrived + `', '` + StatusInProgress + `')) ORDER BY r.datetime`
 
-	rows, err := db.Query(q, result)
+	rows, err := s.db.Query(q, userId, now)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get records: %w", err)
 	}
 	defer rows.Close()
 
-	var datetimes []string
+	var records []Record
 	for rows.Next() {
-		var datetime string
-		err = rows.Scan(&datetime)
+		record, err := scanRecord(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
-		datetimes = append(datetimes, datetime)
+		records = append(records, record)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("failed to get records: %w", err)
 	}
 
-	return datetimes, nil
+	return records, nil
 }
 
-func UpdateNumber(newNumber, userId int) error {
+func (s *Store) UpdateNumber(userId int64, newNumber string) error {
 	q := `UPDATE users SET phone_number=? WHERE user_id=?`
 
-	_, err := db.Exec(q, newNumber, userId)
+	_, err := s.db.Exec(q, newNumber, userId)
 	if err != nil {
 		return fmt.Errorf("failed to update number: %w", err)
 	}
@@ -137,11 +227,11 @@ func UpdateNumber(newNumber, userId int) error {
 	return nil
 }
 
-func IsExists(userId int) (bool, error) {
+func (s *Store) IsExists(userId int64) (bool, error) {
 	q := `SELECT COUNT(*) FROM users WHERE user_id=?`
 
 	var count int
-	err := db.QueryRow(q, userId).Scan(&count)
+	err := s.db.QueryRow(q, userId).Scan(&count)
 	if err != nil {
 		return false, fmt.Errorf("failed to check if row exists: %w", err)
 	}
@@ -0,0 +1,100 @@
+// Package dbtest открывает хранилища для тестов: SQLite - всегда, PostgreSQL - если
+// в переменной BOT_TEST_POSTGRES_DSN задана строка подключения. Каждый тест на
+// PostgreSQL работает в своей временной схеме и не трогает остальные данные базы.
+package dbtest
+
+import (
+	"automobile36/internal/db"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// PostgresEnv - переменная окружения со строкой подключения к PostgreSQL для тестов.
+const PostgresEnv = "BOT_TEST_POSTGRES_DSN"
+
+// Location - часовой пояс мастерской в тестах. Не UTC, чтобы местная полночь
+// и полночь по UTC приходились на разные даты.
+var Location = mustLoadLocation("Europe/Moscow")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+
+	return loc
+}
+
+// Each запускает f подтестом на каждом драйвере с чистой базой после всех миграций.
+func Each(t *testing.T, f func(t *testing.T, s *db.Store)) {
+	t.Run(db.DialectSQLite, func(t *testing.T) { f(t, SQLite(t)) })
+	t.Run(db.DialectPostgres, func(t *testing.T) { f(t, Postgres(t)) })
+}
+
+// SQLite создаёт базу SQLite во временном каталоге теста и применяет миграции.
+func SQLite(t *testing.T) *db.Store {
+	t.Helper()
+
+	s, err := db.NewSQLite(filepath.Join(t.TempDir(), "bot.db"), Location)
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %s", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+// Postgres создаёт схему в базе из BOT_TEST_POSTGRES_DSN, применяет в ней миграции
+// и удаляет схему после теста. Без переменной тест пропускается.
+func Postgres(t *testing.T) *db.Store {
+	t.Helper()
+
+	dsn := os.Getenv(PostgresEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set, skipping PostgreSQL; set it to a test database DSN to run this test (see README, «Тесты»)", PostgresEnv)
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("failed to open postgres: %s", err)
+	}
+	schema := fmt.Sprintf("bot_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		admin.Close()
+		t.Fatalf("failed to create schema: %s", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`); err != nil {
+			t.Errorf("failed to drop schema %s: %s", schema, err)
+		}
+		admin.Close()
+	})
+
+	s, err := db.NewPostgres(withSearchPath(dsn, schema), Location)
+	if err != nil {
+		t.Fatalf("failed to open postgres: %s", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+// withSearchPath дописывает к строке подключения схему по умолчанию. lib/pq
+// передаёт незнакомые параметры серверу как параметры сеанса.
+func withSearchPath(dsn, schema string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		return dsn + sep + "search_path=" + schema
+	}
+
+	return dsn + " search_path=" + schema
+}
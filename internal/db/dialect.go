@@ -0,0 +1,102 @@
+package db
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// Поддерживаемые диалекты SQL.
+const (
+	DialectSQLite   = "sqlite"
+	DialectPostgres = "postgres"
+)
+
+// conn оборачивает *sql.DB: запросы пишутся с плейсхолдерами ? и переписываются
+// под диалект базы перед выполнением.
+type conn struct {
+	*sql.DB
+	dialect string
+}
+
+func (c *conn) rebind(q string) string {
+	return rebind(c.dialect, q)
+}
+
+func (c *conn) Exec(q string, args ...any) (sql.Result, error) {
+	return c.DB.Exec(c.rebind(q), args...)
+}
+
+func (c *conn) Query(q string, args ...any) (*sql.Rows, error) {
+	return c.DB.Query(c.rebind(q), args...)
+}
+
+func (c *conn) QueryRow(q string, args ...any) *sql.Row {
+	return c.DB.QueryRow(c.rebind(q), args...)
+}
+
+func (c *conn) Begin() (*tx, error) {
+	t, err := c.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	return &tx{Tx: t, dialect: c.dialect}, nil
+}
+
+// tx - транзакция с той же перезаписью плейсхолдеров, что и у conn.
+type tx struct {
+	*sql.Tx
+	dialect string
+}
+
+func (t *tx) Exec(q string, args ...any) (sql.Result, error) {
+	return t.Tx.Exec(rebind(t.dialect, q), args...)
+}
+
+func (t *tx) Query(q string, args ...any) (*sql.Rows, error) {
+	return t.Tx.Query(rebind(t.dialect, q), args...)
+}
+
+func (t *tx) QueryRow(q string, args ...any) *sql.Row {
+	return t.Tx.QueryRow(rebind(t.dialect, q), args...)
+}
+
+// lockRecords не даёт параллельным транзакциям менять записи, пока эта
+// проверяет занятость постов. В SQLite то же делает _txlock=immediate.
+func (t *tx) lockRecords() error {
+	if t.dialect != DialectPostgres {
+		return nil
+	}
+	_, err := t.Tx.Exec(`LOCK TABLE records IN SHARE ROW EXCLUSIVE MODE`)
+
+	return err
+}
+
+// rebind заменяет плейсхолдеры ? на $1, $2, ... для PostgreSQL.
+// Знаки вопроса внутри строковых литералов не трогаются.
+func rebind(dialect, q string) string {
+	if dialect != DialectPostgres || !strings.Contains(q, "?") {
+		return q
+	}
+
+	var (
+		b       strings.Builder
+		n       int
+		literal bool
+	)
+	b.Grow(len(q) + 8)
+	for _, r := range q {
+		switch {
+		case r == '\'':
+			literal = !literal
+		case r == '?' && !literal:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
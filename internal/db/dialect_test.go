@@ -0,0 +1,53 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		name, dialect, q, want string
+	}{
+		{"sqlite keeps placeholders", DialectSQLite, `SELECT * FROM records WHERE id=? AND user_id=?`, `SELECT * FROM records WHERE id=? AND user_id=?`},
+		{"postgres numbers placeholders", DialectPostgres, `UPDATE records SET bay=? WHERE id=? AND user_id=?`, `UPDATE records SET bay=$1 WHERE id=$2 AND user_id=$3`},
+		{"no placeholders", DialectPostgres, `SELECT COUNT(*) FROM users`, `SELECT COUNT(*) FROM users`},
+		{"literal is not touched", DialectPostgres, `SELECT ? WHERE name='who?' AND id=?`, `SELECT $1 WHERE name='who?' AND id=$2`},
+		{"escaped quote in literal", DialectPostgres, `SELECT 'it''s ?', ?`, `SELECT 'it''s ?', $1`},
+		{"more than nine", DialectPostgres, `VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, `VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rebind(tt.dialect, tt.q); got != tt.want {
+				t.Errorf("rebind(%q) = %q, want %q", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"other error", errors.New("boom"), false},
+		{"sqlite unique", sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintUnique}, true},
+		{"sqlite primary key", sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintPrimaryKey}, false},
+		{"postgres unique", &pq.Error{Code: "23505"}, true},
+		{"postgres foreign key", &pq.Error{Code: "23503"}, false},
+		{"wrapped", fmt.Errorf("failed to save data: %w", &pq.Error{Code: "23505"}), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isUniqueViolation(tt.err); got != tt.want {
+				t.Errorf("isUniqueViolation(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
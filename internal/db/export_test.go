@@ -0,0 +1,16 @@
+package db
+
+// Доступ внешних тестов пакета к внутренностям хранилища.
+
+var IsUniqueViolation = isUniqueViolation
+
+// Exec выполняет запрос в обход репозиториев.
+func (s *Store) Exec(q string, args ...any) error {
+	_, err := s.db.Exec(q, args...)
+	return err
+}
+
+// Dialect возвращает диалект SQL базы.
+func (s *Store) Dialect() string {
+	return s.db.dialect
+}
@@ -0,0 +1,201 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// upgradeLegacySchema доводит базу, созданную до появления миграций, до схемы
+// первой миграции: добавляет недостающие колонки records, переводит даты в
+// настоящие unix-метки и разводит записи на одно время по разным постам.
+// Недостающие таблицы создаст сама миграция.
+func (s *Store) upgradeLegacySchema() error {
+	exists, err := s.tableExists("records")
+	if err != nil || !exists {
+		return err
+	}
+
+	columns := []struct{ table, column, definition string }{
+		{"records", "bay", "INTEGER NOT NULL DEFAULT 1"},
+		{"records", "service_id", "INTEGER"},
+		// Записи, сделанные до появления каталога услуг, занимали один слот в 90 минут.
+		{"records", "duration", "INTEGER NOT NULL DEFAULT 90"},
+		{"records", "status", "TEXT NOT NULL DEFAULT '" + StatusPending + "'"},
+	}
+	for _, c := range columns {
+		if err := s.addColumn(c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	exists, err = s.tableExists("closed_days")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if _, err := s.db.Exec(`CREATE TABLE closed_days (day INTEGER PRIMARY KEY, reason TEXT)`); err != nil {
+			return fmt.Errorf("failed to create closed_days: %w", err)
+		}
+	}
+
+	if err := s.convertWallClockTimes(); err != nil {
+		return err
+	}
+
+	return s.spreadDuplicateSlots()
+}
+
+// spreadDuplicateSlots разводит по постам записи, занимающие один пост с одного
+// времени. До миграций база такого не запрещала, а уникальный индекс
+// records_datetime_bay первой миграции на таких данных не создаётся. Самая ранняя
+// запись остаётся на своём посту, остальные переходят на свободные посты с
+// наименьшими номерами, поэтому ни одна запись не теряется.
+func (s *Store) spreadDuplicateSlots() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, datetime, bay FROM records WHERE status <> ? ORDER BY datetime, id`, StatusCancelledByClient)
+	if err != nil {
+		return fmt.Errorf("failed to read records: %w", err)
+	}
+	type record struct{ id, datetime int64 }
+	var (
+		taken = make(map[int64]map[int]bool)
+		dups  []record
+	)
+	for rows.Next() {
+		var (
+			r   record
+			bay int
+		)
+		if err := rows.Scan(&r.id, &r.datetime, &bay); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		if taken[r.datetime] == nil {
+			taken[r.datetime] = make(map[int]bool)
+		}
+		if taken[r.datetime][bay] {
+			dups = append(dups, r)
+			continue
+		}
+		taken[r.datetime][bay] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read records: %w", err)
+	}
+
+	// Посты раздаются после чтения всех записей времени, чтобы перенесённая
+	// запись не заняла пост, на котором уже стоит более поздняя.
+	for _, r := range dups {
+		bay := 1
+		for taken[r.datetime][bay] {
+			bay++
+		}
+		taken[r.datetime][bay] = true
+		if _, err := tx.Exec(`UPDATE records SET bay=? WHERE id=?`, bay, r.id); err != nil {
+			return fmt.Errorf("failed to update records: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// addColumn добавляет колонку в существующую таблицу, если её ещё нет.
+func (s *Store) addColumn(table, column, definition string) error {
+	rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to get table info: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, typ        string
+			dflt             sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to get table info: %w", err)
+	}
+
+	_, err = s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+
+	return nil
+}
+
+// convertWallClockTimes однократно переводит records.datetime и closed_days.day
+// из прежнего формата (время по часам мастерской, записанное как UTC) в
+// настоящие unix-метки. Выполненное преобразование отмечается в PRAGMA user_version.
+func (s *Store) convertWallClockTimes() error {
+	var version int
+	if err := s.db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return fmt.Errorf("failed to get user_version: %w", err)
+	}
+	if version >= 1 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, c := range []struct{ table, key, column string }{
+		{"records", "id", "datetime"},
+		{"closed_days", "day", "day"},
+	} {
+		rows, err := tx.Query(fmt.Sprintf("SELECT %s, %s FROM %s", c.key, c.column, c.table))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", c.table, err)
+		}
+		converted := make(map[int64]int64)
+		for rows.Next() {
+			var key, value int64
+			if err := rows.Scan(&key, &value); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan row: %w", err)
+			}
+			converted[key] = s.wallClockToInstant(value)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to read %s: %w", c.table, err)
+		}
+
+		// Сдвиг меньше суток, поэтому полночи разных дней не совпадут и ключ closed_days.day останется уникальным.
+		q := fmt.Sprintf("UPDATE %s SET %s=? WHERE %s=?", c.table, c.column, c.key)
+		for key, value := range converted {
+			if _, err := tx.Exec(q, value, key); err != nil {
+				return fmt.Errorf("failed to update %s: %w", c.table, err)
+			}
+		}
+	}
+
+	if _, err := tx.Exec(`PRAGMA user_version = 1`); err != nil {
+		return fmt.Errorf("failed to set user_version: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) wallClockToInstant(value int64) int64 {
+	t := time.Unix(value, 0).UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, s.location).Unix()
+}
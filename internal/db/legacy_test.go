@@ -0,0 +1,74 @@
+package db_test
+
+import (
+	"automobile36/internal/db"
+	"automobile36/internal/db/dbtest"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// legacySchema - схема базы до появления миграций.
+const legacySchema = `CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, user_id INTEGER, name TEXT, phone_number INTEGER);
+	CREATE TABLE records (id INTEGER PRIMARY KEY AUTOINCREMENT, user_id INTEGER, datetime INTEGER);
+	CREATE TABLE closed_days (day INTEGER PRIMARY KEY, reason TEXT);
+	CREATE TABLE services (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, category TEXT, price INTEGER, duration INTEGER);
+	CREATE TABLE service_prices (service_id INTEGER, radius INTEGER, price INTEGER, PRIMARY KEY (service_id, radius));
+	CREATE TABLE telegram_files (key TEXT PRIMARY KEY, file_id TEXT, mod_time INTEGER);
+	CREATE TABLE session_data (key TEXT PRIMARY KEY, value TEXT, expires_at INTEGER);
+	CREATE TABLE conversations (name TEXT, key TEXT, state TEXT, expires_at INTEGER, PRIMARY KEY (name, key))`
+
+// TestUpgradeLegacyDuplicates проверяет, что база без миграций с несколькими
+// записями на одно время обновляется и ни одна запись не теряется.
+func TestUpgradeLegacyDuplicates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.db")
+	legacy, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := legacy.Exec(legacySchema); err != nil {
+		t.Fatal(err)
+	}
+	// Прежний формат: время по часам мастерской, записанное как UTC.
+	at := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC).Unix()
+	for i, datetime := range []int64{at, at + 3600, at, at} {
+		if _, err := legacy.Exec(`INSERT INTO records (user_id, datetime) VALUES (?, ?)`, i+1, datetime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	legacy.Close()
+
+	s, err := db.NewSQLite(path, dbtest.Location)
+	if err != nil {
+		t.Fatalf("NewSQLite() on a legacy database with duplicate slots: %s", err)
+	}
+	defer s.Close()
+
+	want := time.Date(2026, 10, 19, 10, 0, 0, 0, dbtest.Location).Unix()
+	for _, c := range []struct {
+		id       int64
+		datetime int64
+		bay      int
+	}{
+		{1, want, 1},
+		{2, want + 3600, 1},
+		{3, want, 2},
+		{4, want, 3},
+	} {
+		r, err := s.GetRecordById(c.id)
+		if err != nil {
+			t.Fatalf("GetRecordById(%d): %s", c.id, err)
+		}
+		if r.Datetime != c.datetime || r.Bay != c.bay || r.Status != db.StatusPending {
+			t.Errorf("record %d = %+v, want pending at %d on bay %d", c.id, r, c.datetime, c.bay)
+		}
+	}
+
+	// Уникальный индекс создан и снова не даёт занять пост дважды.
+	err = s.Exec(`INSERT INTO records (user_id, datetime, bay, duration, status) VALUES (?, ?, ?, ?, ?)`,
+		5, want, 1, 60, db.StatusPending)
+	if !db.IsUniqueViolation(err) {
+		t.Errorf("insert over an upgraded slot: err = %v, want unique violation", err)
+	}
+}
@@ -0,0 +1,1095 @@
+// Package memory - хранилище бота в памяти процесса. Повторяет поведение
+// SQLite-хранилища и нужно для тестов обработчиков.
+package memory
+
+import (
+	"automobile36/internal/db"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/conversation"
+)
+
+type user struct {
+	name   string
+	number string
+}
+
+type session struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+type file struct {
+	fileId  string
+	modTime int64
+}
+
+type reminder struct {
+	recordId int64
+	before   time.Duration
+}
+
+type Storage struct {
+	mu sync.Mutex
+
+	users      map[int64]user
+	records    []db.Record
+	lastRecord int64
+	history    map[int64][]db.StatusChange
+	reminders  map[reminder]bool
+	admins     map[int64]db.Admin
+	blocks     []db.SlotBlock
+	lastBlock  int64
+	// vehicles - автомобили по id; удалённые помечены в removed.
+	vehicles    map[int64]db.Vehicle
+	removed     map[int64]bool
+	lastVehicle int64
+	storages    []db.StorageContract
+	lastStorage int64
+	broadcasts  []db.Broadcast
+	deliveries  map[int64]map[int64]string
+	waitlist    []db.WaitlistEntry
+	// queuedAt - когда заявка встала в конец очереди, по id.
+	queuedAt   map[int64]int64
+	closedDays map[int64]string
+	services   []db.Service
+	prices     []db.Price
+	files      map[string]file
+	sessions   map[string]session
+}
+
+var _ db.Storage = (*Storage)(nil)
+
+// New создаёт пустое хранилище с каталогом услуг и ценами по умолчанию.
+func New() *Storage {
+	s := &Storage{
+		users:      make(map[int64]user),
+		history:    make(map[int64][]db.StatusChange),
+		reminders:  make(map[reminder]bool),
+		admins:     make(map[int64]db.Admin),
+		vehicles:   make(map[int64]db.Vehicle),
+		removed:    make(map[int64]bool),
+		deliveries: make(map[int64]map[int64]string),
+		queuedAt:   make(map[int64]int64),
+		closedDays: make(map[int64]string),
+		files:      make(map[string]file),
+		sessions:   make(map[string]session),
+	}
+
+	for i, service := range db.DefaultServices {
+		service.Id = int64(i + 1)
+		s.services = append(s.services, service)
+
+		p, ok := db.DefaultRadiusPrices[service.Name]
+		if !ok {
+			continue
+		}
+		for r := db.MinRadius; r <= db.MaxRadius; r++ {
+			s.prices = append(s.prices, db.Price{ServiceId: service.Id, Radius: r, Price: p[0] + (r-db.MinRadius)*p[1]})
+		}
+	}
+
+	return s
+}
+
+func (s *Storage) Close() error {
+	return nil
+}
+
+func (s *Storage) GetInfo(userId int64) (string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userId]
+	if !ok {
+		return "", "", sql.ErrNoRows
+	}
+
+	return u.name, u.number, nil
+}
+
+func (s *Storage) SaveUser(userId int64, name string, number string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[userId] = user{name: name, number: number}
+
+	return nil
+}
+
+func (s *Storage) UpdateNumber(userId int64, number string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[userId]; ok {
+		u.number = number
+		s.users[userId] = u
+	}
+
+	return nil
+}
+
+func (s *Storage) IsExists(userId int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.users[userId]
+
+	return ok, nil
+}
+
+func active(r db.Record) bool {
+	return r.Status != db.StatusCancelledByClient && r.Status != db.StatusCancelledByShop
+}
+
+func (s *Storage) logStatus(id int64, status string) {
+	s.history[id] = append(s.history[id], db.StatusChange{Status: status, ChangedAt: time.Now().Unix()})
+}
+
+func (s *Storage) changeStatus(i int, to string) error {
+	if !db.CanTransition(s.records[i].Status, to) {
+		return db.ErrBadTransition
+	}
+	s.records[i].Status = to
+	s.logStatus(s.records[i].Id, to)
+
+	return nil
+}
+
+func (s *Storage) serviceName(id int64) string {
+	for _, service := range s.services {
+		if service.Id == id {
+			return service.Name
+		}
+	}
+
+	return ""
+}
+
+func (s *Storage) bookings(from, to int64, exclude int64, now int64) []db.Booking {
+	var res []db.Booking
+	for _, r := range s.records {
+		end := r.Datetime + int64(r.Duration.Seconds())
+		if r.Id == exclude || !active(r) || r.Datetime >= to || end <= from {
+			continue
+		}
+		res = append(res, db.Booking{Start: r.Datetime, End: end, Bay: r.Bay})
+	}
+	for _, b := range s.blocks {
+		if b.Start < to && b.End > from {
+			res = append(res, db.Booking{Start: b.Start, End: b.End, Bay: b.Bay})
+		}
+	}
+	for _, e := range s.waitlist {
+		end := e.OfferDatetime + int64(e.Duration.Seconds())
+		if e.Status == db.WaitlistOffered && e.OfferExpiresAt > now && e.OfferDatetime < to && end > from {
+			res = append(res, db.Booking{Start: e.OfferDatetime, End: end, Bay: db.HeldBay})
+		}
+	}
+
+	return res
+}
+
+func (s *Storage) SaveRecord(userId, vehicleId int64, datetime int64, serviceId int64, duration time.Duration, bays int, now int64) (db.Record, error) {
+	return s.saveRecord(db.Record{
+		UserId:    userId,
+		Datetime:  datetime,
+		ServiceId: serviceId,
+		Duration:  duration,
+		Status:    db.StatusPending,
+		Vehicle:   db.Vehicle{Id: vehicleId, UserId: userId},
+	}, bays, now)
+}
+
+func (s *Storage) SaveWalkIn(name, phone string, datetime int64, serviceId int64, duration time.Duration, bays int, now int64) (db.Record, error) {
+	return s.saveRecord(db.Record{
+		Datetime:    datetime,
+		ServiceId:   serviceId,
+		Duration:    duration,
+		Status:      db.StatusConfirmed,
+		ClientName:  name,
+		ClientPhone: phone,
+	}, bays, now)
+}
+
+func (s *Storage) saveRecord(record db.Record, bays int, now int64) (db.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.insertRecord(record, bays, now)
+}
+
+// insertRecord сохраняет record на первый свободный пост; s.mu уже захвачен.
+func (s *Storage) insertRecord(record db.Record, bays int, now int64) (db.Record, error) {
+	end := record.Datetime + int64(record.Duration.Seconds())
+	record.Bay = db.FreeBay(s.bookings(record.Datetime, end, 0, now), record.Datetime, end, bays)
+	if record.Bay == 0 {
+		return db.Record{}, db.ErrSlotTaken
+	}
+
+	s.lastRecord++
+	record.Id = s.lastRecord
+	record.ServiceName = s.serviceName(record.ServiceId)
+	// В отличие от базы, автомобиль запоминается на момент записи.
+	if v, ok := s.vehicles[record.Vehicle.Id]; ok {
+		record.Vehicle = v
+	}
+	s.records = append(s.records, record)
+	s.logStatus(record.Id, record.Status)
+
+	return record, nil
+}
+
+func (s *Storage) GetAllRecords(userId, now int64) ([]db.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []db.Record
+	for _, r := range s.records {
+		inWork := r.Status == db.StatusArrived || r.Status == db.StatusInProgress
+		if r.UserId == userId && r.Status != db.StatusCancelledByClient && (r.Datetime > now || inWork) {
+			res = append(res, r)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Datetime < res[j].Datetime })
+
+	return res, nil
+}
+
+func (s *Storage) find(id, userId int64) (int, bool) {
+	for i, r := range s.records {
+		if r.Id == id && r.UserId == userId {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+func (s *Storage) GetRecord(id, userId int64) (db.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.find(id, userId)
+	if !ok {
+		return db.Record{}, db.ErrNoRecord
+	}
+
+	return s.records[i], nil
+}
+
+func (s *Storage) GetRecordById(id int64) (db.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range s.records {
+		if r.Id == id {
+			return r, nil
+		}
+	}
+
+	return db.Record{}, db.ErrNoRecord
+}
+
+func (s *Storage) SetStatus(id int64, status string) (db.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, r := range s.records {
+		if r.Id != id {
+			continue
+		}
+		if err := s.changeStatus(i, status); err != nil {
+			return db.Record{}, err
+		}
+
+		return s.records[i], nil
+	}
+
+	return db.Record{}, db.ErrNoRecord
+}
+
+func (s *Storage) StatusHistory(id int64) ([]db.StatusChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]db.StatusChange(nil), s.history[id]...), nil
+}
+
+func (s *Storage) CancelRecord(id, userId int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.find(id, userId)
+	if !ok {
+		return db.ErrNoRecord
+	}
+
+	return s.changeStatus(i, db.StatusCancelledByClient)
+}
+
+func (s *Storage) MoveRecord(id, userId, datetime int64, bays int, now int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.find(id, userId)
+	if !ok {
+		return 0, db.ErrNoRecord
+	}
+	if !db.Changeable(s.records[i].Status) {
+		return 0, db.ErrBadTransition
+	}
+
+	end := datetime + int64(s.records[i].Duration.Seconds())
+	bay := db.FreeBay(s.bookings(datetime, end, id, now), datetime, end, bays)
+	if bay == 0 {
+		return 0, db.ErrSlotTaken
+	}
+	if s.records[i].Status != db.StatusPending {
+		if err := s.changeStatus(i, db.StatusPending); err != nil {
+			return 0, err
+		}
+	}
+	s.records[i].Datetime = datetime
+	s.records[i].Bay = bay
+	for key := range s.reminders {
+		if key.recordId == id {
+			delete(s.reminders, key)
+		}
+	}
+
+	return bay, nil
+}
+
+func (s *Storage) DayRecords(from, to int64) ([]db.ScheduledRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []db.ScheduledRecord
+	for _, r := range s.records {
+		if !active(r) || r.Datetime < from || r.Datetime >= to {
+			continue
+		}
+		entry := db.ScheduledRecord{Record: r, Name: r.ClientName, Phone: r.ClientPhone}
+		if !r.WalkIn() {
+			entry.Name, entry.Phone = s.users[r.UserId].name, s.users[r.UserId].number
+		}
+		for _, c := range s.storages {
+			if c.PickupRecordId == r.Id && c.CheckedOutAt == 0 {
+				entry.PickupRack = c.Rack
+				break
+			}
+		}
+		res = append(res, entry)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Datetime != res[j].Datetime {
+			return res[i].Datetime < res[j].Datetime
+		}
+		return res[i].Bay < res[j].Bay
+	})
+
+	return res, nil
+}
+
+func (s *Storage) Bookings(from, to, exclude, now int64) ([]db.Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.bookings(from, to, exclude, now), nil
+}
+
+func (s *Storage) DueReminders(now int64, before time.Duration) ([]db.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	offset := int64(before.Seconds())
+
+	var res []db.Record
+	for _, r := range s.records {
+		if r.WalkIn() || r.Status != db.StatusPending && r.Status != db.StatusConfirmed {
+			continue
+		}
+		if r.Datetime <= now || r.Datetime > now+offset || s.reminders[reminder{r.Id, before.Truncate(time.Minute)}] {
+			continue
+		}
+		if h := s.history[r.Id]; len(h) == 0 || h[0].ChangedAt > r.Datetime-offset {
+			continue
+		}
+		res = append(res, r)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Datetime < res[j].Datetime })
+
+	return res, nil
+}
+
+func (s *Storage) MarkReminderSent(recordId int64, before time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := reminder{recordId, before.Truncate(time.Minute)}
+	if s.reminders[key] {
+		return false, nil
+	}
+	s.reminders[key] = true
+
+	return true, nil
+}
+
+func (s *Storage) GetRole(userId int64) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.admins[userId].Role, nil
+}
+
+func (s *Storage) SetAdmin(userId int64, role string, addedBy int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.admins[userId] = db.Admin{UserId: userId, Role: role, AddedBy: addedBy, AddedAt: time.Now().Unix()}
+
+	return nil
+}
+
+func (s *Storage) RemoveAdmin(userId int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.admins[userId]
+	delete(s.admins, userId)
+
+	return ok, nil
+}
+
+func (s *Storage) Admins() ([]db.Admin, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	admins := make([]db.Admin, 0, len(s.admins))
+	for _, a := range s.admins {
+		admins = append(admins, a)
+	}
+	sort.Slice(admins, func(i, j int) bool {
+		if admins[i].AddedAt != admins[j].AddedAt {
+			return admins[i].AddedAt < admins[j].AddedAt
+		}
+		return admins[i].UserId < admins[j].UserId
+	})
+
+	return admins, nil
+}
+
+func (s *Storage) AddSlotBlock(start, end int64, bay int, reason string, createdBy int64) (db.SlotBlock, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastBlock++
+	block := db.SlotBlock{Id: s.lastBlock, Start: start, End: end, Bay: bay, Reason: reason}
+	s.blocks = append(s.blocks, block)
+
+	return block, nil
+}
+
+func (s *Storage) RemoveSlotBlock(id int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, b := range s.blocks {
+		if b.Id == id {
+			s.blocks = append(s.blocks[:i], s.blocks[i+1:]...)
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (s *Storage) SlotBlocks(from, to int64) ([]db.SlotBlock, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []db.SlotBlock
+	for _, b := range s.blocks {
+		if b.Start < to && b.End > from {
+			res = append(res, b)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Start != res[j].Start {
+			return res[i].Start < res[j].Start
+		}
+		return res[i].Bay < res[j].Bay
+	})
+
+	return res, nil
+}
+
+func (s *Storage) GetVehicles(userId int64) ([]db.Vehicle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []db.Vehicle
+	for id, v := range s.vehicles {
+		if v.UserId == userId && !s.removed[id] {
+			res = append(res, v)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Id < res[j].Id })
+
+	return res, nil
+}
+
+func (s *Storage) GetVehicle(id, userId int64) (db.Vehicle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.vehicles[id]
+	if !ok || v.UserId != userId || s.removed[id] {
+		return db.Vehicle{}, db.ErrNoVehicle
+	}
+
+	return v, nil
+}
+
+func (s *Storage) SaveVehicle(v db.Vehicle) (db.Vehicle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if v.Id == 0 {
+		s.lastVehicle++
+		v.Id = s.lastVehicle
+	} else if old, ok := s.vehicles[v.Id]; !ok || old.UserId != v.UserId || s.removed[v.Id] {
+		return db.Vehicle{}, db.ErrNoVehicle
+	}
+	s.vehicles[v.Id] = v
+
+	return v, nil
+}
+
+func (s *Storage) RemoveVehicle(id, userId int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.vehicles[id]
+	if !ok || v.UserId != userId || s.removed[id] {
+		return false, nil
+	}
+	s.removed[id] = true
+
+	return true, nil
+}
+
+// storageView дополняет договор клиентом и автомобилем, найденными по телефону.
+func (s *Storage) storageView(c db.StorageContract) db.StorageContract {
+	if c.UserId == 0 {
+		for id, u := range s.users {
+			if u.number == c.Phone {
+				c.UserId = id
+				break
+			}
+		}
+	}
+	if v, ok := s.vehicles[c.Vehicle.Id]; ok {
+		c.Vehicle = v
+	}
+
+	return c
+}
+
+func (s *Storage) AddStorageContract(c db.StorageContract, createdBy int64) (db.StorageContract, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastStorage++
+	c.Id, c.UserId, c.Vehicle = s.lastStorage, 0, db.Vehicle{}
+	c.CheckedOutAt, c.PickupRecordId = 0, 0
+	c = s.storageView(c)
+	if c.Plate != "" {
+		for id, v := range s.vehicles {
+			if c.UserId != 0 && v.UserId == c.UserId && v.Plate == c.Plate && !s.removed[id] {
+				c.Vehicle = v
+				break
+			}
+		}
+	}
+	s.storages = append(s.storages, c)
+
+	return c, nil
+}
+
+func (s *Storage) findStorage(id int64) (int, bool) {
+	for i, c := range s.storages {
+		if c.Id == id {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+func (s *Storage) GetStorageContract(id int64) (db.StorageContract, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.findStorage(id)
+	if !ok {
+		return db.StorageContract{}, db.ErrNoStorage
+	}
+
+	return s.storageView(s.storages[i]), nil
+}
+
+func (s *Storage) StorageContracts(userId int64) ([]db.StorageContract, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []db.StorageContract
+	for _, c := range s.storages {
+		if c = s.storageView(c); c.CheckedOutAt == 0 && c.UserId == userId {
+			res = append(res, c)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].StartsAt < res[j].StartsAt })
+
+	return res, nil
+}
+
+func (s *Storage) ActiveStorageContracts() ([]db.StorageContract, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []db.StorageContract
+	for _, c := range s.storages {
+		if c.CheckedOutAt == 0 {
+			res = append(res, s.storageView(c))
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].EndsAt < res[j].EndsAt })
+
+	return res, nil
+}
+
+func (s *Storage) CheckOutStorage(id int64) (db.StorageContract, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.findStorage(id)
+	if !ok || s.storages[i].CheckedOutAt != 0 {
+		return db.StorageContract{}, db.ErrNoStorage
+	}
+	s.storages[i].CheckedOutAt = time.Now().Unix()
+
+	return s.storageView(s.storages[i]), nil
+}
+
+func (s *Storage) LinkStoragePickup(id, userId, recordId int64) (db.StorageContract, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.findStorage(id)
+	if !ok {
+		return db.StorageContract{}, db.ErrNoStorage
+	}
+	if c := s.storageView(s.storages[i]); c.CheckedOutAt != 0 || c.UserId != userId {
+		return db.StorageContract{}, db.ErrNoStorage
+	}
+	if _, ok := s.find(recordId, userId); !ok {
+		return db.StorageContract{}, db.ErrNoRecord
+	}
+	s.storages[i].PickupRecordId = recordId
+
+	return s.storageView(s.storages[i]), nil
+}
+
+func (s *Storage) AudienceUsers(audience string, seasonStart int64) ([]int64, error) {
+	if !db.ValidAudience(audience) {
+		return nil, fmt.Errorf("unknown audience %q", audience)
+	}
+	var storages []db.StorageContract
+	if audience == db.AudienceStorage {
+		var err error
+		if storages, err = s.ActiveStorageContracts(); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []int64
+	for userId := range s.users {
+		switch audience {
+		case db.AudienceStorage:
+			stored := false
+			for _, c := range storages {
+				stored = stored || c.UserId == userId
+			}
+			if !stored {
+				continue
+			}
+		case db.AudienceNotBooked:
+			booked := false
+			for _, r := range s.records {
+				booked = booked || r.UserId == userId && r.Datetime >= seasonStart && active(r)
+			}
+			if booked {
+				continue
+			}
+		}
+		res = append(res, userId)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+
+	return res, nil
+}
+
+func (s *Storage) CreateBroadcast(b db.Broadcast, recipients []int64) (db.Broadcast, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b.Id = int64(len(s.broadcasts) + 1)
+	b.CreatedAt, b.FinishedAt = time.Now().Unix(), 0
+	s.broadcasts = append(s.broadcasts, b)
+	s.deliveries[b.Id] = make(map[int64]string)
+	for _, userId := range recipients {
+		s.deliveries[b.Id][userId] = db.DeliveryPending
+	}
+
+	return b, nil
+}
+
+func (s *Storage) UnfinishedBroadcasts() ([]db.Broadcast, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []db.Broadcast
+	for _, b := range s.broadcasts {
+		if b.FinishedAt == 0 {
+			res = append(res, b)
+		}
+	}
+
+	return res, nil
+}
+
+func (s *Storage) PendingRecipients(id int64) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []int64
+	for userId, status := range s.deliveries[id] {
+		if status == db.DeliveryPending {
+			res = append(res, userId)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+
+	return res, nil
+}
+
+func (s *Storage) SetDeliveryStatus(id, userId int64, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.deliveries[id][userId]; ok {
+		s.deliveries[id][userId] = status
+	}
+
+	return nil
+}
+
+func (s *Storage) FinishBroadcast(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id >= 1 && id <= int64(len(s.broadcasts)) {
+		s.broadcasts[id-1].FinishedAt = time.Now().Unix()
+	}
+
+	return nil
+}
+
+func (s *Storage) BroadcastStats(id int64) (db.BroadcastStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var stats db.BroadcastStats
+	for _, status := range s.deliveries[id] {
+		switch status {
+		case db.DeliveryPending:
+			stats.Pending++
+		case db.DeliveryDelivered:
+			stats.Delivered++
+		case db.DeliveryBlocked:
+			stats.Blocked++
+		case db.DeliveryFailed:
+			stats.Failed++
+		}
+	}
+
+	return stats, nil
+}
+
+func waitlistActive(e db.WaitlistEntry) bool {
+	return e.Status == db.WaitlistWaiting || e.Status == db.WaitlistOffered
+}
+
+func (s *Storage) AddWaitlist(e db.WaitlistEntry, now int64) (db.WaitlistEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.waitlist {
+		if existing.UserId == e.UserId && existing.Day == e.Day && existing.From == e.From && existing.To == e.To && waitlistActive(existing) {
+			return existing, nil
+		}
+	}
+
+	e.Id = int64(len(s.waitlist) + 1)
+	e.Status, e.OfferDatetime, e.OfferExpiresAt, e.MissedOffers = db.WaitlistWaiting, 0, 0, 0
+	s.waitlist = append(s.waitlist, e)
+	s.queuedAt[e.Id] = now
+
+	return e, nil
+}
+
+func (s *Storage) Waitlist() ([]db.WaitlistEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []db.WaitlistEntry
+	for _, e := range s.waitlist {
+		if waitlistActive(e) {
+			res = append(res, e)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return s.queuedAt[res[i].Id] < s.queuedAt[res[j].Id] })
+
+	return res, nil
+}
+
+// entry возвращает заявку id; ok = false, если её нет.
+func (s *Storage) entry(id int64) (*db.WaitlistEntry, bool) {
+	if id < 1 || id > int64(len(s.waitlist)) {
+		return nil, false
+	}
+
+	return &s.waitlist[id-1], true
+}
+
+func (s *Storage) OfferWaitlist(id, datetime, expiresAt int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entry(id)
+	if !ok || e.Status != db.WaitlistWaiting {
+		return db.ErrNoWaitlist
+	}
+	e.Status, e.OfferDatetime, e.OfferExpiresAt = db.WaitlistOffered, datetime, expiresAt
+
+	return nil
+}
+
+func (s *Storage) ClaimWaitlist(id, userId, now int64, bays int) (db.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entry(id)
+	if !ok || e.UserId != userId || e.Status != db.WaitlistOffered || e.OfferExpiresAt <= now {
+		return db.Record{}, db.ErrNoWaitlist
+	}
+	// Предложение больше не держит время, иначе заняло бы пост само у себя.
+	e.Status = db.WaitlistClaimed
+
+	vehicleId := e.VehicleId
+	if v, ok := s.vehicles[vehicleId]; !ok || v.UserId != userId || s.removed[vehicleId] {
+		vehicleId = 0
+	}
+	record, err := s.insertRecord(db.Record{
+		UserId:    userId,
+		Datetime:  e.OfferDatetime,
+		ServiceId: e.ServiceId,
+		Duration:  e.Duration,
+		Status:    db.StatusPending,
+		Vehicle:   db.Vehicle{Id: vehicleId, UserId: userId},
+	}, bays, now)
+	if err != nil {
+		e.Status = db.WaitlistOffered
+		return db.Record{}, err
+	}
+
+	return record, nil
+}
+
+func (s *Storage) RequeueWaitlist(id, now int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entry(id); ok && e.Status == db.WaitlistOffered {
+		e.Status, e.OfferDatetime, e.OfferExpiresAt = db.WaitlistWaiting, 0, 0
+		e.MissedOffers++
+		s.queuedAt[id] = now
+	}
+
+	return nil
+}
+
+func (s *Storage) SetWaitlistStatus(id int64, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entry(id); ok {
+		e.Status = status
+		if status == db.WaitlistWaiting {
+			e.OfferDatetime, e.OfferExpiresAt = 0, 0
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) CancelWaitlist(id, userId int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entry(id)
+	if !ok || e.UserId != userId || !waitlistActive(*e) {
+		return false, nil
+	}
+	e.Status = db.WaitlistCancelled
+
+	return true, nil
+}
+
+func (s *Storage) AddClosedDay(day int64, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closedDays[day] = reason
+
+	return nil
+}
+
+func (s *Storage) RemoveClosedDay(day int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.closedDays[day]
+	delete(s.closedDays, day)
+
+	return ok, nil
+}
+
+func (s *Storage) GetClosedDays(from, to int64) ([]db.ClosedDay, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var days []db.ClosedDay
+	for day, reason := range s.closedDays {
+		if day >= from && day < to {
+			days = append(days, db.ClosedDay{Day: day, Reason: reason})
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Day < days[j].Day })
+
+	return days, nil
+}
+
+func (s *Storage) GetServices() ([]db.Service, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]db.Service(nil), s.services...), nil
+}
+
+func (s *Storage) GetService(id int64) (db.Service, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, service := range s.services {
+		if service.Id == id {
+			return service, nil
+		}
+	}
+
+	return db.Service{}, fmt.Errorf("failed to get service: %w", sql.ErrNoRows)
+}
+
+func (s *Storage) GetPrices() ([]db.Price, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]db.Price(nil), s.prices...), nil
+}
+
+func (s *Storage) GetFileId(key string) (string, int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.files[key]
+
+	return f.fileId, f.modTime, ok, nil
+}
+
+func (s *Storage) SaveFileId(key, fileId string, modTime int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.files[key] = file{fileId: fileId, modTime: modTime}
+
+	return nil
+}
+
+func (s *Storage) SetSession(key string, value any, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode session data: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[key] = session{value: data, expiresAt: time.Now().Add(ttl)}
+
+	return nil
+}
+
+func (s *Storage) GetSession(key string, dst any) (bool, error) {
+	s.mu.Lock()
+	sess, ok := s.sessions[key]
+	s.mu.Unlock()
+
+	if !ok || !sess.expiresAt.After(time.Now()) {
+		return false, nil
+	}
+	if err := json.Unmarshal(sess.value, dst); err != nil {
+		return false, fmt.Errorf("failed to decode session data: %w", err)
+	}
+
+	return true, nil
+}
+
+func (s *Storage) DeleteExpiredSessions() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, sess := range s.sessions {
+		if !sess.expiresAt.After(now) {
+			delete(s.sessions, key)
+		}
+	}
+
+	return nil
+}
+
+// ConversationStorage хранит состояния бесед в памяти; ttl не используется,
+// так как они и так не переживают перезапуск.
+func (s *Storage) ConversationStorage(name string, strategy conversation.KeyStrategy, ttl time.Duration) conversation.Storage {
+	return conversation.NewInMemoryStorage(strategy)
+}
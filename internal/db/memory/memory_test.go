@@ -0,0 +1,86 @@
+package memory_test
+
+import (
+	"automobile36/internal/db"
+	"automobile36/internal/db/dbtest"
+	"automobile36/internal/db/memory"
+	"automobile36/internal/schedule"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// scenario проходит по s сценарий записи, переноса и листа ожидания и описывает
+// каждый результат строкой, чтобы сравнить хранилища между собой.
+func scenario(s db.Storage) []string {
+	var log []string
+	logf := func(format string, args ...any) {
+		log = append(log, fmt.Sprintf(format, args...))
+	}
+	record := func(r db.Record, err error) {
+		logf("record id=%d at=%d bay=%d status=%s err=%v", r.Id, r.Datetime, r.Bay, r.Status, err)
+	}
+
+	day := time.Date(2026, 10, 19, 0, 0, 0, 0, dbtest.Location).Unix()
+	now := day - 12*3600
+	at := day + 9*3600
+
+	first, err := s.SaveRecord(1, 0, at, 0, time.Hour, 2, now)
+	record(first, err)
+	record(s.SaveRecord(2, 0, at+1800, 0, time.Hour, 2, now))
+	record(s.SaveRecord(3, 0, at, 0, time.Hour, 2, now))
+	record(s.SetStatus(first.Id, db.StatusConfirmed))
+	record(s.SetStatus(first.Id, db.StatusDone))
+
+	bay, err := s.MoveRecord(first.Id, 1, at+3*3600, 2, now)
+	logf("move bay=%d err=%v", bay, err)
+	record(s.GetRecordById(first.Id))
+	logf("cancel err=%v", s.CancelRecord(first.Id, 2))
+
+	e, err := s.AddWaitlist(db.WaitlistEntry{UserId: 4, Day: day, To: schedule.Clock(24 * time.Hour), Duration: time.Hour}, now)
+	logf("waitlist id=%d err=%v", e.Id, err)
+	again, err := s.AddWaitlist(db.WaitlistEntry{UserId: 4, Day: day, To: schedule.Clock(24 * time.Hour), Duration: time.Hour}, now)
+	logf("waitlist again id=%d err=%v", again.Id, err)
+	logf("offer err=%v", s.OfferWaitlist(e.Id, at, now+1800))
+	logf("offer twice err=%v", s.OfferWaitlist(e.Id, at, now+1800))
+	record(s.SaveRecord(5, 0, at, 0, time.Hour, 2, now))
+	record(s.ClaimWaitlist(e.Id, 4, now+1800, 2))
+	logf("requeue err=%v", s.RequeueWaitlist(e.Id, now+1800))
+	entries, err := s.Waitlist()
+	logf("waitlist %+v err=%v", entries, err)
+
+	busy, err := s.Bookings(day, day+86400, 0, now)
+	// Порядок занятости не задан.
+	sort.Slice(busy, func(i, j int) bool { return busy[i].Start < busy[j].Start })
+	logf("bookings %v err=%v", busy, err)
+	records, err := s.GetAllRecords(1, now)
+	for _, r := range records {
+		record(r, nil)
+	}
+	logf("all records err=%v", err)
+
+	return log
+}
+
+// TestMirrorsStore проверяет, что хранилище в памяти ведёт себя как база.
+func TestMirrorsStore(t *testing.T) {
+	want := scenario(dbtest.SQLite(t))
+	got := scenario(memory.New())
+
+	if !reflect.DeepEqual(got, want) {
+		for i := 0; i < len(got) || i < len(want); i++ {
+			var g, w string
+			if i < len(got) {
+				g = got[i]
+			}
+			if i < len(want) {
+				w = want[i]
+			}
+			if g != w {
+				t.Errorf("step %d:\nmemory: %s\nsqlite: %s", i, g, w)
+			}
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package db
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Миграции лежат в migrations/<диалект>/ парами NNNN_name.up.sql и NNNN_name.down.sql.
+//
+//go:embed migrations
+var migrationsFS embed.FS
+
+type migration struct {
+	version int
+	name    string
+	up      string
+	down    string
+}
+
+// loadMigrations читает миграции из каталога dir и сортирует их по версии.
+func loadMigrations(dir string) ([]migration, error) {
+	entries, err := fs.ReadDir(migrationsFS, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*migration)
+	for _, e := range entries {
+		file := e.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(file, ".sql"), ".")
+		if !ok || !strings.HasSuffix(file, ".sql") || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("unexpected migration file %q", file)
+		}
+		number, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("unexpected migration file %q", file)
+		}
+		version, err := strconv.Atoi(number)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("unexpected migration version in %q", file)
+		}
+
+		data, err := fs.ReadFile(migrationsFS, path.Join(dir, file))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %q: %w", file, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version, name: name}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.up = string(data)
+		} else {
+			m.down = string(data)
+		}
+	}
+
+	res := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("migration %d has no up script", m.version)
+		}
+		res = append(res, *m)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].version < res[j].version })
+
+	return res, nil
+}
+
+func (s *Store) migrations() ([]migration, error) {
+	return loadMigrations("migrations/" + s.db.dialect)
+}
+
+func (s *Store) tableExists(name string) (bool, error) {
+	q := `SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name=?`
+	if s.db.dialect == DialectPostgres {
+		q = `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema=current_schema() AND table_name=?`
+	}
+
+	var count int
+	err := s.db.QueryRow(q, name).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check table %s: %w", name, err)
+	}
+
+	return count > 0, nil
+}
+
+// SchemaVersion возвращает номер последней применённой миграции.
+func (s *Store) SchemaVersion() (int, error) {
+	exists, err := s.tableExists("schema_version")
+	if err != nil || !exists {
+		return 0, err
+	}
+
+	var version int
+	err = s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	return version, nil
+}
+
+// Migrate применяет все ещё не применённые миграции. Каждая миграция выполняется
+// в своей транзакции; при ошибке применение останавливается.
+func (s *Store) Migrate() error {
+	versioned, err := s.tableExists("schema_version")
+	if err != nil {
+		return err
+	}
+	if !versioned && s.db.dialect == DialectSQLite {
+		// База, созданная до появления миграций, сначала доводится до схемы первой миграции.
+		if err := s.upgradeLegacySchema(); err != nil {
+			return fmt.Errorf("failed to upgrade legacy schema: %w", err)
+		}
+	}
+
+	q := `CREATE TABLE IF NOT EXISTS schema_version (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at BIGINT NOT NULL)`
+	if _, err := s.db.Exec(q); err != nil {
+		return fmt.Errorf("failed to create schema_version: %w", err)
+	}
+
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	migrations, err := s.migrations()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := s.applyMigration(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) applyMigration(m migration) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.up); err != nil {
+		return fmt.Errorf("failed to apply migration %04d_%s: %w", m.version, m.name, err)
+	}
+	q := `INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)`
+	if _, err := tx.Exec(q, m.version, m.name, time.Now().Unix()); err != nil {
+		return fmt.Errorf("failed to record migration %04d_%s: %w", m.version, m.name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %04d_%s: %w", m.version, m.name, err)
+	}
+
+	return nil
+}
+
+// Rollback откатывает steps последних применённых миграций.
+func (s *Store) Rollback(steps int) error {
+	migrations, err := s.migrations()
+	if err != nil {
+		return err
+	}
+
+	for ; steps > 0; steps-- {
+		current, err := s.SchemaVersion()
+		if err != nil {
+			return err
+		}
+		if current == 0 {
+			return nil
+		}
+
+		i := sort.Search(len(migrations), func(i int) bool { return migrations[i].version >= current })
+		if i == len(migrations) || migrations[i].version != current {
+			return fmt.Errorf("migration %d is applied but unknown to this build", current)
+		}
+		if err := s.revertMigration(migrations[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) revertMigration(m migration) error {
+	if m.down == "" {
+		return fmt.Errorf("migration %04d_%s cannot be rolled back", m.version, m.name)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.down); err != nil {
+		return fmt.Errorf("failed to roll back migration %04d_%s: %w", m.version, m.name, err)
+	}
+	if _, err := tx.Exec(`DELETE FROM schema_version WHERE version=?`, m.version); err != nil {
+		return fmt.Errorf("failed to record rollback of %04d_%s: %w", m.version, m.name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rollback of %04d_%s: %w", m.version, m.name, err)
+	}
+
+	return nil
+}
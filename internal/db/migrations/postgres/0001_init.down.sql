@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS conversations;
+DROP TABLE IF EXISTS session_data;
+DROP TABLE IF EXISTS telegram_files;
+DROP TABLE IF EXISTS service_prices;
+DROP TABLE IF EXISTS services;
+DROP TABLE IF EXISTS closed_days;
+DROP TABLE IF EXISTS records;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,59 @@
+CREATE TABLE users (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT,
+    name TEXT,
+    phone_number BIGINT
+);
+
+CREATE TABLE records (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT,
+    datetime BIGINT,
+    bay INTEGER NOT NULL DEFAULT 1,
+    service_id BIGINT,
+    duration INTEGER NOT NULL DEFAULT 90,
+    status TEXT NOT NULL DEFAULT 'pending'
+);
+
+-- Один пост не может быть занят двумя записями с одного и того же времени.
+CREATE UNIQUE INDEX records_datetime_bay ON records (datetime, bay) WHERE status NOT IN ('cancelled_by_client');
+
+CREATE TABLE closed_days (
+    day BIGINT PRIMARY KEY,
+    reason TEXT
+);
+
+CREATE TABLE services (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT,
+    category TEXT,
+    price INTEGER,
+    duration INTEGER
+);
+
+CREATE TABLE service_prices (
+    service_id BIGINT,
+    radius INTEGER,
+    price INTEGER,
+    PRIMARY KEY (service_id, radius)
+);
+
+CREATE TABLE telegram_files (
+    key TEXT PRIMARY KEY,
+    file_id TEXT,
+    mod_time BIGINT
+);
+
+CREATE TABLE session_data (
+    key TEXT PRIMARY KEY,
+    value TEXT,
+    expires_at BIGINT
+);
+
+CREATE TABLE conversations (
+    name TEXT,
+    key TEXT,
+    state TEXT,
+    expires_at BIGINT,
+    PRIMARY KEY (name, key)
+);
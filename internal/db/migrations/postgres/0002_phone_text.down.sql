@@ -0,0 +1,2 @@
+ALTER TABLE users ALTER COLUMN phone_number TYPE BIGINT USING
+    NULLIF(regexp_replace(phone_number, '\D', '', 'g'), '')::BIGINT;
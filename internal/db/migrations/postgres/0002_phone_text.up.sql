@@ -0,0 +1,10 @@
+-- Номера хранились числом: терялись ведущие нули и "+". Переводим их в текст
+-- в формате E.164; номера, которые не удалось распознать, сохраняются как есть.
+ALTER TABLE users ALTER COLUMN phone_number TYPE TEXT USING
+    CASE
+        WHEN phone_number IS NULL THEN NULL
+        WHEN length(phone_number::TEXT) = 11 AND substr(phone_number::TEXT, 1, 1) IN ('7', '8')
+            THEN '+7' || substr(phone_number::TEXT, 2)
+        WHEN length(phone_number::TEXT) = 10 THEN '+7' || phone_number::TEXT
+        ELSE phone_number::TEXT
+    END;
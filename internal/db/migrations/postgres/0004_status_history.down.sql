@@ -0,0 +1 @@
+DROP TABLE record_status_history;
@@ -0,0 +1 @@
+DROP TABLE reminders_sent;
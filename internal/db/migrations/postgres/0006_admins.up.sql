@@ -0,0 +1,6 @@
+CREATE TABLE admins (
+    user_id BIGINT PRIMARY KEY,
+    role TEXT NOT NULL,
+    added_by BIGINT NOT NULL,
+    added_at BIGINT NOT NULL
+);
@@ -0,0 +1,16 @@
+CREATE TABLE slot_blocks (
+    id BIGSERIAL PRIMARY KEY,
+    start_at BIGINT NOT NULL,
+    end_at BIGINT NOT NULL,
+    -- 0 - все посты.
+    bay INTEGER NOT NULL DEFAULT 0,
+    reason TEXT NOT NULL DEFAULT '',
+    created_by BIGINT NOT NULL,
+    created_at BIGINT NOT NULL
+);
+
+CREATE INDEX slot_blocks_start_at ON slot_blocks (start_at);
+
+-- Записи, созданные сотрудниками за клиента без Telegram: user_id = 0, контакты хранятся в записи.
+ALTER TABLE records ADD COLUMN client_name TEXT;
+ALTER TABLE records ADD COLUMN client_phone TEXT;
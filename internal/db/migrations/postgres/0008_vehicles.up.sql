@@ -0,0 +1,15 @@
+CREATE TABLE vehicles (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    make TEXT NOT NULL,
+    model TEXT NOT NULL,
+    plate TEXT NOT NULL DEFAULT '',
+    tire_size TEXT NOT NULL DEFAULT '',
+    rim_type TEXT NOT NULL DEFAULT '',
+    -- Удалённый клиентом автомобиль остаётся в прошлых записях.
+    removed_at BIGINT
+);
+
+CREATE INDEX vehicles_user_id ON vehicles (user_id);
+
+ALTER TABLE records ADD COLUMN vehicle_id BIGINT;
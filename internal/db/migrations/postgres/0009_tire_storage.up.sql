@@ -0,0 +1,19 @@
+CREATE TABLE tire_storage (
+    id BIGSERIAL PRIMARY KEY,
+    -- 0, если клиента с этим телефоном на момент приёма не было в боте.
+    user_id BIGINT NOT NULL DEFAULT 0,
+    phone TEXT NOT NULL,
+    vehicle_id BIGINT,
+    plate TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL,
+    rack TEXT NOT NULL,
+    starts_at BIGINT NOT NULL,
+    ends_at BIGINT NOT NULL,
+    price INTEGER NOT NULL,
+    checked_out_at BIGINT,
+    pickup_record_id BIGINT,
+    created_by BIGINT NOT NULL,
+    created_at BIGINT NOT NULL
+);
+
+CREATE INDEX tire_storage_phone ON tire_storage (phone);
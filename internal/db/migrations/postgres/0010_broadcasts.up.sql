@@ -0,0 +1,19 @@
+CREATE TABLE broadcasts (
+    id BIGSERIAL PRIMARY KEY,
+    text TEXT NOT NULL,
+    -- Telegram file_id фото, пустая строка у текстовой рассылки.
+    photo_id TEXT NOT NULL DEFAULT '',
+    audience TEXT NOT NULL,
+    created_by BIGINT NOT NULL,
+    created_at BIGINT NOT NULL,
+    finished_at BIGINT
+);
+
+CREATE TABLE broadcast_deliveries (
+    broadcast_id BIGINT NOT NULL REFERENCES broadcasts (id),
+    user_id BIGINT NOT NULL,
+    -- pending, delivered, blocked (бот заблокирован) или failed.
+    status TEXT NOT NULL,
+    sent_at BIGINT,
+    PRIMARY KEY (broadcast_id, user_id)
+);
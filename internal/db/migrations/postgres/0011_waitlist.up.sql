@@ -0,0 +1,19 @@
+CREATE TABLE waitlist (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    -- Полночь дня по часам мастерской.
+    day BIGINT NOT NULL,
+    -- Окно начала в минутах от полуночи: [window_from, window_to).
+    window_from INTEGER NOT NULL,
+    window_to INTEGER NOT NULL,
+    service_id BIGINT NOT NULL,
+    duration INTEGER NOT NULL,
+    vehicle_id BIGINT,
+    -- waiting, offered, claimed, expired или cancelled.
+    status TEXT NOT NULL,
+    offer_datetime BIGINT,
+    offer_expires_at BIGINT,
+    created_at BIGINT NOT NULL
+);
+
+CREATE INDEX waitlist_status ON waitlist (status);
@@ -0,0 +1,2 @@
+ALTER TABLE waitlist DROP COLUMN missed_offers;
+ALTER TABLE waitlist DROP COLUMN queued_at;
@@ -0,0 +1,59 @@
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER,
+    name TEXT,
+    phone_number INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS records (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER,
+    datetime INTEGER,
+    bay INTEGER NOT NULL DEFAULT 1,
+    service_id INTEGER,
+    duration INTEGER NOT NULL DEFAULT 90,
+    status TEXT NOT NULL DEFAULT 'pending'
+);
+
+-- Один пост не может быть занят двумя записями с одного и того же времени.
+CREATE UNIQUE INDEX IF NOT EXISTS records_datetime_bay ON records (datetime, bay) WHERE status NOT IN ('cancelled_by_client');
+
+CREATE TABLE IF NOT EXISTS closed_days (
+    day INTEGER PRIMARY KEY,
+    reason TEXT
+);
+
+CREATE TABLE IF NOT EXISTS services (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT,
+    category TEXT,
+    price INTEGER,
+    duration INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS service_prices (
+    service_id INTEGER,
+    radius INTEGER,
+    price INTEGER,
+    PRIMARY KEY (service_id, radius)
+);
+
+CREATE TABLE IF NOT EXISTS telegram_files (
+    key TEXT PRIMARY KEY,
+    file_id TEXT,
+    mod_time INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS session_data (
+    key TEXT PRIMARY KEY,
+    value TEXT,
+    expires_at INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS conversations (
+    name TEXT,
+    key TEXT,
+    state TEXT,
+    expires_at INTEGER,
+    PRIMARY KEY (name, key)
+);
@@ -0,0 +1,13 @@
+CREATE TABLE users_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER,
+    name TEXT,
+    phone_number INTEGER
+);
+
+INSERT INTO users_old (id, user_id, name, phone_number)
+SELECT id, user_id, name, CAST(replace(phone_number, '+', '') AS INTEGER)
+FROM users;
+
+DROP TABLE users;
+ALTER TABLE users_old RENAME TO users;
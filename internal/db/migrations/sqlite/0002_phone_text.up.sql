@@ -0,0 +1,22 @@
+-- Номера хранились числом: терялись ведущие нули и "+". Переводим их в текст
+-- в формате E.164; номера, которые не удалось распознать, сохраняются как есть.
+CREATE TABLE users_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER,
+    name TEXT,
+    phone_number TEXT
+);
+
+INSERT INTO users_new (id, user_id, name, phone_number)
+SELECT id, user_id, name,
+    CASE
+        WHEN phone_number IS NULL THEN NULL
+        WHEN length(CAST(phone_number AS TEXT)) = 11 AND substr(CAST(phone_number AS TEXT), 1, 1) IN ('7', '8')
+            THEN '+7' || substr(CAST(phone_number AS TEXT), 2)
+        WHEN length(CAST(phone_number AS TEXT)) = 10 THEN '+7' || CAST(phone_number AS TEXT)
+        ELSE CAST(phone_number AS TEXT)
+    END
+FROM users;
+
+DROP TABLE users;
+ALTER TABLE users_new RENAME TO users;
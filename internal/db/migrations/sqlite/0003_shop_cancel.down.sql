@@ -0,0 +1,2 @@
+DROP INDEX records_datetime_bay;
+CREATE UNIQUE INDEX records_datetime_bay ON records (datetime, bay) WHERE status NOT IN ('cancelled_by_client');
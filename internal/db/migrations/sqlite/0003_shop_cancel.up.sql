@@ -0,0 +1,3 @@
+-- Записи, отклонённые мастерской, освобождают пост так же, как отменённые клиентом.
+DROP INDEX records_datetime_bay;
+CREATE UNIQUE INDEX records_datetime_bay ON records (datetime, bay) WHERE status NOT IN ('cancelled_by_client', 'cancelled_by_shop');
@@ -0,0 +1,12 @@
+CREATE TABLE record_status_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    record_id INTEGER NOT NULL,
+    status TEXT NOT NULL,
+    changed_at INTEGER NOT NULL
+);
+
+CREATE INDEX record_status_history_record_id ON record_status_history (record_id);
+
+-- Для существующих записей момент перехода неизвестен, отмечаем текущий статус временем миграции.
+INSERT INTO record_status_history (record_id, status, changed_at)
+SELECT id, status, CAST(strftime('%s', 'now') AS INTEGER) FROM records;
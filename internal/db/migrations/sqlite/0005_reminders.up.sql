@@ -0,0 +1,6 @@
+CREATE TABLE reminders_sent (
+    record_id INTEGER NOT NULL,
+    offset_minutes INTEGER NOT NULL,
+    sent_at INTEGER NOT NULL,
+    PRIMARY KEY (record_id, offset_minutes)
+);
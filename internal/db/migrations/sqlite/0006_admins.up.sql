@@ -0,0 +1,6 @@
+CREATE TABLE admins (
+    user_id INTEGER PRIMARY KEY,
+    role TEXT NOT NULL,
+    added_by INTEGER NOT NULL,
+    added_at INTEGER NOT NULL
+);
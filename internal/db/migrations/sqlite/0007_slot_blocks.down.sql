@@ -0,0 +1,4 @@
+ALTER TABLE records DROP COLUMN client_phone;
+ALTER TABLE records DROP COLUMN client_name;
+
+DROP TABLE slot_blocks;
@@ -0,0 +1,3 @@
+ALTER TABLE records DROP COLUMN vehicle_id;
+
+DROP TABLE vehicles;
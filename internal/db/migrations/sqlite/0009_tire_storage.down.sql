@@ -0,0 +1 @@
+DROP TABLE tire_storage;
@@ -0,0 +1,19 @@
+CREATE TABLE tire_storage (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    -- 0, если клиента с этим телефоном на момент приёма не было в боте.
+    user_id INTEGER NOT NULL DEFAULT 0,
+    phone TEXT NOT NULL,
+    vehicle_id INTEGER,
+    plate TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL,
+    rack TEXT NOT NULL,
+    starts_at INTEGER NOT NULL,
+    ends_at INTEGER NOT NULL,
+    price INTEGER NOT NULL,
+    checked_out_at INTEGER,
+    pickup_record_id INTEGER,
+    created_by INTEGER NOT NULL,
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX tire_storage_phone ON tire_storage (phone);
@@ -0,0 +1,19 @@
+CREATE TABLE broadcasts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    text TEXT NOT NULL,
+    -- Telegram file_id фото, пустая строка у текстовой рассылки.
+    photo_id TEXT NOT NULL DEFAULT '',
+    audience TEXT NOT NULL,
+    created_by INTEGER NOT NULL,
+    created_at INTEGER NOT NULL,
+    finished_at INTEGER
+);
+
+CREATE TABLE broadcast_deliveries (
+    broadcast_id INTEGER NOT NULL REFERENCES broadcasts (id),
+    user_id INTEGER NOT NULL,
+    -- pending, delivered, blocked (бот заблокирован) или failed.
+    status TEXT NOT NULL,
+    sent_at INTEGER,
+    PRIMARY KEY (broadcast_id, user_id)
+);
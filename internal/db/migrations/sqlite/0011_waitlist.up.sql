@@ -0,0 +1,19 @@
+CREATE TABLE waitlist (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    -- Полночь дня по часам мастерской.
+    day INTEGER NOT NULL,
+    -- Окно начала в минутах от полуночи: [window_from, window_to).
+    window_from INTEGER NOT NULL,
+    window_to INTEGER NOT NULL,
+    service_id INTEGER NOT NULL,
+    duration INTEGER NOT NULL,
+    vehicle_id INTEGER,
+    -- waiting, offered, claimed, expired или cancelled.
+    status TEXT NOT NULL,
+    offer_datetime INTEGER,
+    offer_expires_at INTEGER,
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX waitlist_status ON waitlist (status);
@@ -0,0 +1,4 @@
+-- Место в очереди: заявка, пропустившая предложение, встаёт в конец.
+ALTER TABLE waitlist ADD COLUMN queued_at INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE waitlist ADD COLUMN missed_offers INTEGER NOT NULL DEFAULT 0;
+UPDATE waitlist SET queued_at = created_at;
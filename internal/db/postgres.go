@@ -0,0 +1,36 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// OpenPostgres подключается к PostgreSQL по строке подключения dsn, не применяя миграций.
+func OpenPostgres(dsn string, loc *time.Location) (*Store, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	return &Store{db: &conn{DB: db, dialect: DialectPostgres}, location: loc}, nil
+}
+
+// NewPostgres подключается к PostgreSQL, применяет миграции и заполняет пустой каталог услуг.
+func NewPostgres(dsn string, loc *time.Location) (*Store, error) {
+	s, err := OpenPostgres(dsn, loc)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.prepare(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
@@ -0,0 +1,115 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Радиусы дисков, для которых ведутся цены.
+const (
+	MinRadius = 13
+	MaxRadius = 22
+)
+
+// Price - цена услуги для дисков радиуса Radius.
+type Price struct {
+	ServiceId int64
+	Radius    int
+	Price     int
+}
+
+// DefaultRadiusPrices - цена для R13 и шаг на каждый следующий радиус для услуг по умолчанию.
+var DefaultRadiusPrices = map[string][2]int{
+	"Сезонная замена шин": {1600, 200},
+	"Балансировка":        {600, 100},
+}
+
+// seedPrices заполняет цены по радиусам для услуг по умолчанию, если цен ещё нет.
+func (s *Store) seedPrices() error {
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM service_prices`).Scan(&count); err != nil {
+		return fmt.Errorf("failed to count prices: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	services, err := s.GetServices()
+	if err != nil {
+		return err
+	}
+
+	q := `INSERT INTO service_prices (service_id, radius, price) VALUES (?, ?, ?)`
+	for _, service := range services {
+		p, ok := DefaultRadiusPrices[service.Name]
+		if !ok {
+			continue
+		}
+		for r := MinRadius; r <= MaxRadius; r++ {
+			if _, err := s.db.Exec(q, service.Id, r, p[0]+(r-MinRadius)*p[1]); err != nil {
+				return fmt.Errorf("failed to save price: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) GetPrices() ([]Price, error) {
+	q := `SELECT service_id, radius, price FROM service_prices ORDER BY service_id, radius`
+
+	rows, err := s.db.Query(q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prices: %w", err)
+	}
+	defer rows.Close()
+
+	var prices []Price
+	for rows.Next() {
+		var p Price
+		err = rows.Scan(&p.ServiceId, &p.Radius, &p.Price)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		prices = append(prices, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get prices: %w", err)
+	}
+
+	return prices, nil
+}
+
+// GetFileId возвращает file_id, под которым Telegram хранит ранее загруженный файл key,
+// и время изменения файла на момент загрузки.
+func (s *Store) GetFileId(key string) (string, int64, bool, error) {
+	q := `SELECT file_id, mod_time FROM telegram_files WHERE key=?`
+
+	var (
+		fileId  string
+		modTime int64
+	)
+	err := s.db.QueryRow(q, key).Scan(&fileId, &modTime)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", 0, false, nil
+	}
+	if err != nil {
+		return "", 0, false, fmt.Errorf("failed to get file id: %w", err)
+	}
+
+	return fileId, modTime, true, nil
+}
+
+func (s *Store) SaveFileId(key, fileId string, modTime int64) error {
+	q := `INSERT INTO telegram_files (key, file_id, mod_time) VALUES (?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET file_id=excluded.file_id, mod_time=excluded.mod_time`
+
+	_, err := s.db.Exec(q, key, fileId, modTime)
+	if err != nil {
+		return fmt.Errorf("failed to save file id: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,367 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Статусы записи.
+const (
+	StatusPending           = "pending"
+	StatusConfirmed         = "confirmed"
+	StatusCancelledByClient = "cancelled_by_client"
+	StatusCancelledByShop   = "cancelled_by_shop"
+	StatusArrived           = "arrived"
+	StatusInProgress        = "in_progress"
+	StatusDone              = "done"
+	StatusNoShow            = "no_show"
+)
+
+// activeCondition отбирает записи, которые занимают пост.
+const activeCondition = "status NOT IN ('" + StatusCancelledByClient + "', '" + StatusCancelledByShop + "')"
+
+// transitions - разрешённые переходы между статусами. Отменённые, выполненные
+// записи и неявки - конечные состояния. Подтверждённая запись при переносе
+// снова ждёт подтверждения.
+var transitions = map[string][]string{
+	StatusPending:    {StatusConfirmed, StatusCancelledByClient, StatusCancelledByShop, StatusArrived, StatusNoShow},
+	StatusConfirmed:  {StatusPending, StatusCancelledByClient, StatusCancelledByShop, StatusArrived, StatusNoShow},
+	StatusArrived:    {StatusInProgress, StatusDone},
+	StatusInProgress: {StatusDone},
+}
+
+// CanTransition сообщает, можно ли перевести запись из статуса from в статус to.
+func CanTransition(from, to string) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Changeable сообщает, может ли клиент ещё отменить или перенести запись в статусе status.
+func Changeable(status string) bool {
+	return CanTransition(status, StatusCancelledByClient)
+}
+
+var (
+	ErrNoRecord      = errors.New("record not found")
+	ErrSlotTaken     = errors.New("slot is already taken")
+	ErrBadTransition = errors.New("status transition is not allowed")
+)
+
+func isUniqueViolation(err error) bool {
+	var (
+		sqliteErr sqlite3.Error
+		pqErr     *pq.Error
+	)
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+type Record struct {
+	Id          int64
+	UserId      int64
+	Datetime    int64
+	Bay         int
+	ServiceId   int64
+	ServiceName string
+	Duration    time.Duration
+	Status      string
+	// ClientName и ClientPhone заполнены у записей без Telegram (UserId = 0),
+	// которые сотрудники создали за клиента.
+	ClientName  string
+	ClientPhone string
+	// Vehicle - автомобиль, выбранный при записи; Id = 0, если не выбран.
+	Vehicle Vehicle
+}
+
+// WalkIn сообщает, что запись создана сотрудниками за клиента без Telegram.
+func (r Record) WalkIn() bool {
+	return r.UserId == 0
+}
+
+const recordColumns = `r.id, r.user_id, r.datetime, r.bay, COALESCE(r.service_id, 0), COALESCE(s.name, ''), r.duration, r.status,
+	COALESCE(r.client_name, ''), COALESCE(r.client_phone, ''),
+	COALESCE(v.id, 0), COALESCE(v.make, ''), COALESCE(v.model, ''), COALESCE(v.plate, ''), COALESCE(v.tire_size, ''), COALESCE(v.rim_type, '')`
+
+// recordJoins - таблицы, из которых recordColumns берёт название услуги и автомобиль.
+const recordJoins = ` FROM records r LEFT JOIN services s ON s.id = r.service_id LEFT JOIN vehicles v ON v.id = r.vehicle_id`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// recordFields - куда сканировать recordColumns. Длительность в минутах пишется в minutes.
+func recordFields(r *Record, minutes *int) []any {
+	return []any{
+		&r.Id, &r.UserId, &r.Datetime, &r.Bay, &r.ServiceId, &r.ServiceName, minutes, &r.Status, &r.ClientName, &r.ClientPhone,
+		&r.Vehicle.Id, &r.Vehicle.Make, &r.Vehicle.Model, &r.Vehicle.Plate, &r.Vehicle.TireSize, &r.Vehicle.RimType,
+	}
+}
+
+func scanRecord(row scanner) (Record, error) {
+	var (
+		r       Record
+		minutes int
+	)
+	err := row.Scan(recordFields(&r, &minutes)...)
+	if err != nil {
+		return Record{}, err
+	}
+	r.Duration = time.Duration(minutes) * time.Minute
+	r.Vehicle.UserId = r.UserId
+
+	return r, nil
+}
+
+// ScheduledRecord - запись вместе с контактами клиента для расписания сотрудников.
+type ScheduledRecord struct {
+	Record
+	Name  string
+	Phone string
+	// PickupRack - место на складе шин, которые клиент заберёт к этой записи.
+	PickupRack string
+}
+
+// StatusChange - момент, когда запись перешла в статус Status.
+type StatusChange struct {
+	Status    string
+	ChangedAt int64
+}
+
+// logStatus записывает переход записи id в статус status в историю.
+func logStatus(t *tx, id int64, status string) error {
+	q := `INSERT INTO record_status_history (record_id, status, changed_at) VALUES (?, ?, ?)`
+	if _, err := t.Exec(q, id, status, time.Now().Unix()); err != nil {
+		return fmt.Errorf("failed to save status history: %w", err)
+	}
+
+	return nil
+}
+
+// changeStatus переводит запись record в статус to, если переход разрешён.
+func changeStatus(t *tx, record *Record, to string) error {
+	if !CanTransition(record.Status, to) {
+		return ErrBadTransition
+	}
+	if _, err := t.Exec(`UPDATE records SET status=? WHERE id=?`, to, record.Id); err != nil {
+		return fmt.Errorf("failed to update status: %w", err)
+	}
+	record.Status = to
+
+	return logStatus(t, record.Id, to)
+}
+
+const recordQuery = `SELECT ` + recordColumns + recordJoins
+
+// GetRecordById возвращает запись id независимо от того, кому она принадлежит.
+func (s *Store) GetRecordById(id int64) (Record, error) {
+	record, err := scanRecord(s.db.QueryRow(recordQuery+` WHERE r.id=?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Record{}, ErrNoRecord
+	}
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to get record: %w", err)
+	}
+
+	return record, nil
+}
+
+// SetStatus переводит запись id в статус status, если такой переход разрешён,
+// и возвращает обновлённую запись.
+func (s *Store) SetStatus(id int64, status string) (Record, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	record, err := scanRecord(tx.QueryRow(recordQuery+` WHERE r.id=?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Record{}, ErrNoRecord
+	}
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to get record: %w", err)
+	}
+	if err := changeStatus(tx, &record, status); err != nil {
+		return Record{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Record{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return record, nil
+}
+
+// StatusHistory возвращает переходы записи id между статусами по порядку.
+func (s *Store) StatusHistory(id int64) ([]StatusChange, error) {
+	q := `SELECT status, changed_at FROM record_status_history WHERE record_id=? ORDER BY changed_at, id`
+
+	rows, err := s.db.Query(q, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []StatusChange
+	for rows.Next() {
+		var c StatusChange
+		if err := rows.Scan(&c.Status, &c.ChangedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		history = append(history, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get status history: %w", err)
+	}
+
+	return history, nil
+}
+
+func (s *Store) DayRecords(from, to int64) ([]ScheduledRecord, error) {
+	q := `SELECT ` + recordColumns + `,
+			COALESCE(r.client_name, (SELECT u.name FROM users u WHERE u.user_id = r.user_id LIMIT 1), ''),
+			COALESCE(r.client_phone, (SELECT u.phone_number FROM users u WHERE u.user_id = r.user_id LIMIT 1), ''),
+			COALESCE((SELECT t.rack FROM tire_storage t WHERE t.pickup_record_id = r.id AND t.checked_out_at IS NULL LIMIT 1), '')` +
+		recordJoins + `
+		WHERE r.datetime >= ? AND r.datetime < ? AND r.` + activeCondition + `
+		ORDER BY r.datetime, r.bay`
+
+	rows, err := s.db.Query(q, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get day records: %w", err)
+	}
+	defer rows.Close()
+
+	var records []ScheduledRecord
+	for rows.Next() {
+		var (
+			r       ScheduledRecord
+			minutes int
+		)
+		err := rows.Scan(append(recordFields(&r.Record, &minutes), &r.Name, &r.Phone, &r.PickupRack)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		r.Duration = time.Duration(minutes) * time.Minute
+		r.Vehicle.UserId = r.UserId
+		records = append(records, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get day records: %w", err)
+	}
+
+	return records, nil
+}
+
+func (s *Store) GetRecord(id, userId int64) (Record, error) {
+	record, err := scanRecord(s.db.QueryRow(recordQuery+` WHERE r.id=? AND r.user_id=?`, id, userId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Record{}, ErrNoRecord
+	}
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to get record: %w", err)
+	}
+
+	return record, nil
+}
+
+// CancelRecord отменяет запись пользователя, если она ещё не началась.
+// Если отменять уже поздно, возвращается ErrBadTransition.
+func (s *Store) CancelRecord(id, userId int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	record, err := scanRecord(tx.QueryRow(recordQuery+` WHERE r.id=? AND r.user_id=?`, id, userId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNoRecord
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get record: %w", err)
+	}
+	if err := changeStatus(tx, &record, StatusCancelledByClient); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// MoveRecord переносит запись пользователя, подбирая свободный пост. Перенесённая
+// запись снова ждёт подтверждения, напоминания о ней отправятся заново. Если
+// переносить уже поздно, возвращается ErrBadTransition. Занятость считается на момент now.
+func (s *Store) MoveRecord(id, userId, datetime int64, bays int, now int64) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := tx.lockRecords(); err != nil {
+		return 0, fmt.Errorf("failed to lock records: %w", err)
+	}
+
+	record, err := scanRecord(tx.QueryRow(recordQuery+` WHERE r.id=? AND r.user_id=?`, id, userId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNoRecord
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get record: %w", err)
+	}
+	if !Changeable(record.Status) {
+		return 0, ErrBadTransition
+	}
+
+	end := datetime + int64(record.Duration.Seconds())
+	busy, err := bookings(tx, datetime, end, id, now)
+	if err != nil {
+		return 0, err
+	}
+	bay := FreeBay(busy, datetime, end, bays)
+	if bay == 0 {
+		return 0, ErrSlotTaken
+	}
+
+	_, err = tx.Exec(`UPDATE records SET datetime=?, bay=? WHERE id=?`, datetime, bay, id)
+	if isUniqueViolation(err) {
+		return 0, ErrSlotTaken
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to move record: %w", err)
+	}
+	// Напоминания о прежнем времени не должны помешать напомнить о новом.
+	if _, err := tx.Exec(`DELETE FROM reminders_sent WHERE record_id=?`, id); err != nil {
+		return 0, fmt.Errorf("failed to reset reminders: %w", err)
+	}
+	if record.Status != StatusPending {
+		if err := changeStatus(tx, &record, StatusPending); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return bay, nil
+}
@@ -0,0 +1,185 @@
+package db_test
+
+import (
+	"automobile36/internal/db"
+	"automobile36/internal/db/dbtest"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// recordIds возвращает id записей по порядку.
+func recordIds(records []db.Record) []int64 {
+	var ids []int64
+	for _, r := range records {
+		ids = append(ids, r.Id)
+	}
+
+	return ids
+}
+
+func TestGetAllRecords(t *testing.T) {
+	loc := dbtest.Location
+	at := time.Date(2026, 10, 19, 9, 0, 0, 0, loc).Unix()
+	booked := time.Date(2026, 10, 18, 12, 0, 0, 0, loc).Unix()
+
+	dbtest.Each(t, func(t *testing.T, s *db.Store) {
+		past, err := s.SaveRecord(1, 0, at, 0, time.Hour, 2, booked)
+		if err != nil {
+			t.Fatal(err)
+		}
+		later, err := s.SaveRecord(1, 0, at+3*3600, 0, time.Hour, 2, booked)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cancelled, err := s.SaveRecord(1, 0, at+6*3600, 0, time.Hour, 2, booked)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.CancelRecord(cancelled.Id, 1); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name string
+			now  int64
+			want []int64
+		}{
+			{"before both", at - 1, []int64{past.Id, later.Id}},
+			{"first started", at, []int64{later.Id}},
+			{"all started", at + 3*3600, nil},
+		}
+		for _, tt := range tests {
+			got, err := s.GetAllRecords(1, tt.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ids := recordIds(got); !equalIds(ids, tt.want) {
+				t.Errorf("%s: GetAllRecords() = %v, want %v", tt.name, ids, tt.want)
+			}
+		}
+
+		// Работы по записи, на которую клиент приехал, ещё идут - запись остаётся в списке.
+		if _, err := s.SetStatus(past.Id, db.StatusArrived); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.GetAllRecords(1, at+3*3600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids := recordIds(got); !equalIds(ids, []int64{past.Id}) {
+			t.Errorf("GetAllRecords() with arrived record = %v, want %v", ids, []int64{past.Id})
+		}
+	})
+}
+
+func equalIds(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{db.StatusPending, db.StatusConfirmed, true},
+		{db.StatusPending, db.StatusCancelledByClient, true},
+		{db.StatusPending, db.StatusDone, false},
+		{db.StatusConfirmed, db.StatusPending, true},
+		{db.StatusConfirmed, db.StatusArrived, true},
+		{db.StatusConfirmed, db.StatusInProgress, false},
+		{db.StatusArrived, db.StatusConfirmed, false},
+		{db.StatusArrived, db.StatusCancelledByClient, false},
+		{db.StatusArrived, db.StatusInProgress, true},
+		{db.StatusInProgress, db.StatusDone, true},
+		{db.StatusInProgress, db.StatusArrived, false},
+		{db.StatusDone, db.StatusPending, false},
+		{db.StatusNoShow, db.StatusArrived, false},
+		{db.StatusCancelledByShop, db.StatusConfirmed, false},
+		{db.StatusCancelledByClient, db.StatusPending, false},
+	}
+	for _, tt := range tests {
+		if got := db.CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestChangeable(t *testing.T) {
+	tests := map[string]bool{
+		db.StatusPending:           true,
+		db.StatusConfirmed:         true,
+		db.StatusArrived:           false,
+		db.StatusInProgress:        false,
+		db.StatusDone:              false,
+		db.StatusNoShow:            false,
+		db.StatusCancelledByClient: false,
+		db.StatusCancelledByShop:   false,
+	}
+	for status, want := range tests {
+		if got := db.Changeable(status); got != want {
+			t.Errorf("Changeable(%s) = %v, want %v", status, got, want)
+		}
+	}
+}
+
+// statuses возвращает статусы из истории по порядку.
+func statuses(history []db.StatusChange) []string {
+	var s []string
+	for _, c := range history {
+		s = append(s, c.Status)
+	}
+
+	return s
+}
+
+func TestSetStatus(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, dbtest.Location)
+	at := time.Date(2026, 10, 19, 9, 0, 0, 0, dbtest.Location).Unix()
+
+	dbtest.Each(t, func(t *testing.T, s *db.Store) {
+		r, err := s.SaveRecord(1, 0, at, 0, time.Hour, 1, now.Unix())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, status := range []string{db.StatusConfirmed, db.StatusArrived} {
+			if r, err = s.SetStatus(r.Id, status); err != nil || r.Status != status {
+				t.Fatalf("SetStatus(%s) = %+v, %v", status, r, err)
+			}
+		}
+		if _, err := s.SetStatus(r.Id, db.StatusConfirmed); !errors.Is(err, db.ErrBadTransition) {
+			t.Errorf("SetStatus() from arrived to confirmed: err = %v, want ErrBadTransition", err)
+		}
+		if _, err := s.SetStatus(r.Id, db.StatusDone); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.SetStatus(r.Id, db.StatusPending); !errors.Is(err, db.ErrBadTransition) {
+			t.Errorf("SetStatus() from done to pending: err = %v, want ErrBadTransition", err)
+		}
+
+		got, err := s.GetRecordById(r.Id)
+		if err != nil || got.Status != db.StatusDone {
+			t.Fatalf("GetRecordById() = %+v, %v; want a done record", got, err)
+		}
+		history, err := s.StatusHistory(r.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Отклонённые переходы в историю не попадают.
+		want := []string{db.StatusPending, db.StatusConfirmed, db.StatusArrived, db.StatusDone}
+		if got := statuses(history); !reflect.DeepEqual(got, want) {
+			t.Errorf("StatusHistory() = %v, want %v", got, want)
+		}
+	})
+}
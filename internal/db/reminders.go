@@ -0,0 +1,56 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// DueReminders возвращает записи, до начала которых на момент now осталось не больше
+// before и напоминание за before о которых ещё не отправлено. Записи, сделанные
+// меньше чем за before до начала, не попадают в выборку: клиент и так о них помнит.
+func (s *Store) DueReminders(now int64, before time.Duration) ([]Record, error) {
+	offset := int64(before.Seconds())
+	q := recordQuery + ` WHERE r.user_id != 0 AND r.status IN ('` + StatusPending + `', '` + StatusConfirmed + `')
+		AND r.datetime > ? AND r.datetime <= ?
+		AND (SELECT MIN(h.changed_at) FROM record_status_history h WHERE h.record_id = r.id) <= r.datetime - ?
+		AND NOT EXISTS (SELECT 1 FROM reminders_sent rs WHERE rs.record_id = r.id AND rs.offset_minutes = ?)
+		ORDER BY r.datetime`
+
+	rows, err := s.db.Query(q, now, now+offset, offset, int(before.Minutes()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due reminders: %w", err)
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		record, err := scanRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		records = append(records, record)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get due reminders: %w", err)
+	}
+
+	return records, nil
+}
+
+// MarkReminderSent отмечает напоминание за before о записи recordId отправленным.
+// Возвращает false, если отметка уже была, чтобы напоминание не ушло дважды.
+func (s *Store) MarkReminderSent(recordId int64, before time.Duration) (bool, error) {
+	q := `INSERT INTO reminders_sent (record_id, offset_minutes, sent_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`
+
+	res, err := s.db.Exec(q, recordId, int(before.Minutes()), time.Now().Unix())
+	if err != nil {
+		return false, fmt.Errorf("failed to mark reminder sent: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to mark reminder sent: %w", err)
+	}
+
+	return n > 0, nil
+}
@@ -0,0 +1,173 @@
+package db
+
+import (
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/conversation"
+)
+
+// UserRepository - клиенты бота.
+type UserRepository interface {
+	// GetInfo возвращает имя и номер телефона в формате E.164.
+	GetInfo(userId int64) (string, string, error)
+	SaveUser(userId int64, name string, phone string) error
+	UpdateNumber(userId int64, phone string) error
+	IsExists(userId int64) (bool, error)
+}
+
+// RecordRepository - записи на обслуживание.
+type RecordRepository interface {
+	// SaveRecord сохраняет запись на первый свободный из bays постов и возвращает её.
+	// vehicleId = 0 - автомобиль не выбран; now - текущий момент, к которому истекают
+	// предложения листа ожидания. Если свободного поста нет, возвращается ErrSlotTaken.
+	SaveRecord(userId, vehicleId int64, datetime int64, serviceId int64, duration time.Duration, bays int, now int64) (Record, error)
+	// SaveWalkIn сохраняет подтверждённую запись за клиента без Telegram, как SaveRecord.
+	SaveWalkIn(name, phone string, datetime int64, serviceId int64, duration time.Duration, bays int, now int64) (Record, error)
+	// GetAllRecords возвращает записи пользователя, которые начнутся после now, и записи,
+	// работы по которым ещё идут.
+	GetAllRecords(userId, now int64) ([]Record, error)
+	// GetRecord возвращает запись id, если она принадлежит пользователю userId, иначе ErrNoRecord.
+	GetRecord(id, userId int64) (Record, error)
+	// GetRecordById возвращает запись id любого пользователя, иначе ErrNoRecord.
+	GetRecordById(id int64) (Record, error)
+	// SetStatus меняет статус записи по решению сотрудников. Недопустимый переход - ErrBadTransition.
+	SetStatus(id int64, status string) (Record, error)
+	// StatusHistory возвращает переходы записи между статусами по порядку.
+	StatusHistory(id int64) ([]StatusChange, error)
+	// CancelRecord отменяет запись клиентом. Если отменять поздно - ErrBadTransition.
+	CancelRecord(id, userId int64) error
+	// MoveRecord переносит запись на datetime и возвращает номер нового поста.
+	// Если переносить поздно - ErrBadTransition.
+	MoveRecord(id, userId, datetime int64, bays int, now int64) (int, error)
+	// DayRecords возвращает неотменённые записи с from до to вместе с контактами клиентов.
+	DayRecords(from, to int64) ([]ScheduledRecord, error)
+	// Bookings возвращает занятость постов в интервале [from, to) на момент now, не считая
+	// записи exclude (0 - считать все).
+	Bookings(from, to, exclude, now int64) ([]Booking, error)
+}
+
+// ReminderRepository - напоминания клиентам о записях.
+type ReminderRepository interface {
+	DueReminders(now int64, before time.Duration) ([]Record, error)
+	MarkReminderSent(recordId int64, before time.Duration) (bool, error)
+}
+
+// AdminRepository - сотрудники и их роли.
+type AdminRepository interface {
+	// GetRole возвращает роль пользователя или пустую строку, если он не сотрудник.
+	GetRole(userId int64) (string, error)
+	// SetAdmin назначает пользователю роль, заменяя прежнюю.
+	SetAdmin(userId int64, role string, addedBy int64) error
+	RemoveAdmin(userId int64) (bool, error)
+	Admins() ([]Admin, error)
+}
+
+// SlotBlockRepository - интервалы, закрытые сотрудниками для записи.
+type SlotBlockRepository interface {
+	AddSlotBlock(start, end int64, bay int, reason string, createdBy int64) (SlotBlock, error)
+	RemoveSlotBlock(id int64) (bool, error)
+	// SlotBlocks возвращает блокировки, пересекающиеся с интервалом [from, to).
+	SlotBlocks(from, to int64) ([]SlotBlock, error)
+}
+
+// VehicleRepository - автомобили клиентов.
+type VehicleRepository interface {
+	GetVehicles(userId int64) ([]Vehicle, error)
+	// GetVehicle возвращает автомобиль id пользователя userId, иначе ErrNoVehicle.
+	GetVehicle(id, userId int64) (Vehicle, error)
+	// SaveVehicle добавляет автомобиль без Id или обновляет существующий (ErrNoVehicle, если его нет).
+	SaveVehicle(v Vehicle) (Vehicle, error)
+	RemoveVehicle(id, userId int64) (bool, error)
+}
+
+// TireStorageRepository - сезонное хранение шин.
+type TireStorageRepository interface {
+	// AddStorageContract принимает на хранение шины клиента c.Phone. Автомобиль
+	// находится по c.Plate среди автомобилей клиента.
+	AddStorageContract(c StorageContract, createdBy int64) (StorageContract, error)
+	// GetStorageContract возвращает договор id, иначе ErrNoStorage.
+	GetStorageContract(id int64) (StorageContract, error)
+	// StorageContracts возвращает шины клиента на хранении, в том числе принятые по его телефону.
+	StorageContracts(userId int64) ([]StorageContract, error)
+	ActiveStorageContracts() ([]StorageContract, error)
+	// CheckOutStorage отмечает шины выданными. Если их нет на хранении - ErrNoStorage.
+	CheckOutStorage(id int64) (StorageContract, error)
+	// LinkStoragePickup привязывает выдачу шин клиента к его записи recordId.
+	// Чужие договоры - ErrNoStorage, чужие записи - ErrNoRecord.
+	LinkStoragePickup(id, userId, recordId int64) (StorageContract, error)
+}
+
+// BroadcastRepository - рассылки клиентам и их доставка.
+type BroadcastRepository interface {
+	// AudienceUsers возвращает клиентов аудитории (Audience*); текущий сезон начинается в seasonStart.
+	AudienceUsers(audience string, seasonStart int64) ([]int64, error)
+	// CreateBroadcast сохраняет рассылку и ставит её в очередь для recipients.
+	CreateBroadcast(b Broadcast, recipients []int64) (Broadcast, error)
+	UnfinishedBroadcasts() ([]Broadcast, error)
+	PendingRecipients(id int64) ([]int64, error)
+	SetDeliveryStatus(id, userId int64, status string) error
+	FinishBroadcast(id int64) error
+	BroadcastStats(id int64) (BroadcastStats, error)
+}
+
+// WaitlistRepository - лист ожидания на дни без свободного времени.
+type WaitlistRepository interface {
+	// AddWaitlist ставит заявку в конец очереди на момент now; действующая заявка
+	// клиента на тот же день и окно не дублируется, а возвращается.
+	AddWaitlist(e WaitlistEntry, now int64) (WaitlistEntry, error)
+	// Waitlist возвращает ожидающие заявки и заявки с предложенным временем в порядке очереди.
+	Waitlist() ([]WaitlistEntry, error)
+	// OfferWaitlist предлагает ожидающей заявке время datetime до expiresAt.
+	// Если заявка уже не ждёт - ErrNoWaitlist.
+	OfferWaitlist(id, datetime, expiresAt int64) error
+	// ClaimWaitlist записывает клиента на предложенное время на один из bays постов и
+	// отмечает заявку забранной. Если предложения нет или оно истекло к now -
+	// ErrNoWaitlist, если время заняли - ErrSlotTaken.
+	ClaimWaitlist(id, userId, now int64, bays int) (Record, error)
+	// RequeueWaitlist возвращает заявку с пропущенным предложением в конец очереди.
+	RequeueWaitlist(id, now int64) error
+	// SetWaitlistStatus меняет статус заявки; возврат в WaitlistWaiting снимает предложение.
+	SetWaitlistStatus(id int64, status string) error
+	CancelWaitlist(id, userId int64) (bool, error)
+}
+
+// ClosedDayRepository - дни, когда мастерская не работает.
+type ClosedDayRepository interface {
+	AddClosedDay(day int64, reason string) error
+	RemoveClosedDay(day int64) (bool, error)
+	GetClosedDays(from, to int64) ([]ClosedDay, error)
+}
+
+// CatalogRepository - услуги и цены.
+type CatalogRepository interface {
+	GetServices() ([]Service, error)
+	GetService(id int64) (Service, error)
+	GetPrices() ([]Price, error)
+	GetFileId(key string) (string, int64, bool, error)
+	SaveFileId(key, fileId string, modTime int64) error
+}
+
+// SessionRepository - промежуточные данные и состояния незавершённых диалогов.
+type SessionRepository interface {
+	SetSession(key string, value any, ttl time.Duration) error
+	GetSession(key string, dst any) (bool, error)
+	DeleteExpiredSessions() error
+	ConversationStorage(name string, strategy conversation.KeyStrategy, ttl time.Duration) conversation.Storage
+}
+
+// Storage объединяет все хранилища бота.
+type Storage interface {
+	UserRepository
+	RecordRepository
+	ReminderRepository
+	AdminRepository
+	SlotBlockRepository
+	VehicleRepository
+	TireStorageRepository
+	BroadcastRepository
+	WaitlistRepository
+	ClosedDayRepository
+	CatalogRepository
+	SessionRepository
+	Close() error
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// Service - услуга из каталога. Duration - сколько времени пост занят под работу.
+type Service struct {
+	Id       int64
+	Name     string
+	Category string
+	Price    int
+	Duration time.Duration
+}
+
+// DefaultServices - каталог, которым заполняется пустая база.
+var DefaultServices = []Service{
+	{Name: "Сезонная замена шин", Category: "Шиномонтаж", Price: 2000, Duration: 90 * time.Minute},
+	{Name: "Балансировка", Category: "Шиномонтаж", Price: 800, Duration: 30 * time.Minute},
+	{Name: "Ремонт прокола", Category: "Ремонт", Price: 500, Duration: 30 * time.Minute},
+	{Name: "Сдача шин на хранение", Category: "Хранение", Price: 3000, Duration: 15 * time.Minute},
+}
+
+// seedServices заполняет каталог услугами по умолчанию, если он пуст.
+func (s *Store) seedServices() error {
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM services`).Scan(&count); err != nil {
+		return fmt.Errorf("failed to count services: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	q := `INSERT INTO services (name, category, price, duration) VALUES (?, ?, ?, ?)`
+	for _, service := range DefaultServices {
+		if _, err := s.db.Exec(q, service.Name, service.Category, service.Price, int(service.Duration.Minutes())); err != nil {
+			return fmt.Errorf("failed to save service: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) GetServices() ([]Service, error) {
+	q := `SELECT id, name, category, price, duration FROM services ORDER BY id`
+
+	rows, err := s.db.Query(q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get services: %w", err)
+	}
+	defer rows.Close()
+
+	var services []Service
+	for rows.Next() {
+		var (
+			service Service
+			minutes int
+		)
+		err = rows.Scan(&service.Id, &service.Name, &service.Category, &service.Price, &minutes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		service.Duration = time.Duration(minutes) * time.Minute
+		services = append(services, service)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get services: %w", err)
+	}
+
+	return services, nil
+}
+
+func (s *Store) GetService(id int64) (Service, error) {
+	q := `SELECT id, name, category, price, duration FROM services WHERE id=?`
+
+	var (
+		service Service
+		minutes int
+	)
+	err := s.db.QueryRow(q, id).Scan(&service.Id, &service.Name, &service.Category, &service.Price, &minutes)
+	if err != nil {
+		return Service{}, fmt.Errorf("failed to get service: %w", err)
+	}
+	service.Duration = time.Duration(minutes) * time.Minute
+
+	return service, nil
+}
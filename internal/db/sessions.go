@@ -0,0 +1,130 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/conversation"
+)
+
+// SetSession сохраняет промежуточные данные диалога под ключом key на время ttl.
+func (s *Store) SetSession(key string, value any, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode session data: %w", err)
+	}
+
+	q := `INSERT INTO session_data (key, value, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET value=excluded.value, expires_at=excluded.expires_at`
+
+	_, err = s.db.Exec(q, key, string(data), time.Now().Add(ttl).Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save session data: %w", err)
+	}
+
+	return nil
+}
+
+// GetSession читает данные по ключу key в dst. Если данных нет или они
+// устарели, возвращает false.
+func (s *Store) GetSession(key string, dst any) (bool, error) {
+	q := `SELECT value FROM session_data WHERE key=? AND expires_at > ?`
+
+	var data string
+	err := s.db.QueryRow(q, key, time.Now().Unix()).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get session data: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(data), dst); err != nil {
+		return false, fmt.Errorf("failed to decode session data: %w", err)
+	}
+
+	return true, nil
+}
+
+// DeleteExpiredSessions удаляет устаревшие данные диалогов и состояния бесед.
+func (s *Store) DeleteExpiredSessions() error {
+	now := time.Now().Unix()
+	if _, err := s.db.Exec(`DELETE FROM session_data WHERE expires_at <= ?`, now); err != nil {
+		return fmt.Errorf("failed to delete expired session data: %w", err)
+	}
+	if _, err := s.db.Exec(`DELETE FROM conversations WHERE expires_at <= ?`, now); err != nil {
+		return fmt.Errorf("failed to delete expired conversations: %w", err)
+	}
+
+	return nil
+}
+
+// conversationStorage хранит состояния бесед gotgbot в базе, чтобы они
+// переживали перезапуск бота. name отделяет беседы друг от друга.
+type conversationStorage struct {
+	db          *conn
+	name        string
+	keyStrategy conversation.KeyStrategy
+	ttl         time.Duration
+}
+
+func (s *Store) ConversationStorage(name string, strategy conversation.KeyStrategy, ttl time.Duration) conversation.Storage {
+	return &conversationStorage{
+		db:          s.db,
+		name:        name,
+		keyStrategy: strategy,
+		ttl:         ttl,
+	}
+}
+
+func (c *conversationStorage) Get(ctx *ext.Context) (*conversation.State, error) {
+	q := `SELECT state FROM conversations WHERE name=? AND key=? AND expires_at > ?`
+
+	var data string
+	err := c.db.QueryRow(q, c.name, conversation.StateKey(ctx, c.keyStrategy), time.Now().Unix()).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, conversation.KeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation state: %w", err)
+	}
+
+	var state conversation.State
+	if err := json.Unmarshal([]byte(data), &state); err != nil {
+		return nil, fmt.Errorf("failed to decode conversation state: %w", err)
+	}
+
+	return &state, nil
+}
+
+func (c *conversationStorage) Set(ctx *ext.Context, state conversation.State) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode conversation state: %w", err)
+	}
+
+	q := `INSERT INTO conversations (name, key, state, expires_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(name, key) DO UPDATE SET state=excluded.state, expires_at=excluded.expires_at`
+
+	_, err = c.db.Exec(q, c.name, conversation.StateKey(ctx, c.keyStrategy), string(data), time.Now().Add(c.ttl).Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save conversation state: %w", err)
+	}
+
+	return nil
+}
+
+func (c *conversationStorage) Delete(ctx *ext.Context) error {
+	q := `DELETE FROM conversations WHERE name=? AND key=?`
+
+	_, err := c.db.Exec(q, c.name, conversation.StateKey(ctx, c.keyStrategy))
+	if err != nil {
+		return fmt.Errorf("failed to delete conversation state: %w", err)
+	}
+
+	return nil
+}
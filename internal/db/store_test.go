@@ -0,0 +1,175 @@
+package db_test
+
+import (
+	"automobile36/internal/db"
+	"automobile36/internal/db/dbtest"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// upMigrations считает миграции диалекта в каталоге migrations.
+func upMigrations(t *testing.T, dialect string) int {
+	t.Helper()
+
+	entries, err := os.ReadDir("migrations/" + dialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".up.sql") {
+			n++
+		}
+	}
+
+	return n
+}
+
+func TestMigrations(t *testing.T) {
+	dbtest.Each(t, func(t *testing.T, s *db.Store) {
+		latest := upMigrations(t, s.Dialect())
+		if v, err := s.SchemaVersion(); err != nil || v != latest {
+			t.Fatalf("SchemaVersion() = %d, %v; want %d", v, err, latest)
+		}
+
+		if err := s.Rollback(latest); err != nil {
+			t.Fatalf("Rollback(%d): %s", latest, err)
+		}
+		if v, err := s.SchemaVersion(); err != nil || v != 0 {
+			t.Fatalf("SchemaVersion() after rollback = %d, %v; want 0", v, err)
+		}
+
+		if err := s.Migrate(); err != nil {
+			t.Fatalf("Migrate() after rollback: %s", err)
+		}
+		if v, err := s.SchemaVersion(); err != nil || v != latest {
+			t.Fatalf("SchemaVersion() after migrate = %d, %v; want %d", v, err, latest)
+		}
+		if _, err := s.SaveRecord(1, 0, time.Now().Add(time.Hour).Unix(), 0, time.Hour, 1, time.Now().Unix()); err != nil {
+			t.Fatalf("SaveRecord() on re-migrated schema: %s", err)
+		}
+	})
+}
+
+func TestRebindLiteral(t *testing.T) {
+	dbtest.Each(t, func(t *testing.T, s *db.Store) {
+		if err := s.SaveUser(1, "who?", "+79990000000"); err != nil {
+			t.Fatal(err)
+		}
+		// Знак вопроса в литерале не плейсхолдер: PostgreSQL иначе ждал бы три аргумента.
+		if err := s.Exec(`UPDATE users SET name=? WHERE name='who?' AND user_id=?`, "Иван", 1); err != nil {
+			t.Fatalf("Exec(): %s", err)
+		}
+		if name, _, err := s.GetInfo(1); err != nil || name != "Иван" {
+			t.Fatalf("GetInfo() = %q, %v; want Иван", name, err)
+		}
+	})
+}
+
+func TestUniqueViolation(t *testing.T) {
+	dbtest.Each(t, func(t *testing.T, s *db.Store) {
+		q := `INSERT INTO records (user_id, datetime, bay, duration, status) VALUES (?, ?, ?, ?, ?)`
+		at := time.Now().Add(time.Hour).Unix()
+
+		if err := s.Exec(q, 1, at, 1, 60, db.StatusCancelledByShop); err != nil {
+			t.Fatal(err)
+		}
+		// Отменённые записи пост не занимают.
+		if err := s.Exec(q, 2, at, 1, 60, db.StatusPending); err != nil {
+			t.Fatalf("insert over cancelled record: %s", err)
+		}
+		err := s.Exec(q, 3, at, 1, 60, db.StatusPending)
+		if err == nil || !db.IsUniqueViolation(err) {
+			t.Fatalf("second record on the same bay: err = %v, want unique violation", err)
+		}
+		if err := s.Exec(q, 3, at, 2, 60, db.StatusPending); err != nil {
+			t.Fatalf("record on another bay: %s", err)
+		}
+	})
+}
+
+// TestSaveRecordConcurrent проверяет блокировку записей: параллельные записи на
+// пересекающееся, но не совпадающее время уникальный индекс не остановит.
+func TestSaveRecordConcurrent(t *testing.T) {
+	dbtest.Each(t, func(t *testing.T, s *db.Store) {
+		for bays := 1; bays <= 2; bays++ {
+			base := time.Now().Add(time.Duration(bays) * 24 * time.Hour).Truncate(time.Hour)
+
+			var (
+				wg           sync.WaitGroup
+				mu           sync.Mutex
+				saved, taken int
+				unexpected   []error
+			)
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					at := base.Add(time.Duration(i) * 5 * time.Minute).Unix()
+					_, err := s.SaveRecord(int64(i+1), 0, at, 0, time.Hour, bays, time.Now().Unix())
+
+					mu.Lock()
+					defer mu.Unlock()
+					switch {
+					case err == nil:
+						saved++
+					case errors.Is(err, db.ErrSlotTaken):
+						taken++
+					default:
+						unexpected = append(unexpected, err)
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			if len(unexpected) > 0 {
+				t.Fatalf("bays=%d: unexpected errors: %v", bays, unexpected)
+			}
+			if saved != bays || taken != 8-bays {
+				t.Errorf("bays=%d: saved %d, taken %d; want %d and %d", bays, saved, taken, bays, 8-bays)
+			}
+		}
+	})
+}
+
+// TestPhoneMigration проверяет, что перевод номеров в текст приводит российские
+// номера к E.164, а нераспознанные оставляет как были.
+func TestPhoneMigration(t *testing.T) {
+	dbtest.Each(t, func(t *testing.T, s *db.Store) {
+		latest := upMigrations(t, s.Dialect())
+		// Откатываемся к первой миграции, где номер ещё хранится числом.
+		if err := s.Rollback(latest - 1); err != nil {
+			t.Fatalf("Rollback(%d): %s", latest-1, err)
+		}
+
+		tests := []struct {
+			phone int64
+			want  string
+		}{
+			{89991234567, "+79991234567"},
+			{79991234567, "+79991234567"},
+			{9991234567, "+79991234567"},
+			{12345, "12345"},
+			{380501234567, "380501234567"},
+			{19991234567, "19991234567"},
+		}
+		for i, tt := range tests {
+			if err := s.Exec(`INSERT INTO users (user_id, name, phone_number) VALUES (?, ?, ?)`, i+1, "Клиент", tt.phone); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.Migrate(); err != nil {
+			t.Fatalf("Migrate(): %s", err)
+		}
+
+		for i, tt := range tests {
+			if _, phone, err := s.GetInfo(int64(i + 1)); err != nil || phone != tt.want {
+				t.Errorf("phone %d after migration = %q, %v; want %q", tt.phone, phone, err, tt.want)
+			}
+		}
+	})
+}
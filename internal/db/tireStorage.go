@@ -0,0 +1,164 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrNoStorage = errors.New("storage contract not found")
+
+// StorageContract - договор сезонного хранения шин («шинный отель»). Клиент
+// определяется по телефону: если он ещё не пользуется ботом, договор появится
+// у него после регистрации с этим номером.
+type StorageContract struct {
+	Id int64
+	// UserId - клиент в Telegram или 0, если клиента с таким телефоном в боте нет.
+	UserId int64
+	Phone  string
+	// Vehicle - автомобиль клиента с госномером Plate, если он есть в боте.
+	Vehicle     Vehicle
+	Plate       string
+	Description string
+	// Rack - место на складе, например "A-12".
+	Rack     string
+	StartsAt int64
+	EndsAt   int64
+	Price    int
+	// CheckedOutAt - когда шины выданы, 0 - ещё на хранении.
+	CheckedOutAt int64
+	// PickupRecordId - запись, к которой клиент заберёт шины, 0 - не выбрана.
+	PickupRecordId int64
+}
+
+const storageQuery = `SELECT t.id,
+		COALESCE(NULLIF(t.user_id, 0), (SELECT u.user_id FROM users u WHERE u.phone_number = t.phone LIMIT 1), 0),
+		t.phone, COALESCE(v.id, 0), COALESCE(v.make, ''), COALESCE(v.model, ''), COALESCE(v.plate, ''),
+		COALESCE(v.tire_size, ''), COALESCE(v.rim_type, ''),
+		t.plate, t.description, t.rack, t.starts_at, t.ends_at, t.price,
+		COALESCE(t.checked_out_at, 0), COALESCE(t.pickup_record_id, 0)
+	FROM tire_storage t LEFT JOIN vehicles v ON v.id = t.vehicle_id`
+
+func scanStorage(row scanner) (StorageContract, error) {
+	var c StorageContract
+	err := row.Scan(
+		&c.Id, &c.UserId, &c.Phone, &c.Vehicle.Id, &c.Vehicle.Make, &c.Vehicle.Model, &c.Vehicle.Plate,
+		&c.Vehicle.TireSize, &c.Vehicle.RimType,
+		&c.Plate, &c.Description, &c.Rack, &c.StartsAt, &c.EndsAt, &c.Price, &c.CheckedOutAt, &c.PickupRecordId,
+	)
+	c.Vehicle.UserId = c.UserId
+
+	return c, err
+}
+
+func (s *Store) queryStorages(q string, args ...any) ([]StorageContract, error) {
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get storage contracts: %w", err)
+	}
+	defer rows.Close()
+
+	var contracts []StorageContract
+	for rows.Next() {
+		c, err := scanStorage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		contracts = append(contracts, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get storage contracts: %w", err)
+	}
+
+	return contracts, nil
+}
+
+// AddStorageContract принимает шины на хранение. Клиент и автомобиль находятся
+// по телефону и госномеру, если они есть в боте.
+func (s *Store) AddStorageContract(c StorageContract, createdBy int64) (StorageContract, error) {
+	q := `INSERT INTO tire_storage (user_id, phone, vehicle_id, plate, description, rack, starts_at, ends_at, price, created_by, created_at)
+		VALUES (
+			COALESCE((SELECT user_id FROM users WHERE phone_number = ? LIMIT 1), 0), ?,
+			(SELECT v.id FROM vehicles v JOIN users u ON u.user_id = v.user_id
+				WHERE u.phone_number = ? AND v.plate = ? AND v.plate != '' AND v.removed_at IS NULL LIMIT 1),
+			?, ?, ?, ?, ?, ?, ?, ?
+		) RETURNING id`
+
+	var id int64
+	err := s.db.QueryRow(
+		q, c.Phone, c.Phone, c.Phone, c.Plate, c.Plate, c.Description, c.Rack, c.StartsAt, c.EndsAt, c.Price, createdBy, time.Now().Unix(),
+	).Scan(&id)
+	if err != nil {
+		return StorageContract{}, fmt.Errorf("failed to save storage contract: %w", err)
+	}
+
+	return s.GetStorageContract(id)
+}
+
+func (s *Store) GetStorageContract(id int64) (StorageContract, error) {
+	c, err := scanStorage(s.db.QueryRow(storageQuery+` WHERE t.id=?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return StorageContract{}, ErrNoStorage
+	}
+	if err != nil {
+		return StorageContract{}, fmt.Errorf("failed to get storage contract: %w", err)
+	}
+
+	return c, nil
+}
+
+// StorageContracts возвращает шины клиента userId, которые сейчас на хранении.
+func (s *Store) StorageContracts(userId int64) ([]StorageContract, error) {
+	q := storageQuery + ` WHERE t.checked_out_at IS NULL
+		AND (t.user_id = ? OR t.user_id = 0 AND t.phone = (SELECT u.phone_number FROM users u WHERE u.user_id = ? LIMIT 1))
+		ORDER BY t.starts_at, t.id`
+
+	return s.queryStorages(q, userId, userId)
+}
+
+// ActiveStorageContracts возвращает все шины на хранении в порядке окончания сроков.
+func (s *Store) ActiveStorageContracts() ([]StorageContract, error) {
+	return s.queryStorages(storageQuery + ` WHERE t.checked_out_at IS NULL ORDER BY t.ends_at, t.id`)
+}
+
+// CheckOutStorage отмечает шины выданными. Если договора нет или шины уже выданы - ErrNoStorage.
+func (s *Store) CheckOutStorage(id int64) (StorageContract, error) {
+	res, err := s.db.Exec(`UPDATE tire_storage SET checked_out_at=? WHERE id=? AND checked_out_at IS NULL`, time.Now().Unix(), id)
+	if err != nil {
+		return StorageContract{}, fmt.Errorf("failed to check out storage: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return StorageContract{}, fmt.Errorf("failed to check out storage: %w", err)
+	}
+	if n == 0 {
+		return StorageContract{}, ErrNoStorage
+	}
+
+	return s.GetStorageContract(id)
+}
+
+// LinkStoragePickup привязывает выдачу шин клиента userId к его записи recordId.
+func (s *Store) LinkStoragePickup(id, userId, recordId int64) (StorageContract, error) {
+	contracts, err := s.StorageContracts(userId)
+	if err != nil {
+		return StorageContract{}, err
+	}
+	for _, c := range contracts {
+		if c.Id != id {
+			continue
+		}
+		if _, err := s.GetRecord(recordId, userId); err != nil {
+			return StorageContract{}, err
+		}
+		if _, err := s.db.Exec(`UPDATE tire_storage SET pickup_record_id=? WHERE id=?`, recordId, id); err != nil {
+			return StorageContract{}, fmt.Errorf("failed to link storage pickup: %w", err)
+		}
+		c.PickupRecordId = recordId
+		return c, nil
+	}
+
+	return StorageContract{}, ErrNoStorage
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Типы дисков.
+const (
+	RimStamped = "stamped"
+	RimAlloy   = "alloy"
+	RimForged  = "forged"
+)
+
+var ErrNoVehicle = errors.New("vehicle not found")
+
+// ValidRim сообщает, известен ли тип дисков rim.
+func ValidRim(rim string) bool {
+	return rim == RimStamped || rim == RimAlloy || rim == RimForged
+}
+
+// Vehicle - автомобиль клиента. TireSize хранится в виде "205/55 R16".
+type Vehicle struct {
+	Id       int64
+	UserId   int64
+	Make     string
+	Model    string
+	Plate    string
+	TireSize string
+	RimType  string
+}
+
+const vehicleColumns = `id, user_id, make, model, plate, tire_size, rim_type`
+
+func scanVehicle(row scanner) (Vehicle, error) {
+	var v Vehicle
+	err := row.Scan(&v.Id, &v.UserId, &v.Make, &v.Model, &v.Plate, &v.TireSize, &v.RimType)
+
+	return v, err
+}
+
+// GetVehicles возвращает автомобили пользователя в порядке добавления.
+func (s *Store) GetVehicles(userId int64) ([]Vehicle, error) {
+	q := `SELECT ` + vehicleColumns + ` FROM vehicles WHERE user_id=? AND removed_at IS NULL ORDER BY id`
+
+	rows, err := s.db.Query(q, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vehicles: %w", err)
+	}
+	defer rows.Close()
+
+	var vehicles []Vehicle
+	for rows.Next() {
+		v, err := scanVehicle(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		vehicles = append(vehicles, v)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get vehicles: %w", err)
+	}
+
+	return vehicles, nil
+}
+
+func (s *Store) GetVehicle(id, userId int64) (Vehicle, error) {
+	q := `SELECT ` + vehicleColumns + ` FROM vehicles WHERE id=? AND user_id=? AND removed_at IS NULL`
+
+	v, err := scanVehicle(s.db.QueryRow(q, id, userId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Vehicle{}, ErrNoVehicle
+	}
+	if err != nil {
+		return Vehicle{}, fmt.Errorf("failed to get vehicle: %w", err)
+	}
+
+	return v, nil
+}
+
+// SaveVehicle добавляет автомобиль, если у v нет Id, иначе обновляет автомобиль v.UserId.
+func (s *Store) SaveVehicle(v Vehicle) (Vehicle, error) {
+	if v.Id == 0 {
+		q := `INSERT INTO vehicles (user_id, make, model, plate, tire_size, rim_type) VALUES (?, ?, ?, ?, ?, ?) RETURNING id`
+		if err := s.db.QueryRow(q, v.UserId, v.Make, v.Model, v.Plate, v.TireSize, v.RimType).Scan(&v.Id); err != nil {
+			return Vehicle{}, fmt.Errorf("failed to save vehicle: %w", err)
+		}
+		return v, nil
+	}
+
+	q := `UPDATE vehicles SET make=?, model=?, plate=?, tire_size=?, rim_type=? WHERE id=? AND user_id=? AND removed_at IS NULL`
+	res, err := s.db.Exec(q, v.Make, v.Model, v.Plate, v.TireSize, v.RimType, v.Id, v.UserId)
+	if err != nil {
+		return Vehicle{}, fmt.Errorf("failed to update vehicle: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return Vehicle{}, fmt.Errorf("failed to update vehicle: %w", err)
+	}
+	if n == 0 {
+		return Vehicle{}, ErrNoVehicle
+	}
+
+	return v, nil
+}
+
+// RemoveVehicle убирает автомобиль из списка пользователя. В прошлых записях
+// он остаётся виден.
+func (s *Store) RemoveVehicle(id, userId int64) (bool, error) {
+	q := `UPDATE vehicles SET removed_at=? WHERE id=? AND user_id=? AND removed_at IS NULL`
+
+	res, err := s.db.Exec(q, time.Now().Unix(), id, userId)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove vehicle: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to remove vehicle: %w", err)
+	}
+
+	return n > 0, nil
+}
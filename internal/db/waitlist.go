@@ -0,0 +1,245 @@
+package db
+
+import (
+	"automobile36/internal/schedule"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrNoWaitlist = errors.New("waitlist entry not found")
+
+// Статусы заявки в листе ожидания.
+const (
+	WaitlistWaiting = "waiting"
+	// WaitlistOffered - клиенту предложено освободившееся время, он может забрать его до OfferExpiresAt.
+	WaitlistOffered   = "offered"
+	WaitlistClaimed   = "claimed"
+	WaitlistExpired   = "expired"
+	WaitlistCancelled = "cancelled"
+)
+
+// WaitlistEntry - заявка клиента на день, когда свободного времени не было.
+type WaitlistEntry struct {
+	Id     int64
+	UserId int64
+	// Day - полночь дня по часам мастерской.
+	Day int64
+	// From, To - окно, в котором клиенту удобно начать: [From, To).
+	From, To  schedule.Clock
+	ServiceId int64
+	Duration  time.Duration
+	// VehicleId - автомобиль, выбранный при записи, 0 - без автомобиля.
+	VehicleId      int64
+	Status         string
+	OfferDatetime  int64
+	OfferExpiresAt int64
+	// MissedOffers - сколько предложений клиент пропустил.
+	MissedOffers int
+}
+
+// Fits сообщает, попадает ли начало слота в окно заявки.
+func (e WaitlistEntry) Fits(slot schedule.Clock) bool {
+	return slot >= e.From && slot < e.To
+}
+
+const waitlistQuery = `SELECT id, user_id, day, window_from, window_to, service_id, duration,
+		COALESCE(vehicle_id, 0), status, COALESCE(offer_datetime, 0), COALESCE(offer_expires_at, 0), missed_offers
+	FROM waitlist`
+
+// waitlistActive отбирает заявки, которые ещё стоят в очереди.
+const waitlistActive = "status IN ('" + WaitlistWaiting + "', '" + WaitlistOffered + "')"
+
+func scanWaitlist(row scanner) (WaitlistEntry, error) {
+	var (
+		e                  WaitlistEntry
+		from, to, duration int
+	)
+	err := row.Scan(&e.Id, &e.UserId, &e.Day, &from, &to, &e.ServiceId, &duration, &e.VehicleId, &e.Status, &e.OfferDatetime, &e.OfferExpiresAt, &e.MissedOffers)
+	e.From = schedule.Clock(time.Duration(from) * time.Minute)
+	e.To = schedule.Clock(time.Duration(to) * time.Minute)
+	e.Duration = time.Duration(duration) * time.Minute
+
+	return e, err
+}
+
+// AddWaitlist ставит заявку в конец очереди на момент now. Если у клиента уже есть
+// действующая заявка на этот день и окно, новая не создаётся и возвращается она.
+func (s *Store) AddWaitlist(e WaitlistEntry, now int64) (WaitlistEntry, error) {
+	from, to := int(time.Duration(e.From).Minutes()), int(time.Duration(e.To).Minutes())
+
+	existing, err := scanWaitlist(s.db.QueryRow(
+		waitlistQuery+` WHERE user_id=? AND day=? AND window_from=? AND window_to=? AND `+waitlistActive,
+		e.UserId, e.Day, from, to,
+	))
+	if err == nil {
+		return existing, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return WaitlistEntry{}, fmt.Errorf("failed to get waitlist entry: %w", err)
+	}
+
+	q := `INSERT INTO waitlist (user_id, day, window_from, window_to, service_id, duration, vehicle_id, status, created_at, queued_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`
+
+	var vehicleId sql.NullInt64
+	if e.VehicleId != 0 {
+		vehicleId = sql.NullInt64{Int64: e.VehicleId, Valid: true}
+	}
+	e.Status, e.OfferDatetime, e.OfferExpiresAt, e.MissedOffers = WaitlistWaiting, 0, 0, 0
+	err = s.db.QueryRow(
+		q, e.UserId, e.Day, from, to, e.ServiceId, int(e.Duration.Minutes()), vehicleId, e.Status, now, now,
+	).Scan(&e.Id)
+	if err != nil {
+		return WaitlistEntry{}, fmt.Errorf("failed to save waitlist entry: %w", err)
+	}
+
+	return e, nil
+}
+
+func (s *Store) Waitlist() ([]WaitlistEntry, error) {
+	q := waitlistQuery + ` WHERE ` + waitlistActive + ` ORDER BY queued_at, id`
+
+	rows, err := s.db.Query(q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get waitlist: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []WaitlistEntry
+	for rows.Next() {
+		e, err := scanWaitlist(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get waitlist: %w", err)
+	}
+
+	return entries, nil
+}
+
+// OfferWaitlist предлагает заявке id время datetime до expiresAt. Если заявка уже
+// не ждёт (её сняли или забрали), возвращается ErrNoWaitlist.
+func (s *Store) OfferWaitlist(id, datetime, expiresAt int64) error {
+	q := `UPDATE waitlist SET status=?, offer_datetime=?, offer_expires_at=? WHERE id=? AND status=?`
+
+	res, err := s.db.Exec(q, WaitlistOffered, datetime, expiresAt, id, WaitlistWaiting)
+	if err != nil {
+		return fmt.Errorf("failed to offer waitlist slot: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to offer waitlist slot: %w", err)
+	}
+	if n == 0 {
+		return ErrNoWaitlist
+	}
+
+	return nil
+}
+
+// ClaimWaitlist записывает клиента userId на предложенное заявкой id время и отмечает
+// заявку забранной - всё в одной транзакции. Если предложения нет или оно истекло
+// к now - ErrNoWaitlist, если время всё же заняли - ErrSlotTaken; заявка тогда не меняется.
+func (s *Store) ClaimWaitlist(id, userId, now int64, bays int) (Record, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	q := `UPDATE waitlist SET status=? WHERE id=? AND user_id=? AND status=? AND offer_expires_at > ?`
+	res, err := tx.Exec(q, WaitlistClaimed, id, userId, WaitlistOffered, now)
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to claim waitlist slot: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to claim waitlist slot: %w", err)
+	}
+	if n == 0 {
+		return Record{}, ErrNoWaitlist
+	}
+
+	e, err := scanWaitlist(tx.QueryRow(waitlistQuery+` WHERE id=?`, id))
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to get waitlist entry: %w", err)
+	}
+	// Удалённый за это время автомобиль просто не привязывается к записи.
+	if e.VehicleId != 0 {
+		var found int
+		err := tx.QueryRow(`SELECT COUNT(*) FROM vehicles WHERE id=? AND user_id=? AND removed_at IS NULL`, e.VehicleId, userId).Scan(&found)
+		if err != nil {
+			return Record{}, fmt.Errorf("failed to get vehicle: %w", err)
+		}
+		if found == 0 {
+			e.VehicleId = 0
+		}
+	}
+
+	record := Record{
+		UserId:    userId,
+		Datetime:  e.OfferDatetime,
+		ServiceId: e.ServiceId,
+		Duration:  e.Duration,
+		Status:    StatusPending,
+		Vehicle:   Vehicle{Id: e.VehicleId, UserId: userId},
+	}
+	if err := insertRecord(tx, &record, bays, now); err != nil {
+		return Record{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Record{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return record, nil
+}
+
+// RequeueWaitlist возвращает заявку, клиент которой пропустил предложение, в конец
+// очереди на момент now.
+func (s *Store) RequeueWaitlist(id, now int64) error {
+	q := `UPDATE waitlist SET status=?, offer_datetime=NULL, offer_expires_at=NULL, queued_at=?, missed_offers=missed_offers+1
+		WHERE id=? AND status=?`
+
+	if _, err := s.db.Exec(q, WaitlistWaiting, now, id, WaitlistOffered); err != nil {
+		return fmt.Errorf("failed to requeue waitlist entry: %w", err)
+	}
+
+	return nil
+}
+
+// SetWaitlistStatus меняет статус заявки. Возврат в WaitlistWaiting снимает предложение.
+func (s *Store) SetWaitlistStatus(id int64, status string) error {
+	q := `UPDATE waitlist SET status=? WHERE id=?`
+	if status == WaitlistWaiting {
+		q = `UPDATE waitlist SET status=?, offer_datetime=NULL, offer_expires_at=NULL WHERE id=?`
+	}
+
+	if _, err := s.db.Exec(q, status, id); err != nil {
+		return fmt.Errorf("failed to set waitlist status: %w", err)
+	}
+
+	return nil
+}
+
+// CancelWaitlist снимает заявку клиента userId. Возвращает false, если заявка уже неактивна.
+func (s *Store) CancelWaitlist(id, userId int64) (bool, error) {
+	q := `UPDATE waitlist SET status=? WHERE id=? AND user_id=? AND ` + waitlistActive
+
+	res, err := s.db.Exec(q, WaitlistCancelled, id, userId)
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel waitlist entry: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel waitlist entry: %w", err)
+	}
+
+	return n > 0, nil
+}
@@ -0,0 +1,165 @@
+package db_test
+
+import (
+	"automobile36/internal/db"
+	"automobile36/internal/db/dbtest"
+	"automobile36/internal/schedule"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitlistDay - понедельник, на который клиенты ждут время, и момент, когда они встали в очередь.
+var (
+	waitlistDay = time.Date(2026, 10, 19, 0, 0, 0, 0, dbtest.Location)
+	waitlistNow = time.Date(2026, 10, 18, 12, 0, 0, 0, dbtest.Location).Unix()
+)
+
+func addWaitlist(t *testing.T, s *db.Store, userId int64, from, to time.Duration, now int64) db.WaitlistEntry {
+	t.Helper()
+
+	e, err := s.AddWaitlist(db.WaitlistEntry{
+		UserId:   userId,
+		Day:      waitlistDay.Unix(),
+		From:     schedule.Clock(from),
+		To:       schedule.Clock(to),
+		Duration: time.Hour,
+	}, now)
+	if err != nil {
+		t.Fatalf("AddWaitlist(): %s", err)
+	}
+
+	return e
+}
+
+func TestAddWaitlistDuplicate(t *testing.T) {
+	dbtest.Each(t, func(t *testing.T, s *db.Store) {
+		first := addWaitlist(t, s, 1, 0, 24*time.Hour, waitlistNow)
+		if again := addWaitlist(t, s, 1, 0, 24*time.Hour, waitlistNow+60); again.Id != first.Id {
+			t.Errorf("AddWaitlist() for the same window = id %d, want existing %d", again.Id, first.Id)
+		}
+		if other := addWaitlist(t, s, 1, 0, 12*time.Hour, waitlistNow); other.Id == first.Id {
+			t.Errorf("AddWaitlist() for another window returned the existing entry")
+		}
+		if other := addWaitlist(t, s, 2, 0, 24*time.Hour, waitlistNow); other.Id == first.Id {
+			t.Errorf("AddWaitlist() for another client returned the existing entry")
+		}
+
+		// Снятая заявка не мешает встать в очередь снова.
+		if ok, err := s.CancelWaitlist(first.Id, 1); err != nil || !ok {
+			t.Fatalf("CancelWaitlist() = %v, %v", ok, err)
+		}
+		if again := addWaitlist(t, s, 1, 0, 24*time.Hour, waitlistNow); again.Id == first.Id {
+			t.Errorf("AddWaitlist() after cancel returned the cancelled entry")
+		}
+	})
+}
+
+func TestOfferCancelledWaitlist(t *testing.T) {
+	dbtest.Each(t, func(t *testing.T, s *db.Store) {
+		e := addWaitlist(t, s, 1, 0, 24*time.Hour, waitlistNow)
+		if _, err := s.CancelWaitlist(e.Id, 1); err != nil {
+			t.Fatal(err)
+		}
+
+		at := waitlistDay.Add(9 * time.Hour).Unix()
+		if err := s.OfferWaitlist(e.Id, at, waitlistNow+1800); !errors.Is(err, db.ErrNoWaitlist) {
+			t.Fatalf("OfferWaitlist() for a cancelled entry: err = %v, want ErrNoWaitlist", err)
+		}
+	})
+}
+
+func TestClaimWaitlist(t *testing.T) {
+	at := waitlistDay.Add(9 * time.Hour).Unix()
+	expires := waitlistNow + 1800
+
+	dbtest.Each(t, func(t *testing.T, s *db.Store) {
+		e := addWaitlist(t, s, 1, 0, 24*time.Hour, waitlistNow)
+		if _, err := s.ClaimWaitlist(e.Id, 1, waitlistNow, 1); !errors.Is(err, db.ErrNoWaitlist) {
+			t.Fatalf("ClaimWaitlist() without an offer: err = %v, want ErrNoWaitlist", err)
+		}
+		if err := s.OfferWaitlist(e.Id, at, expires); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := s.ClaimWaitlist(e.Id, 2, waitlistNow, 1); !errors.Is(err, db.ErrNoWaitlist) {
+			t.Errorf("ClaimWaitlist() by another client: err = %v, want ErrNoWaitlist", err)
+		}
+		if _, err := s.ClaimWaitlist(e.Id, 1, expires, 1); !errors.Is(err, db.ErrNoWaitlist) {
+			t.Errorf("ClaimWaitlist() after expiry: err = %v, want ErrNoWaitlist", err)
+		}
+
+		record, err := s.ClaimWaitlist(e.Id, 1, expires-1, 1)
+		if err != nil {
+			t.Fatalf("ClaimWaitlist() before expiry: %s", err)
+		}
+		if record.Datetime != at || record.UserId != 1 || record.Bay != 1 || record.Status != db.StatusPending {
+			t.Errorf("ClaimWaitlist() = %+v, want a pending record at %d on bay 1", record, at)
+		}
+		if _, err := s.ClaimWaitlist(e.Id, 1, expires-1, 1); !errors.Is(err, db.ErrNoWaitlist) {
+			t.Errorf("second ClaimWaitlist(): err = %v, want ErrNoWaitlist", err)
+		}
+
+		entries, err := s.Waitlist()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("Waitlist() after claim = %+v, want empty", entries)
+		}
+	})
+}
+
+func TestClaimWaitlistTaken(t *testing.T) {
+	at := waitlistDay.Add(9 * time.Hour).Unix()
+
+	dbtest.Each(t, func(t *testing.T, s *db.Store) {
+		e := addWaitlist(t, s, 1, 0, 24*time.Hour, waitlistNow)
+		if err := s.OfferWaitlist(e.Id, at, waitlistNow+1800); err != nil {
+			t.Fatal(err)
+		}
+		// Сотрудник записал клиента без Telegram на единственный пост, не глядя на лист ожидания.
+		if err := s.Exec(`INSERT INTO records (user_id, datetime, bay, duration, status) VALUES (?, ?, ?, ?, ?)`,
+			0, at, 1, 60, db.StatusConfirmed); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := s.ClaimWaitlist(e.Id, 1, waitlistNow, 1); !errors.Is(err, db.ErrSlotTaken) {
+			t.Fatalf("ClaimWaitlist() over a taken slot: err = %v, want ErrSlotTaken", err)
+		}
+		entries, err := s.Waitlist()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 || entries[0].Status != db.WaitlistOffered {
+			t.Errorf("Waitlist() after failed claim = %+v, want the entry still offered", entries)
+		}
+	})
+}
+
+func TestRequeueWaitlist(t *testing.T) {
+	at := waitlistDay.Add(9 * time.Hour).Unix()
+
+	dbtest.Each(t, func(t *testing.T, s *db.Store) {
+		first := addWaitlist(t, s, 1, 0, 24*time.Hour, waitlistNow)
+		second := addWaitlist(t, s, 2, 0, 24*time.Hour, waitlistNow+1)
+		if err := s.OfferWaitlist(first.Id, at, waitlistNow+1800); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RequeueWaitlist(first.Id, waitlistNow+1800); err != nil {
+			t.Fatal(err)
+		}
+
+		entries, err := s.Waitlist()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 2 || entries[0].Id != second.Id || entries[1].Id != first.Id {
+			t.Fatalf("Waitlist() after requeue = %+v, want %d then %d", entries, second.Id, first.Id)
+		}
+		requeued := entries[1]
+		if requeued.Status != db.WaitlistWaiting || requeued.OfferDatetime != 0 || requeued.OfferExpiresAt != 0 || requeued.MissedOffers != 1 {
+			t.Errorf("requeued entry = %+v, want waiting without an offer and one missed offer", requeued)
+		}
+	})
+}
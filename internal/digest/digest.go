@@ -0,0 +1,112 @@
+// Package digest собирает расписание записей на день для группы сотрудников
+// и каждое утро отправляет его туда.
+package digest
+
+import (
+	"automobile36/internal/clock"
+	"automobile36/internal/config"
+	"automobile36/internal/db"
+	"automobile36/internal/sender"
+	"automobile36/internal/utils"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+)
+
+// Text - расписание на день day в HTML: записи по времени и постам
+// с контактами клиентов.
+func Text(records db.RecordRepository, day time.Time) (string, error) {
+	day = utils.Day(day)
+	list, err := records.DayRecords(day.Unix(), day.AddDate(0, 0, 1).Unix())
+	if err != nil {
+		return "", fmt.Errorf("error while getting day records: %w", err)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<b>Записи на %s, %s</b>", day.Format("02.01.2006"), utils.ShortWeekday(day))
+	if len(list) == 0 {
+		sb.WriteString("\nЗаписей нет")
+		return sb.String(), nil
+	}
+
+	for _, r := range list {
+		start := time.Unix(r.Datetime, 0).In(day.Location())
+		fmt.Fprintf(&sb, "\n\n%s %s–%s, пост %d",
+			utils.StatusIcon(r.Status), start.Format("15:04"), start.Add(r.Duration).Format("15:04"), r.Bay)
+		if r.ServiceName != "" {
+			fmt.Fprintf(&sb, "\n%s", html.EscapeString(r.ServiceName))
+		}
+		if r.Vehicle.Id != 0 {
+			fmt.Fprintf(&sb, "\n🚗 %s", html.EscapeString(utils.VehicleTitle(r.Vehicle)))
+		}
+		if r.PickupRack != "" {
+			fmt.Fprintf(&sb, "\n🛞 Выдать шины с хранения, стеллаж %s", html.EscapeString(r.PickupRack))
+		}
+		if r.Name != "" || r.Phone != "" {
+			fmt.Fprintf(&sb, "\n%s, %s", html.EscapeString(r.Name), r.Phone)
+		}
+	}
+	fmt.Fprintf(&sb, "\n\nВсего: %d", len(list))
+
+	return sb.String(), nil
+}
+
+type Scheduler struct {
+	sender  sender.Sender
+	records db.RecordRepository
+	clock   clock.Clock
+	chatId  int64
+	cfg     config.Digest
+}
+
+func New(sender sender.Sender, records db.RecordRepository, clock clock.Clock, cfg *config.Config) *Scheduler {
+	return &Scheduler{
+		sender:  sender,
+		records: records,
+		clock:   clock,
+		chatId:  cfg.RecordsChatID,
+		cfg:     cfg.Digest,
+	}
+}
+
+// Next возвращает ближайший после now момент отправки сводки.
+func (s *Scheduler) Next(now time.Time) time.Time {
+	next := utils.Day(now).Add(time.Duration(s.cfg.Time))
+	if !next.After(now) {
+		next = utils.Day(now).AddDate(0, 0, 1).Add(time.Duration(s.cfg.Time))
+	}
+
+	return next
+}
+
+// Run отправляет сводку каждый день в digest.time и не возвращается.
+// Если сводка отключена, сразу выходит.
+func (s *Scheduler) Run() {
+	if !s.cfg.Enabled {
+		return
+	}
+
+	for next := s.Next(s.clock.Now()); ; next = s.Next(next) {
+		time.Sleep(next.Sub(s.clock.Now()))
+		if err := s.Send(next); err != nil {
+			log.Println("failed to send digest:", err.Error())
+		}
+	}
+}
+
+// Send отправляет в группу сотрудников расписание на день day.
+func (s *Scheduler) Send(day time.Time) error {
+	t, err := Text(s.records, day)
+	if err != nil {
+		return err
+	}
+	if _, err := s.sender.SendMessage(s.chatId, t, &gotgbot.SendMessageOpts{ParseMode: "html"}); err != nil {
+		return fmt.Errorf("error while sending digest: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package sessions
+
+import (
+	"automobile36/internal/db"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers"
+)
+
+var roleTitles = map[string]string{
+	db.RoleOwner:    "владелец",
+	db.RoleManager:  "менеджер",
+	db.RoleMechanic: "мастер",
+}
+
+const adminUsage = `Команды:
+/admin list — сотрудники
+/admin add ID роль — назначить роль (owner, manager, mechanic)
+/admin remove ID — убрать из сотрудников
+Вместо ID можно ответить командой на сообщение сотрудника.`
+
+func isOwner(userId int64) bool {
+	for _, id := range conf.Owners {
+		if id == userId {
+			return true
+		}
+	}
+
+	return false
+}
+
+// senderRole возвращает роль отправителя: владельца из конфига или роль из таблицы admins.
+func senderRole(ctx *ext.Context) (string, error) {
+	userId := ctx.EffectiveSender.Id()
+	if isOwner(userId) {
+		return db.RoleOwner, nil
+	}
+
+	role, err := adminRepo.GetRole(userId)
+	if err != nil {
+		return "", fmt.Errorf("error while getting role: %w", err)
+	}
+
+	return role, nil
+}
+
+// requireRole пропускает обновление в handler, только если у отправителя есть роль role
+// или более высокая.
+func requireRole(role string, handler handlers.Response) handlers.Response {
+	return func(b *gotgbot.Bot, ctx *ext.Context) error {
+		have, err := senderRole(ctx)
+		if err != nil {
+			return err
+		}
+		if db.RoleAllows(have, role) {
+			return handler(b, ctx)
+		}
+
+		if ctx.Update.CallbackQuery != nil {
+			return answerStaff(b, ctx, "Недостаточно прав для этого действия")
+		}
+		if _, err := ctx.EffectiveMessage.Reply(b, "Недостаточно прав для этой команды", nil); err != nil {
+			return fmt.Errorf("error while denying access: %w", err)
+		}
+
+		return nil
+	}
+}
+
+// adminTarget - пользователь, к которому относится команда: из ответа на
+// сообщение или из первого аргумента. Возвращает оставшиеся аргументы.
+func adminTarget(ctx *ext.Context, args []string) (int64, []string, bool) {
+	if reply := ctx.EffectiveMessage.ReplyToMessage; reply != nil && reply.From != nil {
+		return reply.From.Id, args, true
+	}
+	if len(args) == 0 {
+		return 0, nil, false
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return 0, nil, false
+	}
+
+	return id, args[1:], true
+}
+
+// Admin управляет сотрудниками: /admin list|add|remove.
+func Admin(b *gotgbot.Bot, ctx *ext.Context) error {
+	args := ctx.Args()[1:]
+
+	var (
+		t   string
+		err error
+	)
+	switch {
+	case len(args) == 0:
+		t = adminUsage
+	case args[0] == "list":
+		t, err = listAdmins()
+	case args[0] == "add":
+		t, err = addAdmin(ctx, args[1:])
+	case args[0] == "remove":
+		t, err = removeAdmin(ctx, args[1:])
+	default:
+		t = adminUsage
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := ctx.EffectiveMessage.Reply(b, t, &gotgbot.SendMessageOpts{ParseMode: "html"}); err != nil {
+		return fmt.Errorf("error while answering admin command: %w", err)
+	}
+
+	return nil
+}
+
+func listAdmins() (string, error) {
+	admins, err := adminRepo.Admins()
+	if err != nil {
+		return "", fmt.Errorf("error while getting admins: %w", err)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<b>Сотрудники</b>")
+	for _, id := range conf.Owners {
+		fmt.Fprintf(&sb, "\n<a href=\"tg://user?id=%d\">%d</a> — %s (из конфига)", id, id, roleTitles[db.RoleOwner])
+	}
+	for _, a := range admins {
+		fmt.Fprintf(&sb, "\n<a href=\"tg://user?id=%d\">%d</a> — %s, с %s",
+			a.UserId, a.UserId, roleTitles[a.Role], time.Unix(a.AddedAt, 0).In(conf.Shop.Location).Format(dateLayout))
+	}
+
+	return sb.String(), nil
+}
+
+func addAdmin(ctx *ext.Context, args []string) (string, error) {
+	userId, rest, ok := adminTarget(ctx, args)
+	if !ok || len(rest) != 1 || !db.ValidRole(rest[0]) {
+		return adminUsage, nil
+	}
+	if isOwner(userId) {
+		return "Владельцы из конфига меняются только в конфиге", nil
+	}
+
+	if err := adminRepo.SetAdmin(userId, rest[0], ctx.EffectiveSender.Id()); err != nil {
+		return "", fmt.Errorf("error while adding admin: %w", err)
+	}
+
+	return fmt.Sprintf("Пользователь %d теперь %s", userId, roleTitles[rest[0]]), nil
+}
+
+func removeAdmin(ctx *ext.Context, args []string) (string, error) {
+	userId, rest, ok := adminTarget(ctx, args)
+	if !ok || len(rest) != 0 {
+		return adminUsage, nil
+	}
+	if isOwner(userId) {
+		return "Владельцы из конфига меняются только в конфиге", nil
+	}
+
+	removed, err := adminRepo.RemoveAdmin(userId)
+	if err != nil {
+		return "", fmt.Errorf("error while removing admin: %w", err)
+	}
+	if !removed {
+		return fmt.Sprintf("Пользователь %d не сотрудник", userId), nil
+	}
+
+	return fmt.Sprintf("Пользователь %d больше не сотрудник", userId), nil
+}
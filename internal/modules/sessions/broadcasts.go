@@ -0,0 +1,274 @@
+package sessions
+
+import (
+	"automobile36/internal/broadcast"
+	"automobile36/internal/db"
+	"automobile36/internal/utils"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/filters/callbackquery"
+)
+
+const (
+	BROADCAST_MESSAGE  = "broadcast_message"
+	BROADCAST_AUDIENCE = "broadcast_audience"
+	BROADCAST_CONFIRM  = "broadcast_confirm"
+)
+
+// broadcastData - рассылка, которую сотрудник готовит к отправке.
+type broadcastData struct {
+	Text     string
+	PhotoId  string
+	Audience string
+}
+
+func loadBroadcastHandlers(dp *ext.Dispatcher) {
+	dp.AddHandler(handlers.NewConversation(
+		[]ext.Handler{handlers.NewCommand("broadcast", staffOnly(db.RoleManager, StartBroadcast))},
+		map[string][]ext.Handler{
+			BROADCAST_MESSAGE: {handlers.NewMessage(utils.BroadcastInput, BroadcastMessage)},
+			BROADCAST_AUDIENCE: {
+				handlers.NewCallback(callbackquery.Prefix(utils.BroadcastAudiencePrefix), BroadcastAudience),
+				handlers.NewCallback(callbackquery.Equal(utils.BroadcastCancel), CancelBroadcast),
+			},
+			BROADCAST_CONFIRM: {
+				handlers.NewCallback(callbackquery.Equal(utils.BroadcastSend), SendBroadcast),
+				handlers.NewCallback(callbackquery.Equal(utils.BroadcastCancel), CancelBroadcast),
+			},
+		},
+		&handlers.ConversationOpts{
+			Fallbacks:    []ext.Handler{handlers.NewCommand("cancel", CancelBroadcast)},
+			StateStorage: conversationStorage("broadcast"),
+		},
+	))
+}
+
+var (
+	broadcasterOnce sync.Once
+	broadcaster     *broadcast.Broadcaster
+)
+
+// getBroadcaster возвращает общий для всех рассылок Broadcaster, чтобы они шли по очереди.
+func getBroadcaster(b *gotgbot.Bot) *broadcast.Broadcaster {
+	broadcasterOnce.Do(func() {
+		broadcaster = broadcast.New(b, broadcastRepo, conf.Broadcast)
+	})
+
+	return broadcaster
+}
+
+// broadcastKey - черновик рассылки хранится отдельно для каждого сотрудника, ведь чат у них общий.
+func broadcastKey(ctx *ext.Context) string {
+	return "broadcast_" + strconv.FormatInt(ctx.EffectiveSender.Id(), 10)
+}
+
+// audienceUsers возвращает получателей аудитории в текущем сезоне.
+func audienceUsers(audience string) ([]int64, error) {
+	users, err := broadcastRepo.AudienceUsers(audience, broadcast.SeasonStart(utils.Now()).Unix())
+	if err != nil {
+		return nil, fmt.Errorf("error while getting audience: %w", err)
+	}
+
+	return users, nil
+}
+
+// StartBroadcast просит сотрудника прислать текст или фото рассылки ответом на сообщение бота.
+func StartBroadcast(b *gotgbot.Bot, ctx *ext.Context) error {
+	user := ctx.EffectiveSender.User
+	if _, err := ctx.EffectiveChat.SendMessage(
+		b,
+		fmt.Sprintf(`<a href="tg://user?id=%d">%s</a>, пришлите текст рассылки или фото с подписью ответом на это сообщение или /cancel`, user.Id, html.EscapeString(user.FirstName)),
+		&gotgbot.SendMessageOpts{
+			ParseMode:   "html",
+			ReplyMarkup: gotgbot.ForceReply{ForceReply: true, Selective: true, InputFieldPlaceholder: "Текст рассылки"},
+		},
+	); err != nil {
+		return fmt.Errorf("error while asking for broadcast message: %w", err)
+	}
+
+	return handlers.NextConversationState(BROADCAST_MESSAGE)
+}
+
+// BroadcastMessage сохраняет текст рассылки и предлагает выбрать аудиторию.
+func BroadcastMessage(b *gotgbot.Bot, ctx *ext.Context) error {
+	msg := ctx.EffectiveMessage
+	data := broadcastData{Text: msg.Text}
+	if len(msg.Photo) > 0 {
+		data = broadcastData{Text: msg.Caption, PhotoId: msg.Photo[len(msg.Photo)-1].FileId}
+	}
+	if strings.TrimSpace(data.Text) == "" && data.PhotoId == "" {
+		return nil
+	}
+	if err := setData(ctx, broadcastKey(ctx), data); err != nil {
+		return err
+	}
+
+	counts := make(map[string]int)
+	for _, audience := range []string{db.AudienceAll, db.AudienceStorage, db.AudienceNotBooked} {
+		users, err := audienceUsers(audience)
+		if err != nil {
+			return err
+		}
+		counts[audience] = len(users)
+	}
+
+	if _, err := msg.Reply(b, "Кому отправить рассылку?", &gotgbot.SendMessageOpts{
+		ReplyMarkup: utils.GetBroadcastAudienceKeyboard(counts),
+	}); err != nil {
+		return fmt.Errorf("error while asking for broadcast audience: %w", err)
+	}
+
+	return handlers.NextConversationState(BROADCAST_AUDIENCE)
+}
+
+// BroadcastAudience показывает рассылку так, как её увидят клиенты, и просит подтвердить отправку.
+func BroadcastAudience(b *gotgbot.Bot, ctx *ext.Context) error {
+	audience := strings.TrimPrefix(ctx.Update.CallbackQuery.Data, utils.BroadcastAudiencePrefix)
+	if !db.ValidAudience(audience) {
+		return answerStaff(b, ctx, "Неизвестная аудитория")
+	}
+	var data broadcastData
+	err := getData(ctx, broadcastKey(ctx), &data)
+	if errors.Is(err, errNoData) {
+		if err := answerStaff(b, ctx, "Начните заново командой /broadcast"); err != nil {
+			return err
+		}
+		return handlers.EndConversation()
+	}
+	if err != nil {
+		return err
+	}
+	users, err := audienceUsers(audience)
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		return answerStaff(b, ctx, "В этой аудитории нет клиентов")
+	}
+
+	data.Audience = audience
+	if err := setData(ctx, broadcastKey(ctx), data); err != nil {
+		return err
+	}
+
+	if _, _, err := ctx.EffectiveMessage.EditText(b, "Так клиенты увидят рассылку:", nil); err != nil {
+		return fmt.Errorf("error while previewing broadcast: %w", err)
+	}
+	bc := db.Broadcast{Text: data.Text, PhotoId: data.PhotoId}
+	if err := getBroadcaster(b).Send(conf.RecordsChatID, bc); err != nil {
+		return fmt.Errorf("error while previewing broadcast: %w", err)
+	}
+	if _, err := b.SendMessage(
+		conf.RecordsChatID,
+		fmt.Sprintf("Аудитория: %s\nПолучателей: %d\nОтправить?", utils.AudienceTitle(audience), len(users)),
+		&gotgbot.SendMessageOpts{ReplyMarkup: utils.GetBroadcastConfirmKeyboard()},
+	); err != nil {
+		return fmt.Errorf("error while previewing broadcast: %w", err)
+	}
+	if err := answerStaff(b, ctx, ""); err != nil {
+		return err
+	}
+
+	return handlers.NextConversationState(BROADCAST_CONFIRM)
+}
+
+// SendBroadcast запускает рассылку. Получатели определяются заново в момент отправки.
+func SendBroadcast(b *gotgbot.Bot, ctx *ext.Context) error {
+	var data broadcastData
+	err := getData(ctx, broadcastKey(ctx), &data)
+	if errors.Is(err, errNoData) {
+		if err := answerStaff(b, ctx, "Начните заново командой /broadcast"); err != nil {
+			return err
+		}
+		return handlers.EndConversation()
+	}
+	if err != nil {
+		return err
+	}
+	users, err := audienceUsers(data.Audience)
+	if err != nil {
+		return err
+	}
+
+	bc, err := broadcastRepo.CreateBroadcast(db.Broadcast{
+		Text:      data.Text,
+		PhotoId:   data.PhotoId,
+		Audience:  data.Audience,
+		CreatedBy: ctx.EffectiveSender.Id(),
+	}, users)
+	if err != nil {
+		return fmt.Errorf("error while creating broadcast: %w", err)
+	}
+
+	if _, _, err := ctx.EffectiveMessage.EditText(
+		b,
+		fmt.Sprintf("Рассылка №%d запущена: %s, получателей %d\n%s",
+			bc.Id, utils.AudienceTitle(bc.Audience), len(users), actionLine("📣 Отправил", ctx.EffectiveSender.User)),
+		nil,
+	); err != nil {
+		return fmt.Errorf("error while starting broadcast: %w", err)
+	}
+	go runBroadcast(b, bc)
+
+	if err := answerStaff(b, ctx, ""); err != nil {
+		return err
+	}
+
+	return handlers.EndConversation()
+}
+
+func CancelBroadcast(b *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.Update.CallbackQuery != nil {
+		if _, _, err := ctx.EffectiveMessage.EditText(b, "Рассылка отменена", nil); err != nil {
+			return fmt.Errorf("error while cancelling broadcast: %w", err)
+		}
+		if err := answerStaff(b, ctx, ""); err != nil {
+			return err
+		}
+		return handlers.EndConversation()
+	}
+
+	if _, err := ctx.EffectiveMessage.Reply(b, "Рассылка отменена", nil); err != nil {
+		return fmt.Errorf("error while cancelling broadcast: %w", err)
+	}
+
+	return handlers.EndConversation()
+}
+
+// runBroadcast отправляет рассылку и сообщает сотрудникам, сколько сообщений дошло.
+func runBroadcast(b *gotgbot.Bot, bc db.Broadcast) {
+	stats, err := getBroadcaster(b).Run(bc)
+	if err != nil {
+		log.Printf("failed to send broadcast %d: %s", bc.Id, err)
+		return
+	}
+
+	if err := notifyStaff(b, fmt.Sprintf(
+		"Рассылка №%d завершена\nДоставлено: %d\nБот заблокирован: %d\nОшибки: %d",
+		bc.Id, stats.Delivered, stats.Blocked, stats.Failed,
+	)); err != nil {
+		log.Println(err)
+	}
+}
+
+// ResumeBroadcasts досылает рассылки, прерванные перезапуском бота.
+func ResumeBroadcasts(b *gotgbot.Bot) {
+	broadcasts, err := broadcastRepo.UnfinishedBroadcasts()
+	if err != nil {
+		log.Println("failed to resume broadcasts:", err.Error())
+		return
+	}
+
+	for _, bc := range broadcasts {
+		runBroadcast(b, bc)
+	}
+}
@@ -1,7 +1,6 @@
 package sessions
 
 import (
-	"automobile36/internal/db"
 	"automobile36/internal/utils"
 	"fmt"
 	"github.com/PaulSonOfLars/gotgbot/v2"
@@ -9,6 +8,7 @@ import (
 	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/filters/message"
 	"os"
+	"strings"
 )
 
 func LoadMenuHandlers(dp *ext.Dispatcher) {
@@ -22,14 +22,14 @@ func SendRecordsMenu(b *gotgbot.Bot, ctx *ext.Context) error {
 	if ctx.EffectiveChat.Type != "private" {
 		return nil
 	}
-	name, number, err := db.GetInfo(int(ctx.EffectiveChat.Id))
+	name, number, err := userRepo.GetInfo(ctx.EffectiveChat.Id)
 	if err != nil {
 		return fmt.Errorf("error while getting info about user: %w", err)
 	}
 
 	t := fmt.Sprintf(`<b>Ваши данные</b>
 <b>Имя: %s</b>
-<b>Номер телефона: %d</b>
+<b>Номер телефона: %s</b>
 Чтобы изменить номер, нажмите на кнопку "Изменить номер телефона".
 Чтобы записаться нажмите "Добавить запись".`, name, number)
 	if _, err := ctx.EffectiveChat.SendMessage(b, t, &gotgbot.SendMessageOpts{
@@ -46,17 +46,65 @@ func SendPrice(b *gotgbot.Bot, ctx *ext.Context) error {
 	if ctx.EffectiveChat.Type != "private" {
 		return nil
 	}
-	file, err := os.Open("img/price.jpg")
+	services, err := catalogRepo.GetServices()
+	if err != nil {
+		return fmt.Errorf("error while getting services: %w", err)
+	}
+	prices, err := catalogRepo.GetPrices()
+	if err != nil {
+		return fmt.Errorf("error while getting prices: %w", err)
+	}
+
+	if _, err := ctx.EffectiveChat.SendMessage(b, utils.FormatPriceList(services, prices), &gotgbot.SendMessageOpts{
+		ParseMode: "html",
+	}); err != nil {
+		return fmt.Errorf("error while sending price list: %w", err)
+	}
+
+	if conf.Shop.PriceImage == "" {
+		return nil
+	}
+
+	return sendPriceImage(b, ctx.EffectiveChat.Id)
+}
+
+// sendPriceImage отправляет картинку с прайсом. После первой загрузки файл
+// отправляется по file_id, пока не изменится на диске.
+func sendPriceImage(b *gotgbot.Bot, chatId int64) error {
+	path := conf.Shop.PriceImage
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("error while opening file: %w", err)
+	}
+	modTime := info.ModTime().Unix()
+
+	fileId, cachedModTime, found, err := catalogRepo.GetFileId(path)
+	if err != nil {
+		return fmt.Errorf("error while getting file id: %w", err)
+	}
+	if found && cachedModTime == modTime {
+		if _, err := b.SendPhoto(chatId, fileId, &gotgbot.SendPhotoOpts{}); err == nil {
+			return nil
+		}
+		// file_id мог устареть (например, сменился бот) - загружаем файл заново
+	}
+
+	file, err := os.Open(path)
 	if err != nil {
 		return fmt.Errorf("error while opening file: %w", err)
 	}
 	defer file.Close()
 
-	// Отправляем фото
-	_, err = b.SendPhoto(ctx.EffectiveChat.Id, file, &gotgbot.SendPhotoOpts{})
+	msg, err := b.SendPhoto(chatId, file, &gotgbot.SendPhotoOpts{})
 	if err != nil {
 		return fmt.Errorf("error while sending photo: %w", err)
 	}
+	if len(msg.Photo) > 0 {
+		largest := msg.Photo[len(msg.Photo)-1]
+		if err := catalogRepo.SaveFileId(path, largest.FileId, modTime); err != nil {
+			return fmt.Errorf("error while saving file id: %w", err)
+		}
+	}
 
 	return nil
 }
@@ -65,7 +113,11 @@ func SendContacts(b *gotgbot.Bot, ctx *ext.Context) error {
 	if ctx.EffectiveChat.Type != "private" {
 		return nil
 	}
-	_, err := ctx.EffectiveChat.SendMessage(b, "Номера телефонов:\n+7XXXXXXXXXX\n7XXXXXXXXXX\n\nМы ВК: https://vk.com/XXXXXXXXXXXX", nil)
+	t := "Номера телефонов:\n" + strings.Join(conf.Shop.Phones, "\n")
+	if conf.Shop.VK != "" {
+		t += "\n\nМы ВК: " + conf.Shop.VK
+	}
+	_, err := ctx.EffectiveChat.SendMessage(b, t, nil)
 	if err != nil {
 		return fmt.Errorf("error while sending contacts: %w", err)
 	}
@@ -77,7 +129,7 @@ func SendLocation(b *gotgbot.Bot, ctx *ext.Context) error {
 	if ctx.EffectiveChat.Type != "private" {
 		return nil
 	}
-	_, err := b.SendLocation(ctx.EffectiveChat.Id, 55.754029, 37.620743, nil)
+	_, err := b.SendLocation(ctx.EffectiveChat.Id, conf.Shop.Latitude, conf.Shop.Longitude, nil)
 	if err != nil {
 		return fmt.Errorf("error while sending location: %w", err)
 	}
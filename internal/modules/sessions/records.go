@@ -2,51 +2,114 @@ package sessions
 
 import (
 	"automobile36/internal/db"
+	"automobile36/internal/schedule"
 	"automobile36/internal/utils"
+	"errors"
 	"fmt"
 	"github.com/PaulSonOfLars/gotgbot/v2"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers"
-	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/conversation"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/filters/callbackquery"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/filters/message"
-	"github.com/patrickmn/go-cache"
 	"strconv"
+	"strings"
 	"time"
 )
 
 const (
-	SELECT = "select"
-	TIME   = "time"
-	CHANGE = "change"
+	VEHICLE = "vehicle"
+	SERVICE = "service"
+	SELECT  = "select"
+	TIME    = "time"
+	CHANGE  = "change"
 )
 
-var recordsCache = cache.New(5*time.Minute, 10*time.Minute)
-
 func LoadRecordsHandlers(dp *ext.Dispatcher) {
 	dp.AddHandler(handlers.NewConversation(
 		[]ext.Handler{handlers.NewMessage(message.Equal("Добавить запись 📝"), AddNewRecord)},
 		map[string][]ext.Handler{
-			SELECT:  {handlers.NewCallback(utils.DateSelection, ProcessSelection)},
-			TIME:    {handlers.NewCallback(utils.TimeSelection, SelectTime)},
+			VEHICLE: {handlers.NewCallback(callbackquery.Prefix(utils.RecordVehiclePrefix), SelectRecordVehicle)},
+			SERVICE: {handlers.NewCallback(utils.ServiceSelection, SelectService)},
+			SELECT:  {handlers.NewCallback(utils.DateSelection, ProcessSelection(newRecordFlow))},
+			TIME: {
+				handlers.NewCallback(utils.TimeSelection, SelectTime(newRecordFlow)),
+				handlers.NewCallback(callbackquery.Prefix(utils.WaitlistJoinPrefix), JoinWaitlist),
+				handlers.NewCallback(callbackquery.Equal(utils.OtherDate), ChooseOtherDate),
+			},
 			CONFIRM: {handlers.NewCallback(utils.Confirms, ConfirmRecord)},
 		},
 		&handlers.ConversationOpts{
-			StateStorage: conversation.NewInMemoryStorage(conversation.KeyStrategySenderAndChat),
+			StateStorage: conversationStorage("records"),
+		},
+	))
+	dp.AddHandler(handlers.NewConversation(
+		[]ext.Handler{handlers.NewCallback(callbackquery.Prefix(utils.MovePrefix), StartMoveRecord)},
+		map[string][]ext.Handler{
+			SELECT:  {handlers.NewCallback(utils.DateSelection, ProcessSelection(moveFlow))},
+			TIME:    {handlers.NewCallback(utils.TimeSelection, SelectTime(moveFlow))},
+			CONFIRM: {handlers.NewCallback(utils.Confirms, ConfirmMoveRecord)},
+		},
+		&handlers.ConversationOpts{
+			StateStorage: conversationStorage("move_record"),
 		},
 	))
 	dp.AddHandler(handlers.NewConversation(
 		[]ext.Handler{handlers.NewMessage(message.Equal("Изменить номер телефона 📱"), ChangePhoneNumber)},
 		map[string][]ext.Handler{
-			CHANGE:  {handlers.NewMessage(utils.NoCommands, AddNewNumber)},
+			CHANGE:  {handlers.NewMessage(utils.PhoneInput, AddNewNumber)},
 			CONFIRM: {handlers.NewCallback(utils.Confirms, ConfirmNewPhoneNumber)},
 		},
 		&handlers.ConversationOpts{
-			StateStorage: conversation.NewInMemoryStorage(conversation.KeyStrategySenderAndChat),
+			StateStorage: conversationStorage("change_number"),
 		},
 	))
 
 	dp.AddHandler(handlers.NewMessage(message.Equal("Ваши записи 📜"), ListAllRecords))
+	dp.AddHandler(handlers.NewCallback(callbackquery.Equal(utils.RecordsList), ShowRecordsList))
+	dp.AddHandler(handlers.NewCallback(callbackquery.Prefix(utils.RecordPrefix), ShowRecord))
+	dp.AddHandler(handlers.NewCallback(callbackquery.Prefix(utils.CancelPrefix), AskCancelRecord))
+	dp.AddHandler(handlers.NewCallback(callbackquery.Prefix(utils.CancelConfirmPrefix), CancelRecord))
+	dp.AddHandler(handlers.NewCallback(callbackquery.Prefix(utils.VisitPrefix), ConfirmVisit))
 	dp.AddHandler(handlers.NewMessage(message.Equal("Назад 👈"), GoBack))
+	loadVehicleHandlers(dp)
+	loadStorageHandlers(dp)
+	loadWaitlistHandlers(dp)
+}
+
+// recordFlow - диалог выбора даты и времени: новая запись или перенос. Клиент
+// может начать перенос посреди записи, поэтому у каждого диалога свои данные сессии.
+type recordFlow struct {
+	prefix   string
+	waitlist bool // предлагать лист ожидания, если день занят
+	move     bool // переносится запись, сохранённая под key("record")
+}
+
+var (
+	newRecordFlow = recordFlow{waitlist: true}
+	moveFlow      = recordFlow{prefix: "move_", move: true}
+)
+
+// key - имя данных сессии name в этом диалоге.
+func (f recordFlow) key(name string) string {
+	return f.prefix + name
+}
+
+// calendar - ключ, под которым хранится показанный в этом диалоге месяц календаря.
+func (f recordFlow) calendar(ctx *ext.Context) string {
+	return f.prefix + strconv.FormatInt(ctx.EffectiveChat.Id, 10)
+}
+
+// timesKeyboard - свободное время на день date. Переносимая запись своё время не
+// занимает, иначе клиенту не предложат то, на что MoveRecord согласится.
+func (f recordFlow) timesKeyboard(ctx *ext.Context, date time.Time, duration time.Duration) (gotgbot.InlineKeyboardMarkup, error) {
+	var moved db.Record
+	if f.move {
+		if err := getData(ctx, f.key("record"), &moved); err != nil {
+			return gotgbot.InlineKeyboardMarkup{}, err
+		}
+	}
+
+	return utils.GetTimesKeyboard(date, duration, moved.Id)
 }
 
 func AddNewRecord(b *gotgbot.Bot, ctx *ext.Context) error {
@@ -63,11 +126,105 @@ func AddNewRecord(b *gotgbot.Bot, ctx *ext.Context) error {
 		return fmt.Errorf("error while deleting message: %w", err)
 	}
 
+	vehicles, err := vehicleRepo.GetVehicles(ctx.EffectiveChat.Id)
+	if err != nil {
+		return fmt.Errorf("error while getting vehicles: %w", err)
+	}
+	if len(vehicles) == 0 {
+		if err := setData(ctx, newRecordFlow.key("vehicle"), db.Vehicle{}); err != nil {
+			return err
+		}
+		return askService(b, ctx)
+	}
+
+	if _, err := ctx.EffectiveChat.SendMessage(
+		b,
+		"На каком автомобиле приедете?",
+		&gotgbot.SendMessageOpts{ReplyMarkup: utils.GetRecordVehicleKeyboard(vehicles)},
+	); err != nil {
+		return fmt.Errorf("error while sending vehicles: %w", err)
+	}
+
+	return handlers.NextConversationState(VEHICLE)
+}
+
+// SelectRecordVehicle запоминает автомобиль для записи и переходит к выбору услуги.
+func SelectRecordVehicle(b *gotgbot.Bot, ctx *ext.Context) error {
+	id, err := utils.ParseCallbackId(ctx.Update.CallbackQuery.Data, utils.RecordVehiclePrefix)
+	if err != nil {
+		return fmt.Errorf("failed to parse vehicle id: %w", err)
+	}
+
+	var v db.Vehicle
+	if id != 0 {
+		// Удалённый за это время автомобиль просто не привязывается к записи.
+		v, err = vehicleRepo.GetVehicle(id, ctx.EffectiveChat.Id)
+		if err != nil && !errors.Is(err, db.ErrNoVehicle) {
+			return fmt.Errorf("error while getting vehicle: %w", err)
+		}
+	}
+	text := "Без автомобиля"
+	if v.Id != 0 {
+		text = "Автомобиль: " + utils.VehicleTitle(v)
+	}
+	if err := setData(ctx, newRecordFlow.key("vehicle"), v); err != nil {
+		return err
+	}
+	if _, _, err := ctx.EffectiveMessage.EditText(b, text, nil); err != nil {
+		return fmt.Errorf("error while selecting vehicle: %w", err)
+	}
+
+	return askService(b, ctx)
+}
+
+// chosenVehicle возвращает автомобиль, выбранный при записи; Id = 0, если без автомобиля.
+func chosenVehicle(ctx *ext.Context, f recordFlow) (db.Vehicle, error) {
+	var v db.Vehicle
+	err := getData(ctx, f.key("vehicle"), &v)
+
+	return v, err
+}
+
+func askService(b *gotgbot.Bot, ctx *ext.Context) error {
+	kb, err := utils.GetServicesKeyboard()
+	if err != nil {
+		return fmt.Errorf("error while getting services kb: %w", err)
+	}
 	if _, err := ctx.EffectiveChat.SendMessage(
 		b,
-		"Выберите дату",
+		"Выберите услугу",
 		&gotgbot.SendMessageOpts{
-			ReplyMarkup: utils.SimpleCalendar(strconv.Itoa(int(ctx.EffectiveChat.Id)), time.Now().Year(), time.Now().Month()),
+			ReplyMarkup: kb,
+		}); err != nil {
+		return fmt.Errorf("error while sending services: %w", err)
+	}
+
+	return handlers.NextConversationState(SERVICE)
+}
+
+func SelectService(b *gotgbot.Bot, ctx *ext.Context) error {
+	cb := ctx.Update.CallbackQuery
+	serviceId, err := utils.ParseCallbackId(cb.Data, utils.ServicePrefix)
+	if err != nil {
+		return fmt.Errorf("failed to parse service id: %w", err)
+	}
+	service, err := catalogRepo.GetService(serviceId)
+	if err != nil {
+		return fmt.Errorf("error while getting service: %w", err)
+	}
+	if err := setData(ctx, newRecordFlow.key("service"), service); err != nil {
+		return err
+	}
+
+	calendar, err := utils.SimpleCalendar(newRecordFlow.calendar(ctx), utils.Now().Year(), utils.Now().Month())
+	if err != nil {
+		return fmt.Errorf("error while building calendar: %w", err)
+	}
+	if _, _, err := ctx.EffectiveMessage.EditText(
+		b,
+		fmt.Sprintf("Услуга: %s\nВыберите дату", service.Name),
+		&gotgbot.EditMessageTextOpts{
+			ReplyMarkup: calendar,
 		}); err != nil {
 		return fmt.Errorf("error while sending calendar: %w", err)
 	}
@@ -75,25 +232,46 @@ func AddNewRecord(b *gotgbot.Bot, ctx *ext.Context) error {
 	return handlers.NextConversationState(SELECT)
 }
 
-func ProcessSelection(b *gotgbot.Bot, ctx *ext.Context) error {
+// chosenService возвращает услугу, выбранную пользователем на первом шаге записи.
+func chosenService(ctx *ext.Context, f recordFlow) (db.Service, error) {
+	var service db.Service
+	err := getData(ctx, f.key("service"), &service)
+
+	return service, err
+}
+
+// ProcessSelection обрабатывает календарь диалога f. Если на выбранный день нет
+// свободного времени, при новой записи предлагается лист ожидания, при переносе -
+// другая дата.
+func ProcessSelection(f recordFlow) handlers.Response {
+	return func(b *gotgbot.Bot, ctx *ext.Context) error {
+		return processSelection(b, ctx, f)
+	}
+}
+
+func processSelection(b *gotgbot.Bot, ctx *ext.Context, f recordFlow) error {
 	cb := ctx.Update.CallbackQuery
-	chatId := strconv.Itoa(int(ctx.EffectiveChat.Id))
-	data, foundData := utils.CalendarCache.Get(chatId)
-	if !foundData {
-		return fmt.Errorf("failed to get data from cache")
+	chatId := f.calendar(ctx)
+	newData, err := utils.GetCalendarState(chatId)
+	if err != nil {
+		return fmt.Errorf("error while getting calendar state: %w", err)
 	}
-	newData := data.(utils.CalendarCallback)
-	tempTime := time.Date(newData.Year, newData.Month, 1, 0, 0, 0, 0, time.UTC)
+	tempTime := time.Date(newData.Year, newData.Month, 1, 0, 0, 0, 0, conf.Shop.Location)
 
 	switch cb.Data {
 	case utils.IGNORE:
 	case utils.PrevMonth:
-		if tempTime.Month() != time.Now().Month() {
-			prevDate := tempTime.Add(-24 * time.Hour)
-			_, _, err := ctx.EffectiveMessage.EditReplyMarkup(
+		today := utils.Today()
+		if tempTime.After(today.AddDate(0, 0, 1-today.Day())) {
+			prevDate := tempTime.AddDate(0, -1, 0)
+			calendar, err := utils.SimpleCalendar(chatId, prevDate.Year(), prevDate.Month())
+			if err != nil {
+				return fmt.Errorf("error while building calendar: %w", err)
+			}
+			_, _, err = ctx.EffectiveMessage.EditReplyMarkup(
 				b,
 				&gotgbot.EditMessageReplyMarkupOpts{
-					ReplyMarkup: utils.SimpleCalendar(chatId, prevDate.Year(), prevDate.Month()),
+					ReplyMarkup: calendar,
 				})
 			if err != nil {
 				return fmt.Errorf("failed to edit markup")
@@ -101,10 +279,14 @@ func ProcessSelection(b *gotgbot.Bot, ctx *ext.Context) error {
 		}
 	case utils.NextMonth:
 		nextDate := tempTime.AddDate(0, 1, 0)
-		_, _, err := ctx.EffectiveMessage.EditReplyMarkup(
+		calendar, err := utils.SimpleCalendar(chatId, nextDate.Year(), nextDate.Month())
+		if err != nil {
+			return fmt.Errorf("error while building calendar: %w", err)
+		}
+		_, _, err = ctx.EffectiveMessage.EditReplyMarkup(
 			b,
 			&gotgbot.EditMessageReplyMarkupOpts{
-				ReplyMarkup: utils.SimpleCalendar(chatId, nextDate.Year(), nextDate.Month()),
+				ReplyMarkup: calendar,
 			})
 		if err != nil {
 			return fmt.Errorf("failed to edit markup")
@@ -116,31 +298,38 @@ func ProcessSelection(b *gotgbot.Bot, ctx *ext.Context) error {
 			return fmt.Errorf("failed to convert string to int")
 		}
 
-		result := time.Date(newData.Year, newData.Month, newDayInt, 0, 0, 0, 0, time.UTC)
-		compTime := time.Now().AddDate(0, 0, -1)
-		if result.Before(compTime) {
-			_, _, err := ctx.EffectiveMessage.EditText(
-				b,
-				fmt.Sprintf("Нельзя выбрать: %s (прошедшую дату)!\nПопробуйте снова", result.Format("02.01.2006")),
-				&gotgbot.EditMessageTextOpts{
-					ReplyMarkup: utils.SimpleCalendar(strconv.Itoa(int(ctx.EffectiveChat.Id)), time.Now().Year(), time.Now().Month()),
-				})
-			if err != nil {
-				return fmt.Errorf("error while sending calendar: %w", err)
-			}
+		result := time.Date(newData.Year, newData.Month, newDayInt, 0, 0, 0, 0, conf.Shop.Location)
+		if result.Before(utils.Today()) {
+			return rejectDate(b, ctx, f, fmt.Sprintf("Нельзя выбрать: %s (прошедшую дату)!\nПопробуйте снова", result.Format("02.01.2006")))
+		}
 
-			return handlers.NextConversationState(SELECT)
+		reason, closed, err := utils.IsClosed(result)
+		if err != nil {
+			return fmt.Errorf("error while checking closed day: %w", err)
+		}
+		if closed {
+			return rejectDate(b, ctx, f, fmt.Sprintf("%s мы не работаем (%s).\nВыберите другую дату", result.Format("02.01.2006"), reason))
 		}
 
 		if _, err := ctx.EffectiveMessage.Delete(b, &gotgbot.DeleteMessageOpts{}); err != nil {
 			return fmt.Errorf("failed to delete message")
 		}
 
-		recordsCache.Set(strconv.FormatInt(ctx.EffectiveChat.Id, 10)+"_chosen_date", result, cache.DefaultExpiration)
-		kb, err := utils.GetTimesKeyboard(result.Unix())
+		service, err := chosenService(ctx, f)
+		if err != nil {
+			return err
+		}
+
+		if err := setData(ctx, f.key("chosen_date"), result); err != nil {
+			return err
+		}
+		kb, err := f.timesKeyboard(ctx, result, service.Duration)
 		if err != nil {
 			return fmt.Errorf("error while getting times kb: %w", err)
 		}
+		if len(kb.InlineKeyboard) <= 1 {
+			return noFreeTime(b, ctx, f, result)
+		}
 
 		if _, err := ctx.EffectiveChat.SendMessage(
 			b,
@@ -156,27 +345,129 @@ func ProcessSelection(b *gotgbot.Bot, ctx *ext.Context) error {
 	return nil
 }
 
-func SelectTime(b *gotgbot.Bot, ctx *ext.Context) error {
-	chosenDateInterface, ok := recordsCache.Get(strconv.FormatInt(ctx.EffectiveChat.Id, 10) + "_chosen_date")
-	if !ok {
-		return fmt.Errorf("error while getting date from cache")
+// rejectDate показывает причину отказа и возвращает пользователя к календарю текущего месяца.
+func rejectDate(b *gotgbot.Bot, ctx *ext.Context, f recordFlow, text string) error {
+	calendar, err := utils.SimpleCalendar(f.calendar(ctx), utils.Now().Year(), utils.Now().Month())
+	if err != nil {
+		return fmt.Errorf("error while building calendar: %w", err)
+	}
+	_, _, err = ctx.EffectiveMessage.EditText(b, text, &gotgbot.EditMessageTextOpts{ReplyMarkup: calendar})
+	if err != nil {
+		return fmt.Errorf("error while sending calendar: %w", err)
+	}
+
+	return handlers.NextConversationState(SELECT)
+}
+
+// noFreeTime сообщает, что на день date всё занято, и предлагает лист ожидания
+// или сразу календарь для выбора другой даты.
+func noFreeTime(b *gotgbot.Bot, ctx *ext.Context, f recordFlow, date time.Time) error {
+	text := fmt.Sprintf("На %s свободного времени нет.", date.Format("02.01.2006"))
+	if !f.waitlist {
+		calendar, err := utils.SimpleCalendar(f.calendar(ctx), date.Year(), date.Month())
+		if err != nil {
+			return fmt.Errorf("error while building calendar: %w", err)
+		}
+		if _, err := ctx.EffectiveChat.SendMessage(b, text+"\nВыберите другую дату", &gotgbot.SendMessageOpts{ReplyMarkup: calendar}); err != nil {
+			return fmt.Errorf("error while sending calendar: %w", err)
+		}
+		return handlers.NextConversationState(SELECT)
+	}
+
+	// Окно «до/после» делит слоты дня пополам.
+	var mid schedule.Clock
+	if slots := conf.Schedule.Slots(date.Weekday()); len(slots) > 1 {
+		mid = slots[len(slots)/2]
+	}
+	if _, err := ctx.EffectiveChat.SendMessage(
+		b,
+		text+"\nМожем написать вам, если время освободится, или выберите другую дату",
+		&gotgbot.SendMessageOpts{ReplyMarkup: utils.GetWaitlistJoinKeyboard(mid)},
+	); err != nil {
+		return fmt.Errorf("error while offering waitlist: %w", err)
+	}
+
+	return handlers.NextConversationState(TIME)
+}
+
+// ChooseOtherDate возвращает к календарю из предложения листа ожидания.
+func ChooseOtherDate(b *gotgbot.Bot, ctx *ext.Context) error {
+	return rejectDate(b, ctx, newRecordFlow, "Выберите дату")
+}
+
+// slotTaken сообщает, что выбранное время успели занять, и предлагает
+// заново выбрать время на тот же день по обновлённой клавиатуре.
+func slotTaken(b *gotgbot.Bot, ctx *ext.Context, f recordFlow) error {
+	var chosenDate time.Time
+	if err := getData(ctx, f.key("chosen_date"), &chosenDate); err != nil {
+		return err
+	}
+	chosenDate = chosenDate.In(conf.Shop.Location)
+
+	service, err := chosenService(ctx, f)
+	if err != nil {
+		return err
+	}
+
+	kb, err := f.timesKeyboard(ctx, chosenDate, service.Duration)
+	if err != nil {
+		return fmt.Errorf("error while getting times kb: %w", err)
+	}
+	if len(kb.InlineKeyboard) <= 1 {
+		return rejectDate(b, ctx, f, fmt.Sprintf("Это время только что заняли, а других свободных окон на %s не осталось.\nВыберите другую дату", chosenDate.Format("02.01.2006")))
 	}
 
-	chosenDate := chosenDateInterface.(time.Time)
+	if _, _, err := ctx.EffectiveMessage.EditText(
+		b,
+		fmt.Sprintf("Это время только что заняли, выберите другое.\nДата: %s", chosenDate.Format("02.01.2006")),
+		&gotgbot.EditMessageTextOpts{ReplyMarkup: kb},
+	); err != nil {
+		return fmt.Errorf("error while sending times: %w", err)
+	}
+
+	return handlers.NextConversationState(TIME)
+}
+
+// SelectTime запоминает выбранное в диалоге f время и просит подтвердить его.
+func SelectTime(f recordFlow) handlers.Response {
+	return func(b *gotgbot.Bot, ctx *ext.Context) error {
+		return selectTime(b, ctx, f)
+	}
+}
+
+func selectTime(b *gotgbot.Bot, ctx *ext.Context, f recordFlow) error {
+	var chosenDate time.Time
+	if err := getData(ctx, f.key("chosen_date"), &chosenDate); err != nil {
+		return err
+	}
+	chosenDate = chosenDate.In(conf.Shop.Location)
 	cb := ctx.Update.CallbackQuery
 
-	parsedTime, err := time.Parse("15:04", cb.Data)
+	slot, err := schedule.ParseClock(cb.Data)
 	if err != nil {
-		return fmt.Errorf("error while ...: %w", err)
+		return fmt.Errorf("error while parsing time: %w", err)
 	}
 
-	sum := chosenDate.Add(time.Duration(parsedTime.Hour()) * time.Hour)
-	sum = sum.Add(time.Duration(parsedTime.Minute()) * time.Minute)
-	recordsCache.Set(strconv.FormatInt(ctx.EffectiveChat.Id, 10)+"_datetime", sum.Unix(), cache.DefaultExpiration)
+	sum := db.SlotStart(chosenDate, slot)
+	if err := setData(ctx, f.key("datetime"), sum.Unix()); err != nil {
+		return err
+	}
+
+	service, err := chosenService(ctx, f)
+	if err != nil {
+		return err
+	}
 
+	t := fmt.Sprintf("Дата: %s\nВремя: %s", chosenDate.Format("02.01.2006"), cb.Data)
+	if service.Name != "" {
+		t = fmt.Sprintf("Услуга: %s\n%s", service.Name, t)
+	}
+	if v, err := chosenVehicle(ctx, f); err == nil && v.Id != 0 {
+		t += "\nАвтомобиль: " + utils.VehicleTitle(v)
+	}
 	if _, _, err := ctx.EffectiveMessage.EditText(
 		b,
-		fmt.Sprintf("Дата: %s\nВремя: %s", chosenDate.Format("02.01.2006"), cb.Data),
+		t,
 		&gotgbot.EditMessageTextOpts{ReplyMarkup: utils.GetConfirmKeyboard()},
 	); err != nil {
 		return fmt.Errorf("error while ...: %w", err)
@@ -189,11 +480,22 @@ func ConfirmRecord(b *gotgbot.Bot, ctx *ext.Context) error {
 	cb := ctx.Update.CallbackQuery
 	switch cb.Data {
 	case "yes":
-		unixDatetime, ok := recordsCache.Get(strconv.FormatInt(ctx.EffectiveChat.Id, 10) + "_datetime")
-		if !ok {
-			return fmt.Errorf("error while confirming record")
+		var unixDatetime int64
+		if err := getData(ctx, newRecordFlow.key("datetime"), &unixDatetime); err != nil {
+			return err
+		}
+		service, err := chosenService(ctx, newRecordFlow)
+		if err != nil {
+			return err
+		}
+		vehicle, err := chosenVehicle(ctx, newRecordFlow)
+		if err != nil {
+			return err
+		}
+		record, err := recordRepo.SaveRecord(ctx.EffectiveChat.Id, vehicle.Id, unixDatetime, service.Id, service.Duration, conf.Shop.Bays, utils.Now().Unix())
+		if errors.Is(err, db.ErrSlotTaken) {
+			return slotTaken(b, ctx, newRecordFlow)
 		}
-		err := db.SaveRecord(ctx.EffectiveChat.Id, unixDatetime.(int64))
 		if err != nil {
 			return fmt.Errorf("error while saving record: %w", err)
 		}
@@ -201,19 +503,8 @@ func ConfirmRecord(b *gotgbot.Bot, ctx *ext.Context) error {
 			return fmt.Errorf("error while confirming record: %w", err)
 		}
 
-		name, number, err := db.GetInfo(int(ctx.EffectiveChat.Id))
-		if err != nil {
-			return fmt.Errorf("error while getting info about user: %w", err)
-		}
-		t := time.Unix(unixDatetime.(int64)-3*60*60, 0).Format("02.01.2006 15:04")
-		// prod: -1001891091220			test: -673660970
-		recordsChat := gotgbot.Chat{Id: -1001891091220, Type: "group"}
-		if _, err := recordsChat.SendMessage(
-			b,
-			fmt.Sprintf("Запись на %s\nИмя клиента: %s\nНомер телефона: %d", t, name, number),
-			&gotgbot.SendMessageOpts{ReplyMarkup: utils.GetRecordsKeyboard()},
-		); err != nil {
-			return fmt.Errorf("error while back up to menu: %w", err)
+		if err := notifyNewRecord(b, record, service, vehicle, ""); err != nil {
+			return err
 		}
 		if _, err := ctx.EffectiveChat.SendMessage(
 			b,
@@ -225,30 +516,37 @@ func ConfirmRecord(b *gotgbot.Bot, ctx *ext.Context) error {
 
 		return handlers.EndConversation()
 	case "no":
-		_, _, err := ctx.EffectiveMessage.EditText(
-			b,
-			"Попробуем снова!\nВыберите дату",
-			&gotgbot.EditMessageTextOpts{
-				ReplyMarkup: utils.SimpleCalendar(strconv.Itoa(int(ctx.EffectiveChat.Id)), time.Now().Year(), time.Now().Month()),
-			})
-		if err != nil {
-			return fmt.Errorf("error while sending calendar: %w", err)
-		}
-
-		return handlers.NextConversationState(SELECT)
+		return rejectDate(b, ctx, newRecordFlow, "Попробуем снова!\nВыберите дату")
 	}
 
 	return nil
 }
 
+// notifyNewRecord сообщает сотрудникам о новой записи клиента; note дописывается в конец.
+func notifyNewRecord(b *gotgbot.Bot, record db.Record, service db.Service, vehicle db.Vehicle, note string) error {
+	name, number, err := userRepo.GetInfo(record.UserId)
+	if err != nil {
+		return fmt.Errorf("error while getting info about user: %w", err)
+	}
+
+	return notifyStaffAboutRecord(
+		b,
+		fmt.Sprintf(
+			"Запись на %s\nУслуга: %s\nПост: %d\nИмя клиента: %s\nНомер телефона: %s%s%s",
+			utils.FormatRecordTime(record.Datetime), service.Name, record.Bay, name, number, vehicleLine(vehicle), note,
+		),
+		record,
+	)
+}
+
 func ChangePhoneNumber(b *gotgbot.Bot, ctx *ext.Context) error {
 	if ctx.EffectiveChat.Type != "private" {
 		return nil
 	}
 	_, err := ctx.EffectiveChat.SendMessage(
 		b,
-		"Отправьте новый номер телефона",
-		&gotgbot.SendMessageOpts{ReplyMarkup: gotgbot.ReplyKeyboardRemove{RemoveKeyboard: true}},
+		"Отправьте новый номер телефона кнопкой ниже или напишите его",
+		&gotgbot.SendMessageOpts{ReplyMarkup: utils.GetPhoneKeyboard()},
 	)
 
 	if err != nil {
@@ -259,19 +557,16 @@ func ChangePhoneNumber(b *gotgbot.Bot, ctx *ext.Context) error {
 }
 
 func AddNewNumber(b *gotgbot.Bot, ctx *ext.Context) error {
-	inputNumber := ctx.EffectiveMessage.Text
-
-	if _, err := strconv.Atoi(inputNumber); err != nil {
-		_, err := ctx.EffectiveChat.SendMessage(b, "Номер должен состоять из цифр!\nпопробуйте ещё раз", nil)
-		if err != nil {
-			return fmt.Errorf("error while sending number check message: %w", err)
-		}
-		return nil
+	inputNumber, err := utils.PhoneFromMessage(ctx.EffectiveMessage)
+	if err != nil {
+		return rejectPhone(b, ctx, err)
 	}
 
-	recordsCache.Set(strconv.FormatInt(ctx.EffectiveChat.Id, 10)+"_upd_number", inputNumber, cache.DefaultExpiration)
+	if err := setData(ctx, "upd_number", inputNumber); err != nil {
+		return err
+	}
 
-	_, err := ctx.EffectiveChat.SendMessage(
+	_, err = ctx.EffectiveChat.SendMessage(
 		b,
 		fmt.Sprintf("Новый номер: %s\nПодтвердить?", inputNumber),
 		&gotgbot.SendMessageOpts{
@@ -291,25 +586,12 @@ func ConfirmNewPhoneNumber(b *gotgbot.Bot, ctx *ext.Context) error {
 
 	switch cb.Data {
 	case "yes":
-		chatID := strconv.FormatInt(ctx.EffectiveChat.Id, 10)
-		number, foundNumber := recordsCache.Get(chatID + "_upd_number")
-
-		if !foundNumber {
-			// Если данные не найдены в кэше, обработка ошибки
-			return fmt.Errorf("failed to get name and/or number from cache")
-		}
-
-		numberStr, ok := number.(string)
-		if !ok {
-			return fmt.Errorf("failed to convert number to string")
-		}
-
-		numberInt, err := strconv.Atoi(numberStr)
-		if err != nil {
-			return fmt.Errorf("failed to convert number to int: %w", err)
+		var numberStr string
+		if err := getData(ctx, "upd_number", &numberStr); err != nil {
+			return err
 		}
 
-		if err := db.UpdateNumber(numberInt, int(ctx.EffectiveChat.Id)); err != nil {
+		if err := userRepo.UpdateNumber(ctx.EffectiveChat.Id, numberStr); err != nil {
 			return err
 		}
 
@@ -335,7 +617,7 @@ func ListAllRecords(b *gotgbot.Bot, ctx *ext.Context) error {
 	if ctx.EffectiveChat.Type != "private" {
 		return nil
 	}
-	records, err := db.GetAllRecords(ctx.EffectiveChat.Id)
+	records, err := recordRepo.GetAllRecords(ctx.EffectiveChat.Id, utils.Now().Unix())
 	if err != nil {
 		return fmt.Errorf("error while getting all records: %w", err)
 	}
@@ -352,6 +634,270 @@ func ListAllRecords(b *gotgbot.Bot, ctx *ext.Context) error {
 	return nil
 }
 
+// ShowRecordsList возвращает к списку записей из карточки записи.
+func ShowRecordsList(b *gotgbot.Bot, ctx *ext.Context) error {
+	records, err := recordRepo.GetAllRecords(ctx.EffectiveChat.Id, utils.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("error while getting all records: %w", err)
+	}
+	if len(records) == 0 {
+		if _, _, err := ctx.EffectiveMessage.EditText(b, "У вас нет актуальных записей", nil); err != nil {
+			return fmt.Errorf("error while listing all records: %w", err)
+		}
+		return nil
+	}
+
+	if _, _, err := ctx.EffectiveMessage.EditText(
+		b,
+		"Ваши актуальные записи",
+		&gotgbot.EditMessageTextOpts{ReplyMarkup: utils.GetAllUserRecordsKeyboard(records)},
+	); err != nil {
+		return fmt.Errorf("error while listing all records: %w", err)
+	}
+
+	return nil
+}
+
+// callbackRecord находит запись текущего пользователя по id из данных кнопки.
+func callbackRecord(ctx *ext.Context, prefix string) (db.Record, error) {
+	id, err := utils.ParseCallbackId(ctx.Update.CallbackQuery.Data, prefix)
+	if err != nil {
+		return db.Record{}, fmt.Errorf("failed to parse record id: %w", err)
+	}
+
+	return recordRepo.GetRecord(id, ctx.EffectiveChat.Id)
+}
+
+func recordDescription(record db.Record) string {
+	t := fmt.Sprintf("Запись на %s", utils.FormatRecordTime(record.Datetime))
+	if record.ServiceName != "" {
+		t += "\nУслуга: " + record.ServiceName
+	}
+	if record.Vehicle.Id != 0 {
+		t += "\nАвтомобиль: " + utils.VehicleTitle(record.Vehicle)
+	}
+	t += "\nСтатус: " + utils.StatusTitle(record.Status)
+
+	return t
+}
+
+// statusHistory - когда запись меняла статус, по строке на переход.
+func statusHistory(id int64) (string, error) {
+	history, err := recordRepo.StatusHistory(id)
+	if err != nil {
+		return "", fmt.Errorf("error while getting status history: %w", err)
+	}
+
+	var sb strings.Builder
+	for _, c := range history {
+		fmt.Fprintf(&sb, "\n%s — %s", time.Unix(c.ChangedAt, 0).In(conf.Shop.Location).Format("02.01 15:04"), utils.StatusTitle(c.Status))
+	}
+
+	return sb.String(), nil
+}
+
+func ShowRecord(b *gotgbot.Bot, ctx *ext.Context) error {
+	record, err := callbackRecord(ctx, utils.RecordPrefix)
+	if errors.Is(err, db.ErrNoRecord) {
+		return ShowRecordsList(b, ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("error while getting record: %w", err)
+	}
+	history, err := statusHistory(record.Id)
+	if err != nil {
+		return err
+	}
+
+	if _, _, err := ctx.EffectiveMessage.EditText(
+		b,
+		recordDescription(record)+"\n\nИстория:"+history,
+		&gotgbot.EditMessageTextOpts{ReplyMarkup: utils.GetRecordKeyboard(record)},
+	); err != nil {
+		return fmt.Errorf("error while showing record: %w", err)
+	}
+
+	return nil
+}
+
+func AskCancelRecord(b *gotgbot.Bot, ctx *ext.Context) error {
+	record, err := callbackRecord(ctx, utils.CancelPrefix)
+	if errors.Is(err, db.ErrNoRecord) {
+		return ShowRecordsList(b, ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("error while getting record: %w", err)
+	}
+
+	if _, _, err := ctx.EffectiveMessage.EditText(
+		b,
+		recordDescription(record)+"\n\nОтменить эту запись?",
+		&gotgbot.EditMessageTextOpts{ReplyMarkup: utils.GetCancelConfirmKeyboard(record.Id)},
+	); err != nil {
+		return fmt.Errorf("error while asking for cancel confirmation: %w", err)
+	}
+
+	return nil
+}
+
+func CancelRecord(b *gotgbot.Bot, ctx *ext.Context) error {
+	record, err := callbackRecord(ctx, utils.CancelConfirmPrefix)
+	if errors.Is(err, db.ErrNoRecord) {
+		return ShowRecordsList(b, ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("error while getting record: %w", err)
+	}
+
+	err = recordRepo.CancelRecord(record.Id, ctx.EffectiveChat.Id)
+	if errors.Is(err, db.ErrNoRecord) || errors.Is(err, db.ErrBadTransition) {
+		if _, _, err := ctx.EffectiveMessage.EditText(b, "Эту запись уже нельзя отменить", nil); err != nil {
+			return fmt.Errorf("error while cancelling record: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error while cancelling record: %w", err)
+	}
+
+	if _, _, err := ctx.EffectiveMessage.EditText(b, recordDescription(record)+"\n\nЗапись отменена", nil); err != nil {
+		return fmt.Errorf("error while cancelling record: %w", err)
+	}
+
+	name, number, err := userRepo.GetInfo(ctx.EffectiveChat.Id)
+	if err != nil {
+		return fmt.Errorf("error while getting info about user: %w", err)
+	}
+
+	return notifyStaff(b, fmt.Sprintf(
+		"Клиент отменил запись на %s\nПост: %d\nИмя клиента: %s\nНомер телефона: %s\nВремя снова свободно",
+		utils.FormatRecordTime(record.Datetime), record.Bay, name, number,
+	))
+}
+
+// ConfirmVisit - ответ «Приеду» на напоминание: сотрудники узнают, что клиента можно ждать.
+func ConfirmVisit(b *gotgbot.Bot, ctx *ext.Context) error {
+	record, err := callbackRecord(ctx, utils.VisitPrefix)
+	if errors.Is(err, db.ErrNoRecord) {
+		return ShowRecordsList(b, ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("error while getting record: %w", err)
+	}
+	if !db.Changeable(record.Status) {
+		if _, _, err := ctx.EffectiveMessage.EditText(b, recordDescription(record), nil); err != nil {
+			return fmt.Errorf("error while confirming visit: %w", err)
+		}
+		return nil
+	}
+
+	if _, _, err := ctx.EffectiveMessage.EditText(b, recordDescription(record)+"\n\nОтлично, ждём вас!", nil); err != nil {
+		return fmt.Errorf("error while confirming visit: %w", err)
+	}
+
+	name, number, err := userRepo.GetInfo(ctx.EffectiveChat.Id)
+	if err != nil {
+		return fmt.Errorf("error while getting info about user: %w", err)
+	}
+
+	return notifyStaff(b, fmt.Sprintf(
+		"Клиент подтвердил, что приедет на запись %s\nПост: %d\nИмя клиента: %s\nНомер телефона: %s",
+		utils.FormatRecordTime(record.Datetime), record.Bay, name, number,
+	))
+}
+
+// StartMoveRecord начинает перенос записи: дальше используются те же шаги
+// выбора даты и времени, что и при создании записи.
+func StartMoveRecord(b *gotgbot.Bot, ctx *ext.Context) error {
+	record, err := callbackRecord(ctx, utils.MovePrefix)
+	if errors.Is(err, db.ErrNoRecord) {
+		return ShowRecordsList(b, ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("error while getting record: %w", err)
+	}
+
+	if err := setData(ctx, moveFlow.key("record"), record); err != nil {
+		return err
+	}
+	if err := setData(ctx, moveFlow.key("service"), db.Service{Id: record.ServiceId, Name: record.ServiceName, Duration: record.Duration}); err != nil {
+		return err
+	}
+	// При переносе автомобиль не выбирается заново, он остаётся из записи.
+	if err := setData(ctx, moveFlow.key("vehicle"), record.Vehicle); err != nil {
+		return err
+	}
+
+	calendar, err := utils.SimpleCalendar(moveFlow.calendar(ctx), utils.Now().Year(), utils.Now().Month())
+	if err != nil {
+		return fmt.Errorf("error while building calendar: %w", err)
+	}
+	if _, _, err := ctx.EffectiveMessage.EditText(
+		b,
+		recordDescription(record)+"\n\nВыберите новую дату",
+		&gotgbot.EditMessageTextOpts{ReplyMarkup: calendar},
+	); err != nil {
+		return fmt.Errorf("error while sending calendar: %w", err)
+	}
+
+	return handlers.NextConversationState(SELECT)
+}
+
+func ConfirmMoveRecord(b *gotgbot.Bot, ctx *ext.Context) error {
+	cb := ctx.Update.CallbackQuery
+	switch cb.Data {
+	case "yes":
+		var (
+			record      db.Record
+			newDatetime int64
+		)
+		if err := getData(ctx, moveFlow.key("record"), &record); err != nil {
+			return err
+		}
+		if err := getData(ctx, moveFlow.key("datetime"), &newDatetime); err != nil {
+			return err
+		}
+
+		bay, err := recordRepo.MoveRecord(record.Id, ctx.EffectiveChat.Id, newDatetime, conf.Shop.Bays, utils.Now().Unix())
+		if errors.Is(err, db.ErrSlotTaken) {
+			return slotTaken(b, ctx, moveFlow)
+		}
+		if errors.Is(err, db.ErrNoRecord) || errors.Is(err, db.ErrBadTransition) {
+			if _, _, err := ctx.EffectiveMessage.EditText(b, "Эту запись уже нельзя перенести", nil); err != nil {
+				return fmt.Errorf("error while moving record: %w", err)
+			}
+			return handlers.EndConversation()
+		}
+		if err != nil {
+			return fmt.Errorf("error while moving record: %w", err)
+		}
+
+		was, now := utils.FormatRecordTime(record.Datetime), utils.FormatRecordTime(newDatetime)
+		if _, _, err := ctx.EffectiveMessage.EditText(b, fmt.Sprintf("Запись перенесена на %s", now), nil); err != nil {
+			return fmt.Errorf("error while confirming move: %w", err)
+		}
+
+		name, number, err := userRepo.GetInfo(ctx.EffectiveChat.Id)
+		if err != nil {
+			return fmt.Errorf("error while getting info about user: %w", err)
+		}
+		// Перенесённая запись снова ждёт подтверждения сотрудников.
+		record.Datetime, record.Bay, record.Status = newDatetime, bay, db.StatusPending
+		if err := notifyStaffAboutRecord(b, fmt.Sprintf(
+			"Клиент перенёс запись\nБыло: %s\nСтало: %s\nПост: %d\nИмя клиента: %s\nНомер телефона: %s%s",
+			was, now, bay, name, number, vehicleLine(record.Vehicle),
+		), record); err != nil {
+			return err
+		}
+
+		return handlers.EndConversation()
+	case "no":
+		return rejectDate(b, ctx, moveFlow, "Попробуем снова!\nВыберите новую дату")
+	}
+
+	return nil
+}
+
 func GoBack(b *gotgbot.Bot, ctx *ext.Context) error {
 	if ctx.EffectiveChat.Type != "private" {
 		return nil
@@ -0,0 +1,229 @@
+package sessions_test
+
+import (
+	"automobile36/internal/config"
+	"automobile36/internal/db"
+	"automobile36/internal/db/memory"
+	"automobile36/internal/modules/sessions"
+	"automobile36/internal/schedule"
+	"automobile36/internal/utils"
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+)
+
+const staffChat = -100
+
+// request - вызов Bot API, который сделал бот.
+type request struct {
+	method string
+	chatId int64
+	text   string
+}
+
+// fakeClient отвечает на все вызовы Bot API успехом и запоминает их.
+type fakeClient struct {
+	requests []request
+}
+
+func (c *fakeClient) RequestWithContext(ctx context.Context, method string, params map[string]string, data map[string]gotgbot.NamedReader, opts *gotgbot.RequestOpts) (json.RawMessage, error) {
+	chatId, _ := strconv.ParseInt(params["chat_id"], 10, 64)
+	c.requests = append(c.requests, request{method: method, chatId: chatId, text: params["text"]})
+
+	switch method {
+	case "sendMessage", "editMessageText", "editMessageReplyMarkup":
+		return json.Marshal(gotgbot.Message{MessageId: int64(len(c.requests)), Chat: gotgbot.Chat{Id: chatId}})
+	default:
+		return json.RawMessage("true"), nil
+	}
+}
+
+func (c *fakeClient) TimeoutContext(opts *gotgbot.RequestOpts) (context.Context, context.CancelFunc) {
+	return context.WithCancel(context.Background())
+}
+
+func (c *fakeClient) GetAPIURL() string {
+	return gotgbot.DefaultAPIURL
+}
+
+func (c *fakeClient) GetToken() string {
+	return "test"
+}
+
+// sentTo возвращает тексты сообщений, отправленных или изменённых в чате chatId.
+func (c *fakeClient) sentTo(chatId int64) []string {
+	var texts []string
+	for _, r := range c.requests {
+		if r.chatId == chatId && r.text != "" {
+			texts = append(texts, r.text)
+		}
+	}
+
+	return texts
+}
+
+// bot - бот с обработчиками записи поверх хранилища в памяти.
+type bot struct {
+	t      *testing.T
+	b      *gotgbot.Bot
+	client *fakeClient
+	dp     *ext.Dispatcher
+	store  *memory.Storage
+}
+
+func newBot(t *testing.T, bays int) *bot {
+	t.Helper()
+
+	loc, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		RecordsChatID: staffChat,
+		DB:            config.DB{SessionTTL: time.Hour},
+		Shop:          config.Shop{Bays: bays, Location: loc},
+		Schedule:      schedule.Default(),
+	}
+	store := memory.New()
+	utils.Init(cfg, store)
+	sessions.Init(cfg, store)
+
+	bt := &bot{t: t, client: &fakeClient{}, store: store}
+	bt.b = &gotgbot.Bot{User: gotgbot.User{Id: 1, IsBot: true}, BotClient: bt.client}
+	bt.dp = ext.NewDispatcher(&ext.DispatcherOpts{
+		Error: func(b *gotgbot.Bot, ctx *ext.Context, err error) ext.DispatcherAction {
+			t.Errorf("handler error: %s", err)
+			return ext.DispatcherActionNoop
+		},
+	})
+	sessions.LoadRecordsHandlers(bt.dp)
+
+	return bt
+}
+
+func (bt *bot) process(u *gotgbot.Update) {
+	bt.t.Helper()
+
+	if err := bt.dp.ProcessUpdate(bt.b, u, nil); err != nil {
+		bt.t.Fatalf("ProcessUpdate(): %s", err)
+	}
+}
+
+// message присылает боту текст от клиента userId в личном чате.
+func (bt *bot) message(userId int64, text string) {
+	bt.t.Helper()
+
+	bt.process(&gotgbot.Update{Message: &gotgbot.Message{
+		MessageId: 1,
+		Text:      text,
+		From:      &gotgbot.User{Id: userId},
+		Chat:      gotgbot.Chat{Id: userId, Type: "private"},
+	}})
+}
+
+// press нажимает у клиента userId кнопку с данными data.
+func (bt *bot) press(userId int64, data string) {
+	bt.t.Helper()
+
+	bt.process(&gotgbot.Update{CallbackQuery: &gotgbot.CallbackQuery{
+		Id:   "1",
+		From: gotgbot.User{Id: userId},
+		Data: data,
+		Message: &gotgbot.Message{
+			MessageId: 1,
+			Chat:      gotgbot.Chat{Id: userId, Type: "private"},
+		},
+	}})
+}
+
+// chooseTime проводит клиента userId по записи на первую услугу до подтверждения
+// времени slot в день date.
+func (bt *bot) chooseTime(userId int64, date time.Time, slot string) {
+	bt.t.Helper()
+
+	bt.message(userId, "Добавить запись 📝")
+	bt.press(userId, utils.ServicePrefix+"1")
+	if date.Month() != utils.Now().Month() {
+		bt.press(userId, utils.NextMonth)
+	}
+	bt.press(userId, strconv.Itoa(date.Day()))
+	bt.press(userId, slot)
+}
+
+// book записывает клиента userId на первую услугу в день date на время slot.
+func (bt *bot) book(userId int64, date time.Time, slot string) {
+	bt.t.Helper()
+
+	bt.chooseTime(userId, date, slot)
+	bt.press(userId, "yes")
+}
+
+// bookingDay - день через двое суток: его время ещё не прошло и он есть в календаре.
+func bookingDay() time.Time {
+	return utils.Today().AddDate(0, 0, 2)
+}
+
+func TestBookRecord(t *testing.T) {
+	bt := newBot(t, 1)
+	if err := bt.store.SaveUser(10, "Иван", "+79990000000"); err != nil {
+		t.Fatal(err)
+	}
+	day := bookingDay()
+
+	bt.book(10, day, "09:00")
+
+	records, err := bt.store.GetAllRecords(10, utils.Now().Unix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(day.Year(), day.Month(), day.Day(), 9, 0, 0, 0, day.Location()).Unix()
+	if len(records) != 1 || records[0].Datetime != at || records[0].Status != db.StatusPending || records[0].Bay != 1 {
+		t.Fatalf("records after booking = %+v, want one pending record at %d on bay 1", records, at)
+	}
+
+	client := bt.client.sentTo(10)
+	if len(client) == 0 || client[len(client)-1] != "Возвращаемся в меню" {
+		t.Errorf("client messages = %q, want the flow to end with the menu", client)
+	}
+	staff := bt.client.sentTo(staffChat)
+	if len(staff) != 1 || !strings.Contains(staff[0], "Имя клиента: Иван") {
+		t.Errorf("staff messages = %q, want one notification about Иван", staff)
+	}
+}
+
+func TestBookTakenSlot(t *testing.T) {
+	bt := newBot(t, 1)
+	for _, id := range []int64{10, 20} {
+		if err := bt.store.SaveUser(id, "Клиент", "+79990000000"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	day := bookingDay()
+
+	// Второй клиент выбирает то же время, пока первый его подтверждает.
+	bt.chooseTime(20, day, "09:00")
+	bt.book(10, day, "09:00")
+	bt.press(20, "yes")
+
+	records, err := bt.store.GetAllRecords(20, utils.Now().Unix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Errorf("records of the second client = %+v, want none", records)
+	}
+	client := bt.client.sentTo(20)
+	if last := client[len(client)-1]; !strings.HasPrefix(last, "Это время только что заняли, выберите другое.") {
+		t.Errorf("last message to the second client = %q, want a fresh choice of time", last)
+	}
+	if staff := bt.client.sentTo(staffChat); len(staff) != 1 {
+		t.Errorf("staff messages = %q, want only the first booking", staff)
+	}
+}
@@ -1,17 +1,13 @@
 package sessions
 
 import (
-	"automobile36/internal/db"
 	"automobile36/internal/utils"
+	"errors"
 	"fmt"
 	"github.com/PaulSonOfLars/gotgbot/v2"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers"
-	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/conversation"
-	"github.com/patrickmn/go-cache"
 	"html"
-	"strconv"
-	"time"
 )
 
 const (
@@ -20,25 +16,23 @@ const (
 	CONFIRM = "confirm"
 )
 
-var registrationCache = cache.New(5*time.Minute, 10*time.Minute)
-
 func LoadRegisterHandlers(dp *ext.Dispatcher) {
 	dp.AddHandler(handlers.NewConversation(
 		[]ext.Handler{handlers.NewCommand("start", Start)},
 		map[string][]ext.Handler{
 			NAME:    {handlers.NewMessage(utils.NoCommands, Name)},
-			NUMBER:  {handlers.NewMessage(utils.NoCommands, Number)},
+			NUMBER:  {handlers.NewMessage(utils.PhoneInput, Number)},
 			CONFIRM: {handlers.NewCallback(utils.Confirms, ConfirmData)},
 		},
 		&handlers.ConversationOpts{
-			StateStorage: conversation.NewInMemoryStorage(conversation.KeyStrategySenderAndChat),
+			StateStorage: conversationStorage("register"),
 		},
 	))
 }
 
 // Start introduces the bot and starts the conversation
 func Start(b *gotgbot.Bot, ctx *ext.Context) error {
-	res, err := db.IsExists(int(ctx.EffectiveChat.Id))
+	res, err := userRepo.IsExists(ctx.EffectiveChat.Id)
 	if err != nil {
 		return fmt.Errorf("error while IsExists checks user: %w", err)
 	}
@@ -60,14 +54,17 @@ func Start(b *gotgbot.Bot, ctx *ext.Context) error {
 func Name(b *gotgbot.Bot, ctx *ext.Context) error {
 	inputName := ctx.EffectiveMessage.Text
 
-	// Сохраняем имя пользователя в кэше
-	registrationCache.Set(strconv.FormatInt(ctx.EffectiveChat.Id, 10)+"_name", inputName, cache.DefaultExpiration)
+	// Сохраняем имя пользователя до подтверждения
+	if err := setData(ctx, "name", inputName); err != nil {
+		return err
+	}
 
 	_, err := ctx.EffectiveMessage.Reply(
 		b,
-		fmt.Sprintf("Приятно познакомиться, %s!\n\nТеперь напишите ваш номер телефона.", html.EscapeString(inputName)),
+		fmt.Sprintf("Приятно познакомиться, %s!\n\nТеперь отправьте ваш номер телефона кнопкой ниже или напишите его.", html.EscapeString(inputName)),
 		&gotgbot.SendMessageOpts{
-			ParseMode: "html",
+			ParseMode:   "html",
+			ReplyMarkup: utils.GetPhoneKeyboard(),
 		})
 	if err != nil {
 		return fmt.Errorf("failed to send name message: %w", err)
@@ -75,28 +72,38 @@ func Name(b *gotgbot.Bot, ctx *ext.Context) error {
 	return handlers.NextConversationState(NUMBER)
 }
 
-func Number(b *gotgbot.Bot, ctx *ext.Context) error {
-	inputNumber := ctx.EffectiveMessage.Text
+// rejectPhone объясняет, почему номер не принят, и оставляет диалог в том же состоянии.
+func rejectPhone(b *gotgbot.Bot, ctx *ext.Context, err error) error {
+	text := "Не удалось распознать номер.\nОтправьте российский номер в формате +7XXXXXXXXXX или нажмите «Поделиться номером 📱»"
+	if errors.Is(err, utils.ErrForeignContact) {
+		text = "Отправьте, пожалуйста, свой номер, а не чужой контакт"
+	}
 
-	if _, err := strconv.Atoi(inputNumber); err != nil {
-		_, err := ctx.EffectiveChat.SendMessage(b, "Номер должен состоять из цифр!\nпопробуйте ещё раз", nil)
-		if err != nil {
-			return fmt.Errorf("error while sending number check message: %w", err)
-		}
-		return nil
+	if _, err := ctx.EffectiveChat.SendMessage(b, text, &gotgbot.SendMessageOpts{ReplyMarkup: utils.GetPhoneKeyboard()}); err != nil {
+		return fmt.Errorf("error while sending number check message: %w", err)
+	}
+
+	return nil
+}
+
+func Number(b *gotgbot.Bot, ctx *ext.Context) error {
+	inputNumber, err := utils.PhoneFromMessage(ctx.EffectiveMessage)
+	if err != nil {
+		return rejectPhone(b, ctx, err)
 	}
 
-	name, found := registrationCache.Get(strconv.FormatInt(ctx.EffectiveChat.Id, 10) + "_name")
-	if !found {
-		// Если имя пользователя не найдено в кэше, обработка ошибки
-		return fmt.Errorf("failed to get name from cache")
+	var name string
+	if err := getData(ctx, "name", &name); err != nil {
+		return err
 	}
 
-	registrationCache.Set(strconv.FormatInt(ctx.EffectiveChat.Id, 10)+"_number", inputNumber, cache.DefaultExpiration)
+	if err := setData(ctx, "number", inputNumber); err != nil {
+		return err
+	}
 
-	_, err := ctx.EffectiveChat.SendMessage(
+	_, err = ctx.EffectiveChat.SendMessage(
 		b,
-		fmt.Sprintf("Имя: %s\nНомер телефона: %s\nВсё верно?", name.(string), inputNumber),
+		fmt.Sprintf("Имя: %s\nНомер телефона: %s\nВсё верно?", html.EscapeString(name), inputNumber),
 		&gotgbot.SendMessageOpts{
 			ParseMode:   "html",
 			ReplyMarkup: utils.GetConfirmKeyboard(),
@@ -112,31 +119,15 @@ func ConfirmData(b *gotgbot.Bot, ctx *ext.Context) error {
 
 	switch cb.Data {
 	case "yes":
-		chatID := strconv.FormatInt(ctx.EffectiveChat.Id, 10)
-		name, foundName := registrationCache.Get(chatID + "_name")
-		number, foundNumber := registrationCache.Get(chatID + "_number")
-
-		if !foundName || !foundNumber {
-			// Если данные не найдены в кэше, обработка ошибки
-			return fmt.Errorf("failed to get name and/or number from cache")
-		}
-
-		nameStr, ok := name.(string)
-		if !ok {
-			return fmt.Errorf("failed to convert name to string")
-		}
-
-		numberStr, ok := number.(string)
-		if !ok {
-			return fmt.Errorf("failed to convert number to string")
+		var nameStr, numberStr string
+		if err := getData(ctx, "name", &nameStr); err != nil {
+			return err
 		}
-
-		numberInt, err := strconv.Atoi(numberStr)
-		if err != nil {
-			return fmt.Errorf("failed to convert number to int: %w", err)
+		if err := getData(ctx, "number", &numberStr); err != nil {
+			return err
 		}
 
-		if err := db.SaveUser(int(ctx.EffectiveChat.Id), nameStr, numberInt); err != nil {
+		if err := userRepo.SaveUser(ctx.EffectiveChat.Id, nameStr, numberStr); err != nil {
 			return err
 		}
 
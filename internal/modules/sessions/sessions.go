@@ -0,0 +1,79 @@
+package sessions
+
+import (
+	"automobile36/internal/config"
+	"automobile36/internal/db"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/conversation"
+)
+
+var (
+	conf *config.Config
+
+	userRepo      db.UserRepository
+	recordRepo    db.RecordRepository
+	closedDayRepo db.ClosedDayRepository
+	catalogRepo   db.CatalogRepository
+	sessionRepo   db.SessionRepository
+	adminRepo     db.AdminRepository
+	slotBlockRepo db.SlotBlockRepository
+	vehicleRepo   db.VehicleRepository
+	storageRepo   db.TireStorageRepository
+	broadcastRepo db.BroadcastRepository
+	waitlistRepo  db.WaitlistRepository
+)
+
+// errNoData - промежуточные данные диалога не найдены (истекли или диалог начат заново).
+var errNoData = errors.New("session data not found")
+
+// Init передаёт обработчикам загруженную конфигурацию и хранилище.
+// Должна быть вызвана до регистрации обработчиков.
+func Init(cfg *config.Config, storage db.Storage) {
+	conf = cfg
+	userRepo = storage
+	recordRepo = storage
+	closedDayRepo = storage
+	catalogRepo = storage
+	sessionRepo = storage
+	adminRepo = storage
+	slotBlockRepo = storage
+	vehicleRepo = storage
+	storageRepo = storage
+	broadcastRepo = storage
+	waitlistRepo = storage
+}
+
+// conversationStorage возвращает хранилище состояний беседы name в базе.
+func conversationStorage(name string) conversation.Storage {
+	return sessionRepo.ConversationStorage(name, conversation.KeyStrategySenderAndChat, conf.DB.SessionTTL)
+}
+
+func dataKey(ctx *ext.Context, name string) string {
+	return strconv.FormatInt(ctx.EffectiveChat.Id, 10) + "_" + name
+}
+
+// setData сохраняет промежуточные данные диалога текущего чата.
+func setData(ctx *ext.Context, name string, value any) error {
+	if err := sessionRepo.SetSession(dataKey(ctx, name), value, conf.DB.SessionTTL); err != nil {
+		return fmt.Errorf("error while saving %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// getData читает в dst данные, сохранённые setData.
+func getData(ctx *ext.Context, name string, dst any) error {
+	found, err := sessionRepo.GetSession(dataKey(ctx, name), dst)
+	if err != nil {
+		return fmt.Errorf("error while getting %s: %w", name, err)
+	}
+	if !found {
+		return fmt.Errorf("%w: %s", errNoData, name)
+	}
+
+	return nil
+}
@@ -0,0 +1,272 @@
+package sessions
+
+import (
+	"automobile36/internal/db"
+	"automobile36/internal/schedule"
+	"automobile36/internal/utils"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/filters/callbackquery"
+)
+
+const (
+	blockUsage  = "Формат: /block ДД.ММ.ГГГГ ЧЧ:ММ[-ЧЧ:ММ] [пост] [причина]"
+	walkInUsage = "Формат: /walkin ДД.ММ.ГГГГ ЧЧ:ММ телефон имя"
+)
+
+// walkInData - запись без Telegram, для которой сотрудник выбирает услугу.
+type walkInData struct {
+	Datetime int64
+	Name     string
+	Phone    string
+}
+
+func loadSlotHandlers(dp *ext.Dispatcher) {
+	dp.AddHandler(handlers.NewCommand("block", staffOnly(db.RoleManager, BlockSlots)))
+	dp.AddHandler(handlers.NewCommand("unblock", staffOnly(db.RoleManager, UnblockSlots)))
+	dp.AddHandler(handlers.NewCommand("blocks", staffOnly(db.RoleMechanic, ListSlotBlocks)))
+	dp.AddHandler(handlers.NewCommand("walkin", staffOnly(db.RoleManager, StartWalkIn)))
+	dp.AddHandler(handlers.NewCallback(callbackquery.Prefix(utils.StaffWalkInPrefix), staffOnly(db.RoleManager, SaveWalkIn)))
+}
+
+// parseTimeRange разбирает "ЧЧ:ММ" или "ЧЧ:ММ-ЧЧ:ММ" в дне day. Без конца интервал
+// занимает один слот графика.
+func parseTimeRange(day time.Time, s string) (int64, int64, error) {
+	from, to, ranged := strings.Cut(s, "-")
+	start, err := schedule.ParseClock(from)
+	if err != nil {
+		return 0, 0, err
+	}
+	end := start + schedule.Clock(conf.Schedule.SlotLength())
+	if ranged {
+		if end, err = schedule.ParseClock(to); err != nil {
+			return 0, 0, err
+		}
+	}
+	if end <= start {
+		return 0, 0, fmt.Errorf("interval end is before start")
+	}
+
+	return db.SlotStart(day, start).Unix(), db.SlotStart(day, 0).Add(time.Duration(end)).Unix(), nil
+}
+
+func formatBlock(block db.SlotBlock) string {
+	start := time.Unix(block.Start, 0).In(conf.Shop.Location)
+	end := time.Unix(block.End, 0).In(conf.Shop.Location)
+	bay := "все посты"
+	if block.Bay > 0 {
+		bay = fmt.Sprintf("пост %d", block.Bay)
+	}
+
+	t := fmt.Sprintf("#%d %s %s–%s, %s", block.Id, start.Format(dateLayout), start.Format("15:04"), end.Format("15:04"), bay)
+	if block.Reason != "" {
+		t += " — " + html.EscapeString(block.Reason)
+	}
+
+	return t
+}
+
+// BlockSlots закрывает для записи слот или интервал на одном посту или на всех:
+// /block ДД.ММ.ГГГГ ЧЧ:ММ[-ЧЧ:ММ] [пост] [причина]
+func BlockSlots(b *gotgbot.Bot, ctx *ext.Context) error {
+	args := ctx.Args()[1:]
+	if len(args) < 2 {
+		return replyUsage(b, ctx, blockUsage)
+	}
+	day, err := parseDate(args[0])
+	if err != nil {
+		return replyUsage(b, ctx, blockUsage)
+	}
+	start, end, err := parseTimeRange(day, args[1])
+	if err != nil {
+		return replyUsage(b, ctx, blockUsage)
+	}
+	args = args[2:]
+
+	bay := 0
+	if len(args) > 0 {
+		if n, err := strconv.Atoi(args[0]); err == nil {
+			if n < 1 || n > conf.Shop.Bays {
+				return replyUsage(b, ctx, fmt.Sprintf("Номер поста должен быть от 1 до %d", conf.Shop.Bays))
+			}
+			bay, args = n, args[1:]
+		}
+	}
+
+	busy, err := recordRepo.Bookings(start, end, 0, utils.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("error while getting bookings: %w", err)
+	}
+	overlapping := 0
+	for _, bk := range busy {
+		if bay == 0 || bk.Bay == bay || bk.Bay == 0 {
+			overlapping++
+		}
+	}
+
+	block, err := slotBlockRepo.AddSlotBlock(start, end, bay, strings.Join(args, " "), ctx.EffectiveSender.Id())
+	if err != nil {
+		return fmt.Errorf("error while blocking slots: %w", err)
+	}
+
+	t := "Закрыто для записи: " + formatBlock(block)
+	if overlapping > 0 {
+		t += fmt.Sprintf("\nВнимание: на это время уже есть записи или блокировки (%d), они не отменены", overlapping)
+	}
+
+	if _, err := ctx.EffectiveMessage.Reply(b, t, &gotgbot.SendMessageOpts{ParseMode: "html"}); err != nil {
+		return fmt.Errorf("error while confirming slot block: %w", err)
+	}
+
+	return nil
+}
+
+// UnblockSlots снимает блокировку по номеру из /blocks: /unblock ID
+func UnblockSlots(b *gotgbot.Bot, ctx *ext.Context) error {
+	args := ctx.Args()[1:]
+	if len(args) != 1 {
+		return replyUsage(b, ctx, "Формат: /unblock номер (см. /blocks)")
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(args[0], "#"), 10, 64)
+	if err != nil {
+		return replyUsage(b, ctx, "Формат: /unblock номер (см. /blocks)")
+	}
+
+	removed, err := slotBlockRepo.RemoveSlotBlock(id)
+	if err != nil {
+		return fmt.Errorf("error while unblocking slots: %w", err)
+	}
+	t := "Блокировка снята"
+	if !removed {
+		t = "Блокировка не найдена"
+	}
+
+	if _, err := ctx.EffectiveMessage.Reply(b, t, nil); err != nil {
+		return fmt.Errorf("error while confirming slot unblock: %w", err)
+	}
+
+	return nil
+}
+
+// ListSlotBlocks показывает блокировки на месяц вперёд.
+func ListSlotBlocks(b *gotgbot.Bot, ctx *ext.Context) error {
+	blocks, err := slotBlockRepo.SlotBlocks(utils.Now().Unix(), utils.Today().AddDate(0, 1, 0).Unix())
+	if err != nil {
+		return fmt.Errorf("error while getting slot blocks: %w", err)
+	}
+
+	t := "Заблокированных слотов нет"
+	if len(blocks) > 0 {
+		var sb strings.Builder
+		sb.WriteString("<b>Заблокированные слоты</b>")
+		for _, block := range blocks {
+			sb.WriteString("\n" + formatBlock(block))
+		}
+		t = sb.String()
+	}
+
+	if _, err := ctx.EffectiveMessage.Reply(b, t, &gotgbot.SendMessageOpts{ParseMode: "html"}); err != nil {
+		return fmt.Errorf("error while listing slot blocks: %w", err)
+	}
+
+	return nil
+}
+
+func walkInKey(ctx *ext.Context) string {
+	return "walkin_" + strconv.FormatInt(ctx.EffectiveSender.Id(), 10)
+}
+
+// StartWalkIn записывает клиента, позвонившего по телефону или приехавшего без записи:
+// /walkin ДД.ММ.ГГГГ ЧЧ:ММ телефон имя. Дальше сотрудник выбирает услугу кнопкой.
+func StartWalkIn(b *gotgbot.Bot, ctx *ext.Context) error {
+	args := ctx.Args()[1:]
+	if len(args) < 4 {
+		return replyUsage(b, ctx, walkInUsage)
+	}
+	day, err := parseDate(args[0])
+	if err != nil {
+		return replyUsage(b, ctx, walkInUsage)
+	}
+	start, err := schedule.ParseClock(args[1])
+	if err != nil {
+		return replyUsage(b, ctx, walkInUsage)
+	}
+	phone, err := utils.NormalizePhone(args[2])
+	if err != nil {
+		return replyUsage(b, ctx, "Неверный номер телефона. "+walkInUsage)
+	}
+
+	data := walkInData{Datetime: db.SlotStart(day, start).Unix(), Name: strings.Join(args[3:], " "), Phone: phone}
+	if err := setData(ctx, walkInKey(ctx), data); err != nil {
+		return err
+	}
+
+	kb, err := utils.GetWalkInServicesKeyboard()
+	if err != nil {
+		return err
+	}
+	if _, err := ctx.EffectiveMessage.Reply(
+		b,
+		fmt.Sprintf("Запись %s на %s. Выберите услугу:", data.Name, utils.FormatRecordTime(data.Datetime)),
+		&gotgbot.SendMessageOpts{ReplyMarkup: kb},
+	); err != nil {
+		return fmt.Errorf("error while asking for walk-in service: %w", err)
+	}
+
+	return nil
+}
+
+// SaveWalkIn создаёт запись без Telegram после выбора услуги.
+func SaveWalkIn(b *gotgbot.Bot, ctx *ext.Context) error {
+	var data walkInData
+	err := getData(ctx, walkInKey(ctx), &data)
+	if errors.Is(err, errNoData) {
+		return answerStaff(b, ctx, "Начните заново командой /walkin")
+	}
+	if err != nil {
+		return err
+	}
+	serviceId, err := utils.ParseCallbackId(ctx.Update.CallbackQuery.Data, utils.StaffWalkInPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to parse service id: %w", err)
+	}
+	service, err := catalogRepo.GetService(serviceId)
+	if err != nil {
+		return fmt.Errorf("error while getting service: %w", err)
+	}
+
+	record, err := recordRepo.SaveWalkIn(data.Name, data.Phone, data.Datetime, service.Id, service.Duration, conf.Shop.Bays, utils.Now().Unix())
+	if errors.Is(err, db.ErrSlotTaken) {
+		return answerStaff(b, ctx, "На это время все посты заняты")
+	}
+	if err != nil {
+		return fmt.Errorf("error while saving walk-in record: %w", err)
+	}
+
+	text := fmt.Sprintf(
+		"Запись без Telegram на %s\nУслуга: %s\nПост: %d\nИмя клиента: %s\nНомер телефона: %s\n%s",
+		utils.FormatRecordTime(record.Datetime), service.Name, record.Bay, record.ClientName, record.ClientPhone,
+		actionLine("📝 Записал", ctx.EffectiveSender.User),
+	)
+	if err := editStaffMessage(b, ctx.EffectiveMessage.MessageId, text, record); err != nil {
+		return err
+	}
+
+	return answerStaff(b, ctx, "")
+}
+
+func replyUsage(b *gotgbot.Bot, ctx *ext.Context, usage string) error {
+	if _, err := ctx.EffectiveMessage.Reply(b, usage, nil); err != nil {
+		return fmt.Errorf("error while sending usage: %w", err)
+	}
+
+	return nil
+}
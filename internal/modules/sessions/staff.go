@@ -0,0 +1,187 @@
+package sessions
+
+import (
+	"automobile36/internal/db"
+	"automobile36/internal/digest"
+	"automobile36/internal/utils"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers"
+)
+
+const dateLayout = "02.01.2006"
+
+// LoadStaffHandlers регистрирует команды для сотрудников. Команды работают
+// только в группе, указанной в records_chat_id, и только для сотрудников с нужной ролью;
+// /admin доступна владельцам в любом чате.
+func LoadStaffHandlers(dp *ext.Dispatcher) {
+	dp.AddHandler(handlers.NewCommand("close", staffOnly(db.RoleManager, CloseDays)))
+	dp.AddHandler(handlers.NewCommand("open", staffOnly(db.RoleManager, OpenDays)))
+	dp.AddHandler(handlers.NewCommand("closed", staffOnly(db.RoleMechanic, ListClosedDays)))
+	dp.AddHandler(handlers.NewCommand("today", staffOnly(db.RoleMechanic, ShowDaySchedule(0))))
+	dp.AddHandler(handlers.NewCommand("tomorrow", staffOnly(db.RoleMechanic, ShowDaySchedule(1))))
+	dp.AddHandler(handlers.NewCommand("admin", requireRole(db.RoleOwner, Admin)))
+	loadSlotHandlers(dp)
+	loadStaffStorageHandlers(dp)
+	loadBroadcastHandlers(dp)
+	loadStaffRecordHandlers(dp)
+}
+
+func isStaffChat(ctx *ext.Context) bool {
+	return ctx.EffectiveChat.Id == conf.RecordsChatID
+}
+
+// staffOnly - обработчик для группы сотрудников, требующий роль role.
+// В остальных чатах обновление молча пропускается.
+func staffOnly(role string, handler handlers.Response) handlers.Response {
+	checked := requireRole(role, handler)
+	return func(b *gotgbot.Bot, ctx *ext.Context) error {
+		if !isStaffChat(ctx) {
+			return nil
+		}
+		return checked(b, ctx)
+	}
+}
+
+// notifyStaff отправляет сообщение в группу сотрудников.
+func notifyStaff(b *gotgbot.Bot, text string) error {
+	if _, err := b.SendMessage(conf.RecordsChatID, text, nil); err != nil {
+		return fmt.Errorf("error while notifying staff: %w", err)
+	}
+
+	return nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	return time.ParseInLocation(dateLayout, s, conf.Shop.Location)
+}
+
+// parseDateRange разбирает "ДД.ММ.ГГГГ [ДД.ММ.ГГГГ]" из начала args и возвращает
+// границы периода и число использованных аргументов.
+func parseDateRange(args []string) (time.Time, time.Time, int, error) {
+	if len(args) == 0 {
+		return time.Time{}, time.Time{}, 0, fmt.Errorf("date is required")
+	}
+	from, err := parseDate(args[0])
+	if err != nil {
+		return time.Time{}, time.Time{}, 0, fmt.Errorf("invalid date %q", args[0])
+	}
+	if len(args) > 1 {
+		if to, err := parseDate(args[1]); err == nil {
+			if to.Before(from) {
+				return time.Time{}, time.Time{}, 0, fmt.Errorf("period end is before start")
+			}
+			return from, to, 2, nil
+		}
+	}
+
+	return from, from, 1, nil
+}
+
+// CloseDays закрывает день или период для записи: /close ДД.ММ.ГГГГ [ДД.ММ.ГГГГ] [причина]
+func CloseDays(b *gotgbot.Bot, ctx *ext.Context) error {
+	args := ctx.Args()[1:]
+	from, to, used, err := parseDateRange(args)
+	if err != nil {
+		_, err := ctx.EffectiveMessage.Reply(b, "Формат: /close ДД.ММ.ГГГГ [ДД.ММ.ГГГГ] [причина]", nil)
+		if err != nil {
+			return fmt.Errorf("error while sending close usage: %w", err)
+		}
+		return nil
+	}
+	reason := strings.Join(args[used:], " ")
+	if reason == "" {
+		reason = "закрыто"
+	}
+
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if err := closedDayRepo.AddClosedDay(d.Unix(), reason); err != nil {
+			return fmt.Errorf("error while closing day: %w", err)
+		}
+	}
+
+	if _, err := ctx.EffectiveMessage.Reply(
+		b,
+		fmt.Sprintf("Закрыто для записи: %s – %s (%s)", from.Format(dateLayout), to.Format(dateLayout), reason),
+		nil,
+	); err != nil {
+		return fmt.Errorf("error while confirming closed days: %w", err)
+	}
+
+	return nil
+}
+
+// OpenDays снова открывает день или период для записи: /open ДД.ММ.ГГГГ [ДД.ММ.ГГГГ]
+func OpenDays(b *gotgbot.Bot, ctx *ext.Context) error {
+	from, to, _, err := parseDateRange(ctx.Args()[1:])
+	if err != nil {
+		_, err := ctx.EffectiveMessage.Reply(b, "Формат: /open ДД.ММ.ГГГГ [ДД.ММ.ГГГГ]", nil)
+		if err != nil {
+			return fmt.Errorf("error while sending open usage: %w", err)
+		}
+		return nil
+	}
+
+	opened := 0
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		ok, err := closedDayRepo.RemoveClosedDay(d.Unix())
+		if err != nil {
+			return fmt.Errorf("error while opening day: %w", err)
+		}
+		if ok {
+			opened++
+		}
+	}
+
+	if _, err := ctx.EffectiveMessage.Reply(b, fmt.Sprintf("Открыто дней: %d", opened), nil); err != nil {
+		return fmt.Errorf("error while confirming opened days: %w", err)
+	}
+
+	return nil
+}
+
+// ListClosedDays показывает ближайшие закрытые дни.
+func ListClosedDays(b *gotgbot.Bot, ctx *ext.Context) error {
+	today := utils.Today()
+	days, err := closedDayRepo.GetClosedDays(today.Unix(), today.AddDate(1, 0, 0).Unix())
+	if err != nil {
+		return fmt.Errorf("error while getting closed days: %w", err)
+	}
+
+	t := "Закрытых дней нет"
+	if len(days) > 0 {
+		var sb strings.Builder
+		sb.WriteString("<b>Закрытые дни</b>")
+		for _, d := range days {
+			fmt.Fprintf(&sb, "\n%s — %s", time.Unix(d.Day, 0).In(conf.Shop.Location).Format(dateLayout), html.EscapeString(d.Reason))
+		}
+		t = sb.String()
+	}
+
+	if _, err := ctx.EffectiveMessage.Reply(b, t, &gotgbot.SendMessageOpts{ParseMode: "html"}); err != nil {
+		return fmt.Errorf("error while listing closed days: %w", err)
+	}
+
+	return nil
+}
+
+// ShowDaySchedule показывает записи на день через offset дней от сегодняшнего.
+func ShowDaySchedule(offset int) handlers.Response {
+	return func(b *gotgbot.Bot, ctx *ext.Context) error {
+		t, err := digest.Text(recordRepo, utils.Today().AddDate(0, 0, offset))
+		if err != nil {
+			return err
+		}
+
+		if _, err := ctx.EffectiveMessage.Reply(b, t, &gotgbot.SendMessageOpts{ParseMode: "html"}); err != nil {
+			return fmt.Errorf("error while showing day schedule: %w", err)
+		}
+
+		return nil
+	}
+}
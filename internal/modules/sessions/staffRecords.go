@@ -0,0 +1,258 @@
+package sessions
+
+import (
+	"automobile36/internal/db"
+	"automobile36/internal/utils"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/filters/callbackquery"
+)
+
+const REASON = "reason"
+
+// staffAction - кнопка под уведомлением о записи, сразу меняющая её статус.
+type staffAction struct {
+	prefix string
+	status string
+	// role - минимальная роль сотрудника для этого действия.
+	role string
+	// label попадает в уведомление вместе с именем сотрудника.
+	label string
+	// notice - сообщение клиенту, %s заменяется временем записи.
+	notice string
+}
+
+var staffActions = []staffAction{
+	{utils.StaffConfirmPrefix, db.StatusConfirmed, db.RoleManager, "✅ Подтверждено", "Ваша запись на %s подтверждена ✅\nЖдём вас!"},
+	{utils.StaffArrivedPrefix, db.StatusArrived, db.RoleMechanic, "🚗 Клиент приехал", "Мы отметили ваш приезд на запись %s. Скоро начнём работу!"},
+	{utils.StaffStartPrefix, db.StatusInProgress, db.RoleMechanic, "🔧 Работы начаты", "Мастер приступил к работам по записи на %s 🔧"},
+	{utils.StaffDonePrefix, db.StatusDone, db.RoleMechanic, "🏁 Работы завершены", "Работы по записи на %s завершены 🏁\nСпасибо, что выбрали нас!"},
+	{utils.StaffNoShowPrefix, db.StatusNoShow, db.RoleMechanic, "🚫 Клиент не пришёл", "Вы не пришли на запись %s.\nЕсли планы изменились, запишитесь на другое время."},
+}
+
+// rejectData - запись, для которой сотрудник пишет причину отказа, и её уведомление.
+type rejectData struct {
+	RecordId  int64
+	MessageId int64
+	Text      string
+}
+
+func loadStaffRecordHandlers(dp *ext.Dispatcher) {
+	for _, action := range staffActions {
+		dp.AddHandler(handlers.NewCallback(callbackquery.Prefix(action.prefix), staffOnly(action.role, staffSetStatus(action))))
+	}
+	dp.AddHandler(handlers.NewCallback(callbackquery.Prefix(utils.StaffCallPrefix), staffOnly(db.RoleMechanic, StaffCallClient)))
+	dp.AddHandler(handlers.NewConversation(
+		[]ext.Handler{handlers.NewCallback(callbackquery.Prefix(utils.StaffRejectPrefix), staffOnly(db.RoleManager, AskRejectReason))},
+		map[string][]ext.Handler{
+			REASON: {handlers.NewMessage(utils.NoCommands, RejectRecord)},
+		},
+		&handlers.ConversationOpts{
+			Fallbacks:    []ext.Handler{handlers.NewCommand("cancel", CancelReject)},
+			StateStorage: conversationStorage("reject_record"),
+		},
+	))
+}
+
+// notifyStaffAboutRecord отправляет в группу сотрудников уведомление с кнопками действий над записью.
+func notifyStaffAboutRecord(b *gotgbot.Bot, text string, record db.Record) error {
+	_, err := b.SendMessage(conf.RecordsChatID, text, &gotgbot.SendMessageOpts{
+		ReplyMarkup: utils.GetStaffRecordKeyboard(record.Id, record.Status),
+	})
+	if err != nil {
+		return fmt.Errorf("error while notifying staff: %w", err)
+	}
+
+	return nil
+}
+
+// notifyCustomer пишет клиенту в личный чат. Клиент мог заблокировать бота,
+// поэтому ошибка только логируется. Клиентам без Telegram (userId = 0) ничего не отправляется.
+func notifyCustomer(b *gotgbot.Bot, userId int64, text string) {
+	if userId == 0 {
+		return
+	}
+	if _, err := b.SendMessage(userId, text, nil); err != nil {
+		log.Printf("failed to notify customer %d: %s", userId, err)
+	}
+}
+
+func staffName(u *gotgbot.User) string {
+	name := u.FirstName
+	if u.LastName != "" {
+		name += " " + u.LastName
+	}
+	if u.Username != "" {
+		name += " (@" + u.Username + ")"
+	}
+
+	return name
+}
+
+// actionLine - строка "кто и когда" для уведомления о записи.
+func actionLine(label string, u *gotgbot.User) string {
+	return fmt.Sprintf("%s — %s, %s", label, staffName(u), utils.Now().Format("15:04"))
+}
+
+// editStaffMessage заменяет текст уведомления и кнопки под текущий статус записи.
+func editStaffMessage(b *gotgbot.Bot, messageId int64, text string, record db.Record) error {
+	opts := &gotgbot.EditMessageTextOpts{ChatId: conf.RecordsChatID, MessageId: messageId}
+	if kb := utils.GetStaffRecordKeyboard(record.Id, record.Status); len(kb.InlineKeyboard) > 0 {
+		opts.ReplyMarkup = kb
+	}
+	if _, _, err := b.EditMessageText(text, opts); err != nil {
+		return fmt.Errorf("error while editing staff message: %w", err)
+	}
+
+	return nil
+}
+
+func answerStaff(b *gotgbot.Bot, ctx *ext.Context, text string) error {
+	opts := &gotgbot.AnswerCallbackQueryOpts{Text: text, ShowAlert: text != ""}
+	if _, err := ctx.Update.CallbackQuery.Answer(b, opts); err != nil {
+		return fmt.Errorf("error while answering callback: %w", err)
+	}
+
+	return nil
+}
+
+func staffSetStatus(action staffAction) handlers.Response {
+	return func(b *gotgbot.Bot, ctx *ext.Context) error {
+		id, err := utils.ParseCallbackId(ctx.Update.CallbackQuery.Data, action.prefix)
+		if err != nil {
+			return fmt.Errorf("failed to parse record id: %w", err)
+		}
+
+		record, err := recordRepo.SetStatus(id, action.status)
+		if errors.Is(err, db.ErrNoRecord) || errors.Is(err, db.ErrBadTransition) {
+			return answerStaff(b, ctx, "Это действие для записи уже недоступно")
+		}
+		if err != nil {
+			return fmt.Errorf("error while setting record status: %w", err)
+		}
+
+		msg := ctx.EffectiveMessage
+		text := msg.Text + "\n" + actionLine(action.label, ctx.EffectiveSender.User)
+		if err := editStaffMessage(b, msg.MessageId, text, record); err != nil {
+			return err
+		}
+		notifyCustomer(b, record.UserId, fmt.Sprintf(action.notice, utils.FormatRecordTime(record.Datetime)))
+
+		return answerStaff(b, ctx, "")
+	}
+}
+
+// StaffCallClient показывает сотруднику имя и телефон клиента.
+func StaffCallClient(b *gotgbot.Bot, ctx *ext.Context) error {
+	id, err := utils.ParseCallbackId(ctx.Update.CallbackQuery.Data, utils.StaffCallPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to parse record id: %w", err)
+	}
+	record, err := recordRepo.GetRecordById(id)
+	if errors.Is(err, db.ErrNoRecord) {
+		return answerStaff(b, ctx, "Запись не найдена")
+	}
+	if err != nil {
+		return fmt.Errorf("error while getting record: %w", err)
+	}
+
+	name, number := record.ClientName, record.ClientPhone
+	if !record.WalkIn() {
+		if name, number, err = userRepo.GetInfo(record.UserId); err != nil {
+			return fmt.Errorf("error while getting info about user: %w", err)
+		}
+	}
+
+	return answerStaff(b, ctx, fmt.Sprintf("%s\n%s", name, number))
+}
+
+// rejectKey - данные отказа хранятся отдельно для каждого сотрудника, ведь чат у них общий.
+func rejectKey(ctx *ext.Context) string {
+	return "reject_" + strconv.FormatInt(ctx.EffectiveSender.Id(), 10)
+}
+
+// AskRejectReason просит сотрудника написать причину отказа ответом на сообщение бота.
+func AskRejectReason(b *gotgbot.Bot, ctx *ext.Context) error {
+	id, err := utils.ParseCallbackId(ctx.Update.CallbackQuery.Data, utils.StaffRejectPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to parse record id: %w", err)
+	}
+	record, err := recordRepo.GetRecordById(id)
+	if errors.Is(err, db.ErrNoRecord) || err == nil && !db.CanTransition(record.Status, db.StatusCancelledByShop) {
+		return answerStaff(b, ctx, "Эту запись уже нельзя отклонить")
+	}
+	if err != nil {
+		return fmt.Errorf("error while getting record: %w", err)
+	}
+
+	msg := ctx.EffectiveMessage
+	if err := setData(ctx, rejectKey(ctx), rejectData{RecordId: id, MessageId: msg.MessageId, Text: msg.Text}); err != nil {
+		return err
+	}
+
+	user := ctx.EffectiveSender.User
+	if _, err := ctx.EffectiveChat.SendMessage(
+		b,
+		fmt.Sprintf(`<a href="tg://user?id=%d">%s</a>, напишите причину отказа ответом на это сообщение или /cancel`, user.Id, html.EscapeString(user.FirstName)),
+		&gotgbot.SendMessageOpts{
+			ParseMode:   "html",
+			ReplyMarkup: gotgbot.ForceReply{ForceReply: true, Selective: true, InputFieldPlaceholder: "Причина отказа"},
+		},
+	); err != nil {
+		return fmt.Errorf("error while asking for reject reason: %w", err)
+	}
+	if err := answerStaff(b, ctx, ""); err != nil {
+		return err
+	}
+
+	return handlers.NextConversationState(REASON)
+}
+
+func RejectRecord(b *gotgbot.Bot, ctx *ext.Context) error {
+	var data rejectData
+	if err := getData(ctx, rejectKey(ctx), &data); err != nil {
+		return err
+	}
+	reason := ctx.EffectiveMessage.Text
+
+	record, err := recordRepo.SetStatus(data.RecordId, db.StatusCancelledByShop)
+	if errors.Is(err, db.ErrNoRecord) || errors.Is(err, db.ErrBadTransition) {
+		if _, err := ctx.EffectiveMessage.Reply(b, "Эту запись уже нельзя отклонить", nil); err != nil {
+			return fmt.Errorf("error while rejecting record: %w", err)
+		}
+		return handlers.EndConversation()
+	}
+	if err != nil {
+		return fmt.Errorf("error while rejecting record: %w", err)
+	}
+
+	text := data.Text + "\n" + actionLine("❌ Отклонено", ctx.EffectiveSender.User) + "\nПричина: " + reason
+	if err := editStaffMessage(b, data.MessageId, text, record); err != nil {
+		return err
+	}
+	notifyCustomer(b, record.UserId, fmt.Sprintf(
+		"К сожалению, мастерская не сможет принять вас %s.\nПричина: %s\nВыберите, пожалуйста, другое время.",
+		utils.FormatRecordTime(record.Datetime), reason,
+	))
+
+	if _, err := ctx.EffectiveMessage.Reply(b, "Запись отклонена, клиент уведомлён", nil); err != nil {
+		return fmt.Errorf("error while rejecting record: %w", err)
+	}
+
+	return handlers.EndConversation()
+}
+
+func CancelReject(b *gotgbot.Bot, ctx *ext.Context) error {
+	if _, err := ctx.EffectiveMessage.Reply(b, "Отклонение отменено", nil); err != nil {
+		return fmt.Errorf("error while cancelling reject: %w", err)
+	}
+
+	return handlers.EndConversation()
+}
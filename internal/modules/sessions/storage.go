@@ -0,0 +1,360 @@
+package sessions
+
+import (
+	"automobile36/internal/db"
+	"automobile36/internal/utils"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/filters/callbackquery"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/filters/message"
+)
+
+const checkInUsage = "Формат: /checkin телефон | госномер или - | описание комплекта | стеллаж | ДД.ММ.ГГГГ окончания | цена"
+
+func loadStorageHandlers(dp *ext.Dispatcher) {
+	dp.AddHandler(handlers.NewMessage(message.Equal("Мои шины на хранении 🛞"), ListStorages))
+	dp.AddHandler(handlers.NewCallback(callbackquery.Equal(utils.StoragesList), ShowStoragesList))
+	dp.AddHandler(handlers.NewCallback(callbackquery.Prefix(utils.StoragePrefix), ShowStorage))
+	dp.AddHandler(handlers.NewCallback(callbackquery.Prefix(utils.StoragePickupPrefix), ChooseStoragePickup))
+	dp.AddHandler(handlers.NewCallback(callbackquery.Prefix(utils.StorageLinkPrefix), LinkStoragePickup))
+}
+
+func loadStaffStorageHandlers(dp *ext.Dispatcher) {
+	dp.AddHandler(handlers.NewCommand("checkin", staffOnly(db.RoleManager, CheckInStorage)))
+	dp.AddHandler(handlers.NewCommand("checkout", staffOnly(db.RoleManager, CheckOutStorage)))
+	dp.AddHandler(handlers.NewCommand("storage", staffOnly(db.RoleMechanic, ListActiveStorages)))
+}
+
+func formatDay(datetime int64) string {
+	return time.Unix(datetime, 0).In(conf.Shop.Location).Format(dateLayout)
+}
+
+func storagesText(contracts []db.StorageContract) string {
+	if len(contracts) == 0 {
+		return "У нас нет ваших шин на хранении"
+	}
+
+	return "Ваши шины на хранении"
+}
+
+func storageDescription(c db.StorageContract) string {
+	t := fmt.Sprintf("Договор хранения №%d\n%s", c.Id, c.Description)
+	if c.Vehicle.Id != 0 {
+		t += "\nАвтомобиль: " + utils.VehicleTitle(c.Vehicle)
+	} else if c.Plate != "" {
+		t += "\nГосномер: " + c.Plate
+	}
+	t += fmt.Sprintf("\nСрок: %s – %s\nСтоимость: %d ₽", formatDay(c.StartsAt), formatDay(c.EndsAt), c.Price)
+
+	return t
+}
+
+func ListStorages(b *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.EffectiveChat.Type != "private" {
+		return nil
+	}
+	contracts, err := storageRepo.StorageContracts(ctx.EffectiveChat.Id)
+	if err != nil {
+		return fmt.Errorf("error while getting storage contracts: %w", err)
+	}
+
+	opts := &gotgbot.SendMessageOpts{}
+	if len(contracts) > 0 {
+		opts.ReplyMarkup = utils.GetStoragesKeyboard(contracts)
+	}
+	if _, err := ctx.EffectiveChat.SendMessage(b, storagesText(contracts), opts); err != nil {
+		return fmt.Errorf("error while listing storage contracts: %w", err)
+	}
+
+	return nil
+}
+
+// ShowStoragesList возвращает к списку шин из карточки договора.
+func ShowStoragesList(b *gotgbot.Bot, ctx *ext.Context) error {
+	contracts, err := storageRepo.StorageContracts(ctx.EffectiveChat.Id)
+	if err != nil {
+		return fmt.Errorf("error while getting storage contracts: %w", err)
+	}
+
+	if _, _, err := ctx.EffectiveMessage.EditText(
+		b,
+		storagesText(contracts),
+		&gotgbot.EditMessageTextOpts{ReplyMarkup: utils.GetStoragesKeyboard(contracts)},
+	); err != nil {
+		return fmt.Errorf("error while listing storage contracts: %w", err)
+	}
+
+	return nil
+}
+
+// customerStorage находит договор текущего пользователя, если шины ещё на хранении, иначе ErrNoStorage.
+func customerStorage(ctx *ext.Context, id int64) (db.StorageContract, error) {
+	contracts, err := storageRepo.StorageContracts(ctx.EffectiveChat.Id)
+	if err != nil {
+		return db.StorageContract{}, fmt.Errorf("error while getting storage contracts: %w", err)
+	}
+	for _, c := range contracts {
+		if c.Id == id {
+			return c, nil
+		}
+	}
+
+	return db.StorageContract{}, db.ErrNoStorage
+}
+
+func ShowStorage(b *gotgbot.Bot, ctx *ext.Context) error {
+	id, err := utils.ParseCallbackId(ctx.Update.CallbackQuery.Data, utils.StoragePrefix)
+	if err != nil {
+		return fmt.Errorf("failed to parse storage id: %w", err)
+	}
+	c, err := customerStorage(ctx, id)
+	if errors.Is(err, db.ErrNoStorage) {
+		return ShowStoragesList(b, ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	t := storageDescription(c)
+	if c.PickupRecordId != 0 {
+		if record, err := recordRepo.GetRecord(c.PickupRecordId, ctx.EffectiveChat.Id); err == nil {
+			t += "\nЗаберёте к записи на " + utils.FormatRecordTime(record.Datetime)
+		}
+	}
+
+	if _, _, err := ctx.EffectiveMessage.EditText(
+		b,
+		t,
+		&gotgbot.EditMessageTextOpts{ReplyMarkup: utils.GetStorageKeyboard(c.Id)},
+	); err != nil {
+		return fmt.Errorf("error while showing storage contract: %w", err)
+	}
+
+	return nil
+}
+
+// ChooseStoragePickup предлагает выбрать предстоящую запись, к которой подготовить шины.
+func ChooseStoragePickup(b *gotgbot.Bot, ctx *ext.Context) error {
+	cq := ctx.Update.CallbackQuery
+	id, err := utils.ParseCallbackId(cq.Data, utils.StoragePickupPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to parse storage id: %w", err)
+	}
+	if _, err := customerStorage(ctx, id); errors.Is(err, db.ErrNoStorage) {
+		return ShowStoragesList(b, ctx)
+	} else if err != nil {
+		return err
+	}
+
+	all, err := recordRepo.GetAllRecords(ctx.EffectiveChat.Id, utils.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("error while getting records: %w", err)
+	}
+	var records []db.Record
+	for _, r := range all {
+		if db.Changeable(r.Status) {
+			records = append(records, r)
+		}
+	}
+	if len(records) == 0 {
+		if _, err := cq.Answer(b, &gotgbot.AnswerCallbackQueryOpts{
+			Text:      "У вас нет предстоящих записей. Сначала запишитесь, затем выберите запись здесь",
+			ShowAlert: true,
+		}); err != nil {
+			return fmt.Errorf("error while answering callback: %w", err)
+		}
+		return nil
+	}
+
+	if _, _, err := ctx.EffectiveMessage.EditText(
+		b,
+		"К какой записи подготовить шины?",
+		&gotgbot.EditMessageTextOpts{ReplyMarkup: utils.GetStoragePickupKeyboard(id, records)},
+	); err != nil {
+		return fmt.Errorf("error while choosing storage pickup: %w", err)
+	}
+
+	return nil
+}
+
+// LinkStoragePickup привязывает выдачу шин к записи и сообщает сотрудникам, с какого стеллажа их достать.
+func LinkStoragePickup(b *gotgbot.Bot, ctx *ext.Context) error {
+	ids := strings.SplitN(strings.TrimPrefix(ctx.Update.CallbackQuery.Data, utils.StorageLinkPrefix), ":", 2)
+	if len(ids) != 2 {
+		return fmt.Errorf("failed to parse storage pickup: %q", ctx.Update.CallbackQuery.Data)
+	}
+	id, err := strconv.ParseInt(ids[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("failed to parse storage id: %w", err)
+	}
+	recordId, err := strconv.ParseInt(ids[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("failed to parse record id: %w", err)
+	}
+
+	c, err := storageRepo.LinkStoragePickup(id, ctx.EffectiveChat.Id, recordId)
+	if errors.Is(err, db.ErrNoStorage) || errors.Is(err, db.ErrNoRecord) {
+		return ShowStoragesList(b, ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("error while linking storage pickup: %w", err)
+	}
+	record, err := recordRepo.GetRecord(recordId, ctx.EffectiveChat.Id)
+	if err != nil {
+		return fmt.Errorf("error while getting record: %w", err)
+	}
+
+	if _, _, err := ctx.EffectiveMessage.EditText(
+		b,
+		fmt.Sprintf("Подготовим шины «%s» к вашей записи на %s", c.Description, utils.FormatRecordTime(record.Datetime)),
+		&gotgbot.EditMessageTextOpts{ReplyMarkup: utils.GetStorageKeyboard(c.Id)},
+	); err != nil {
+		return fmt.Errorf("error while linking storage pickup: %w", err)
+	}
+
+	return notifyStaff(b, fmt.Sprintf(
+		"Клиент заберёт шины с хранения №%d к записи на %s\n%s\nСтеллаж: %s",
+		c.Id, utils.FormatRecordTime(record.Datetime), c.Description, c.Rack,
+	))
+}
+
+// CheckInStorage принимает шины на хранение:
+// /checkin телефон | госномер или - | описание комплекта | стеллаж | ДД.ММ.ГГГГ окончания | цена
+func CheckInStorage(b *gotgbot.Bot, ctx *ext.Context) error {
+	_, rest, _ := strings.Cut(ctx.EffectiveMessage.Text, " ")
+	fields := strings.Split(rest, "|")
+	if len(fields) != 6 {
+		return replyUsage(b, ctx, checkInUsage)
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+
+	phone, err := utils.NormalizePhone(fields[0])
+	if err != nil {
+		return replyUsage(b, ctx, "Неверный номер телефона. "+checkInUsage)
+	}
+	plate := ""
+	if fields[1] != keepValue {
+		plate = utils.NormalizePlate(fields[1])
+	}
+	if fields[2] == "" || fields[3] == "" {
+		return replyUsage(b, ctx, checkInUsage)
+	}
+	end, err := parseDate(fields[4])
+	if err != nil {
+		return replyUsage(b, ctx, checkInUsage)
+	}
+	start := utils.Today()
+	if !end.After(start) {
+		return replyUsage(b, ctx, "Дата окончания хранения должна быть позже сегодняшней")
+	}
+	price, err := strconv.Atoi(fields[5])
+	if err != nil || price < 0 {
+		return replyUsage(b, ctx, "Неверная цена. "+checkInUsage)
+	}
+
+	c, err := storageRepo.AddStorageContract(db.StorageContract{
+		Phone:       phone,
+		Plate:       plate,
+		Description: fields[2],
+		Rack:        fields[3],
+		StartsAt:    start.Unix(),
+		EndsAt:      end.Unix(),
+		Price:       price,
+	}, ctx.EffectiveSender.Id())
+	if err != nil {
+		return fmt.Errorf("error while checking in storage: %w", err)
+	}
+
+	t := fmt.Sprintf("Шины приняты на хранение\n%s\n%s", formatStorage(c), actionLine("📦 Принял", ctx.EffectiveSender.User))
+	if c.UserId == 0 {
+		t += "\nКлиента с этим телефоном нет в боте, договор появится у него после регистрации"
+	}
+	if _, err := ctx.EffectiveMessage.Reply(b, t, nil); err != nil {
+		return fmt.Errorf("error while confirming storage check-in: %w", err)
+	}
+	notifyCustomer(b, c.UserId, "Мы приняли ваши шины на хранение 🛞\n"+storageDescription(c))
+
+	return nil
+}
+
+// CheckOutStorage отмечает выдачу шин клиенту: /checkout номер
+func CheckOutStorage(b *gotgbot.Bot, ctx *ext.Context) error {
+	args := ctx.Args()[1:]
+	if len(args) != 1 {
+		return replyUsage(b, ctx, "Формат: /checkout номер (см. /storage)")
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(args[0], "№"), 10, 64)
+	if err != nil {
+		return replyUsage(b, ctx, "Формат: /checkout номер (см. /storage)")
+	}
+
+	c, err := storageRepo.CheckOutStorage(id)
+	if errors.Is(err, db.ErrNoStorage) {
+		return replyUsage(b, ctx, "Таких шин на хранении нет")
+	}
+	if err != nil {
+		return fmt.Errorf("error while checking out storage: %w", err)
+	}
+
+	if _, err := ctx.EffectiveMessage.Reply(
+		b,
+		fmt.Sprintf("Шины выданы\n%s\n%s", formatStorage(c), actionLine("📤 Выдал", ctx.EffectiveSender.User)),
+		nil,
+	); err != nil {
+		return fmt.Errorf("error while confirming storage check-out: %w", err)
+	}
+	notifyCustomer(b, c.UserId, fmt.Sprintf("Шины «%s» выданы с хранения. Спасибо, что храните шины у нас!", c.Description))
+
+	return nil
+}
+
+// formatStorage - строка о договоре хранения для сотрудников.
+func formatStorage(c db.StorageContract) string {
+	t := fmt.Sprintf("№%d, стеллаж %s: %s, %s", c.Id, c.Rack, c.Description, c.Phone)
+	if c.Vehicle.Id != 0 {
+		t += ", " + utils.VehicleTitle(c.Vehicle)
+	} else if c.Plate != "" {
+		t += ", " + c.Plate
+	}
+
+	return t + fmt.Sprintf(", до %s, %d ₽", formatDay(c.EndsAt), c.Price)
+}
+
+// ListActiveStorages показывает шины на хранении; с истёкшим сроком помечены.
+func ListActiveStorages(b *gotgbot.Bot, ctx *ext.Context) error {
+	contracts, err := storageRepo.ActiveStorageContracts()
+	if err != nil {
+		return fmt.Errorf("error while getting storage contracts: %w", err)
+	}
+
+	t := "Шин на хранении нет"
+	if len(contracts) > 0 {
+		now := utils.Now().Unix()
+		var sb strings.Builder
+		sb.WriteString("<b>Шины на хранении</b>")
+		for _, c := range contracts {
+			sb.WriteString("\n")
+			if c.EndsAt < now {
+				sb.WriteString("⏰ ")
+			}
+			sb.WriteString(html.EscapeString(formatStorage(c)))
+		}
+		t = sb.String()
+	}
+
+	if _, err := ctx.EffectiveMessage.Reply(b, t, &gotgbot.SendMessageOpts{ParseMode: "html"}); err != nil {
+		return fmt.Errorf("error while listing storage contracts: %w", err)
+	}
+
+	return nil
+}
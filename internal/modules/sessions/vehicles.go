@@ -0,0 +1,359 @@
+package sessions
+
+import (
+	"automobile36/internal/db"
+	"automobile36/internal/utils"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/filters/callbackquery"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/filters/message"
+)
+
+const (
+	VEHICLE_MAKE  = "vehicle_make"
+	VEHICLE_MODEL = "vehicle_model"
+	VEHICLE_PLATE = "vehicle_plate"
+	VEHICLE_TIRES = "vehicle_tires"
+	VEHICLE_RIM   = "vehicle_rim"
+)
+
+// keepValue в ответе оставляет поле автомобиля как есть (или пустым у нового).
+const keepValue = "-"
+
+func loadVehicleHandlers(dp *ext.Dispatcher) {
+	dp.AddHandler(handlers.NewConversation(
+		[]ext.Handler{
+			handlers.NewCallback(callbackquery.Equal(utils.VehicleAdd), StartAddVehicle),
+			handlers.NewCallback(callbackquery.Prefix(utils.VehicleEditPrefix), StartEditVehicle),
+		},
+		map[string][]ext.Handler{
+			VEHICLE_MAKE:  {handlers.NewMessage(utils.NoCommands, VehicleMake)},
+			VEHICLE_MODEL: {handlers.NewMessage(utils.NoCommands, VehicleModel)},
+			VEHICLE_PLATE: {handlers.NewMessage(utils.NoCommands, VehiclePlate)},
+			VEHICLE_TIRES: {handlers.NewMessage(utils.NoCommands, VehicleTires)},
+			VEHICLE_RIM:   {handlers.NewCallback(callbackquery.Prefix(utils.RimPrefix), VehicleRim)},
+		},
+		&handlers.ConversationOpts{
+			Exits:        []ext.Handler{handlers.NewMessage(utils.MenuButton, LeaveVehicle)},
+			Fallbacks:    []ext.Handler{handlers.NewCommand("cancel", CancelVehicle)},
+			StateStorage: conversationStorage("vehicle"),
+		},
+	))
+
+	dp.AddHandler(handlers.NewMessage(message.Equal("Мои автомобили 🚗"), ListVehicles))
+	dp.AddHandler(handlers.NewCallback(callbackquery.Equal(utils.VehiclesList), ShowVehiclesList))
+	dp.AddHandler(handlers.NewCallback(callbackquery.Prefix(utils.VehiclePrefix), ShowVehicle))
+	dp.AddHandler(handlers.NewCallback(callbackquery.Prefix(utils.VehicleRemovePrefix), RemoveVehicle))
+}
+
+func vehiclesText(vehicles []db.Vehicle) string {
+	if len(vehicles) == 0 {
+		return "У вас пока нет автомобилей. Добавьте автомобиль, чтобы выбирать его при записи"
+	}
+
+	return "Ваши автомобили"
+}
+
+// vehicleLine - строка об автомобиле для уведомления сотрудников; пустая, если автомобиль не выбран.
+func vehicleLine(v db.Vehicle) string {
+	if v.Id == 0 {
+		return ""
+	}
+
+	return "\nАвтомобиль: " + utils.VehicleTitle(v)
+}
+
+func ListVehicles(b *gotgbot.Bot, ctx *ext.Context) error {
+	if ctx.EffectiveChat.Type != "private" {
+		return nil
+	}
+	vehicles, err := vehicleRepo.GetVehicles(ctx.EffectiveChat.Id)
+	if err != nil {
+		return fmt.Errorf("error while getting vehicles: %w", err)
+	}
+
+	if _, err := ctx.EffectiveChat.SendMessage(
+		b,
+		vehiclesText(vehicles),
+		&gotgbot.SendMessageOpts{ReplyMarkup: utils.GetVehiclesKeyboard(vehicles)},
+	); err != nil {
+		return fmt.Errorf("error while listing vehicles: %w", err)
+	}
+
+	return nil
+}
+
+// ShowVehiclesList возвращает к списку автомобилей из карточки автомобиля.
+func ShowVehiclesList(b *gotgbot.Bot, ctx *ext.Context) error {
+	vehicles, err := vehicleRepo.GetVehicles(ctx.EffectiveChat.Id)
+	if err != nil {
+		return fmt.Errorf("error while getting vehicles: %w", err)
+	}
+
+	if _, _, err := ctx.EffectiveMessage.EditText(
+		b,
+		vehiclesText(vehicles),
+		&gotgbot.EditMessageTextOpts{ReplyMarkup: utils.GetVehiclesKeyboard(vehicles)},
+	); err != nil {
+		return fmt.Errorf("error while listing vehicles: %w", err)
+	}
+
+	return nil
+}
+
+// callbackVehicle находит автомобиль текущего пользователя по id из данных кнопки.
+func callbackVehicle(ctx *ext.Context, prefix string) (db.Vehicle, error) {
+	id, err := utils.ParseCallbackId(ctx.Update.CallbackQuery.Data, prefix)
+	if err != nil {
+		return db.Vehicle{}, fmt.Errorf("failed to parse vehicle id: %w", err)
+	}
+
+	return vehicleRepo.GetVehicle(id, ctx.EffectiveChat.Id)
+}
+
+func vehicleDescription(v db.Vehicle) string {
+	t := fmt.Sprintf("%s %s", v.Make, v.Model)
+	if v.Plate != "" {
+		t += "\nГосномер: " + v.Plate
+	}
+	if v.TireSize != "" {
+		t += "\nРазмер шин: " + v.TireSize
+	}
+	if v.RimType != "" {
+		t += "\nДиски: " + utils.RimTitle(v.RimType)
+	}
+
+	return t
+}
+
+func ShowVehicle(b *gotgbot.Bot, ctx *ext.Context) error {
+	v, err := callbackVehicle(ctx, utils.VehiclePrefix)
+	if errors.Is(err, db.ErrNoVehicle) {
+		return ShowVehiclesList(b, ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("error while getting vehicle: %w", err)
+	}
+
+	if _, _, err := ctx.EffectiveMessage.EditText(
+		b,
+		vehicleDescription(v),
+		&gotgbot.EditMessageTextOpts{ReplyMarkup: utils.GetVehicleKeyboard(v.Id)},
+	); err != nil {
+		return fmt.Errorf("error while showing vehicle: %w", err)
+	}
+
+	return nil
+}
+
+func RemoveVehicle(b *gotgbot.Bot, ctx *ext.Context) error {
+	id, err := utils.ParseCallbackId(ctx.Update.CallbackQuery.Data, utils.VehicleRemovePrefix)
+	if err != nil {
+		return fmt.Errorf("failed to parse vehicle id: %w", err)
+	}
+	if _, err := vehicleRepo.RemoveVehicle(id, ctx.EffectiveChat.Id); err != nil {
+		return fmt.Errorf("error while removing vehicle: %w", err)
+	}
+
+	return ShowVehiclesList(b, ctx)
+}
+
+// askVehicleField спрашивает очередное поле автомобиля. Если у поля уже есть
+// значение, его можно оставить, отправив keepValue.
+func askVehicleField(b *gotgbot.Bot, ctx *ext.Context, prompt, current string, optional bool) error {
+	switch {
+	case current != "":
+		prompt += fmt.Sprintf("\nСейчас: %s. Отправьте «%s», чтобы оставить как есть", current, keepValue)
+	case optional:
+		prompt += fmt.Sprintf("\nОтправьте «%s», чтобы пропустить", keepValue)
+	}
+	prompt += "\n/cancel — отменить"
+
+	if _, err := ctx.EffectiveChat.SendMessage(b, prompt, nil); err != nil {
+		return fmt.Errorf("error while asking vehicle field: %w", err)
+	}
+
+	return nil
+}
+
+// vehicleInput возвращает ответ пользователя и признак того, что поле нужно оставить как есть.
+func vehicleInput(ctx *ext.Context) (string, bool) {
+	text := strings.TrimSpace(ctx.EffectiveMessage.Text)
+	return text, text == keepValue
+}
+
+func vehicleDraft(ctx *ext.Context) (db.Vehicle, error) {
+	var v db.Vehicle
+	err := getData(ctx, "vehicle_draft", &v)
+
+	return v, err
+}
+
+func StartAddVehicle(b *gotgbot.Bot, ctx *ext.Context) error {
+	if err := setData(ctx, "vehicle_draft", db.Vehicle{UserId: ctx.EffectiveChat.Id}); err != nil {
+		return err
+	}
+	if err := askVehicleField(b, ctx, "Марка автомобиля, например Kia", "", false); err != nil {
+		return err
+	}
+
+	return handlers.NextConversationState(VEHICLE_MAKE)
+}
+
+func StartEditVehicle(b *gotgbot.Bot, ctx *ext.Context) error {
+	v, err := callbackVehicle(ctx, utils.VehicleEditPrefix)
+	if errors.Is(err, db.ErrNoVehicle) {
+		return ShowVehiclesList(b, ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("error while getting vehicle: %w", err)
+	}
+	if err := setData(ctx, "vehicle_draft", v); err != nil {
+		return err
+	}
+	if err := askVehicleField(b, ctx, "Марка автомобиля", v.Make, false); err != nil {
+		return err
+	}
+
+	return handlers.NextConversationState(VEHICLE_MAKE)
+}
+
+func VehicleMake(b *gotgbot.Bot, ctx *ext.Context) error {
+	v, err := vehicleDraft(ctx)
+	if err != nil {
+		return err
+	}
+	text, keep := vehicleInput(ctx)
+	if keep && v.Make == "" {
+		return askVehicleField(b, ctx, "Марку нужно указать", "", false)
+	}
+	if !keep {
+		v.Make = text
+	}
+	if err := setData(ctx, "vehicle_draft", v); err != nil {
+		return err
+	}
+	if err := askVehicleField(b, ctx, "Модель, например Rio", v.Model, false); err != nil {
+		return err
+	}
+
+	return handlers.NextConversationState(VEHICLE_MODEL)
+}
+
+func VehicleModel(b *gotgbot.Bot, ctx *ext.Context) error {
+	v, err := vehicleDraft(ctx)
+	if err != nil {
+		return err
+	}
+	text, keep := vehicleInput(ctx)
+	if keep && v.Model == "" {
+		return askVehicleField(b, ctx, "Модель нужно указать", "", false)
+	}
+	if !keep {
+		v.Model = text
+	}
+	if err := setData(ctx, "vehicle_draft", v); err != nil {
+		return err
+	}
+	if err := askVehicleField(b, ctx, "Госномер, например А123ВС136", v.Plate, true); err != nil {
+		return err
+	}
+
+	return handlers.NextConversationState(VEHICLE_PLATE)
+}
+
+func VehiclePlate(b *gotgbot.Bot, ctx *ext.Context) error {
+	v, err := vehicleDraft(ctx)
+	if err != nil {
+		return err
+	}
+	if text, keep := vehicleInput(ctx); !keep {
+		v.Plate = utils.NormalizePlate(text)
+	}
+	if err := setData(ctx, "vehicle_draft", v); err != nil {
+		return err
+	}
+	if err := askVehicleField(b, ctx, "Размер шин, например 205/55 R16", v.TireSize, true); err != nil {
+		return err
+	}
+
+	return handlers.NextConversationState(VEHICLE_TIRES)
+}
+
+func VehicleTires(b *gotgbot.Bot, ctx *ext.Context) error {
+	v, err := vehicleDraft(ctx)
+	if err != nil {
+		return err
+	}
+	if text, keep := vehicleInput(ctx); !keep {
+		size, err := utils.NormalizeTireSize(text)
+		if err != nil {
+			return askVehicleField(b, ctx, "Не получилось разобрать размер. Напишите его как на боковине шины, например 205/55 R16", v.TireSize, true)
+		}
+		v.TireSize = size
+	}
+	if err := setData(ctx, "vehicle_draft", v); err != nil {
+		return err
+	}
+
+	if _, err := ctx.EffectiveChat.SendMessage(b, "Какие у вас диски?", &gotgbot.SendMessageOpts{ReplyMarkup: utils.GetRimKeyboard()}); err != nil {
+		return fmt.Errorf("error while asking rim type: %w", err)
+	}
+
+	return handlers.NextConversationState(VEHICLE_RIM)
+}
+
+func VehicleRim(b *gotgbot.Bot, ctx *ext.Context) error {
+	v, err := vehicleDraft(ctx)
+	if err != nil {
+		return err
+	}
+	v.RimType = strings.TrimPrefix(ctx.Update.CallbackQuery.Data, utils.RimPrefix)
+	if !db.ValidRim(v.RimType) {
+		return nil
+	}
+
+	v, err = vehicleRepo.SaveVehicle(v)
+	if errors.Is(err, db.ErrNoVehicle) {
+		if _, _, err := ctx.EffectiveMessage.EditText(b, "Этот автомобиль уже удалён", nil); err != nil {
+			return fmt.Errorf("error while saving vehicle: %w", err)
+		}
+		return handlers.EndConversation()
+	}
+	if err != nil {
+		return fmt.Errorf("error while saving vehicle: %w", err)
+	}
+
+	if _, _, err := ctx.EffectiveMessage.EditText(
+		b,
+		"Автомобиль сохранён\n\n"+vehicleDescription(v),
+		&gotgbot.EditMessageTextOpts{ReplyMarkup: utils.GetVehicleKeyboard(v.Id)},
+	); err != nil {
+		return fmt.Errorf("error while saving vehicle: %w", err)
+	}
+
+	return handlers.EndConversation()
+}
+
+func CancelVehicle(b *gotgbot.Bot, ctx *ext.Context) error {
+	if _, err := ctx.EffectiveChat.SendMessage(b, "Изменения автомобиля отменены", nil); err != nil {
+		return fmt.Errorf("error while cancelling vehicle edit: %w", err)
+	}
+
+	return handlers.EndConversation()
+}
+
+// LeaveVehicle прерывает добавление автомобиля, когда клиент нажал кнопку меню
+// вместо ответа.
+func LeaveVehicle(b *gotgbot.Bot, ctx *ext.Context) error {
+	if _, err := ctx.EffectiveChat.SendMessage(b, "Изменения автомобиля отменены, нажмите кнопку меню ещё раз", nil); err != nil {
+		return fmt.Errorf("error while leaving vehicle edit: %w", err)
+	}
+
+	return handlers.EndConversation()
+}
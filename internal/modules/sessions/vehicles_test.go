@@ -0,0 +1,37 @@
+package sessions_test
+
+import (
+	"automobile36/internal/utils"
+	"testing"
+)
+
+// TestLeaveVehicleByMenu проверяет, что кнопка меню посреди добавления автомобиля
+// завершает диалог и не сохраняется как марка.
+func TestLeaveVehicleByMenu(t *testing.T) {
+	bt := newBot(t, 1)
+	if err := bt.store.SaveUser(10, "Иван", "+79990000000"); err != nil {
+		t.Fatal(err)
+	}
+
+	bt.press(10, utils.VehicleAdd)
+	bt.message(10, "Мои автомобили 🚗")
+
+	client := bt.client.sentTo(10)
+	if last := client[len(client)-1]; last != "Изменения автомобиля отменены, нажмите кнопку меню ещё раз" {
+		t.Errorf("last message = %q, want the vehicle edit to be cancelled", last)
+	}
+
+	// Диалог завершён: кнопка снова открывает список, а не отвечает на вопрос о модели.
+	bt.message(10, "Мои автомобили 🚗")
+	client = bt.client.sentTo(10)
+	if last := client[len(client)-1]; last != "У вас пока нет автомобилей. Добавьте автомобиль, чтобы выбирать его при записи" {
+		t.Errorf("last message = %q, want the list of vehicles", last)
+	}
+	vehicles, err := bt.store.GetVehicles(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vehicles) != 0 {
+		t.Errorf("vehicles = %+v, want none", vehicles)
+	}
+}
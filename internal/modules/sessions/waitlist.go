@@ -0,0 +1,174 @@
+package sessions
+
+import (
+	"automobile36/internal/db"
+	"automobile36/internal/schedule"
+	"automobile36/internal/utils"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/filters/callbackquery"
+)
+
+func loadWaitlistHandlers(dp *ext.Dispatcher) {
+	dp.AddHandler(handlers.NewCallback(callbackquery.Prefix(utils.WaitlistClaimPrefix), ClaimWaitlistSlot))
+	dp.AddHandler(handlers.NewCallback(callbackquery.Prefix(utils.WaitlistLeavePrefix), LeaveWaitlist))
+}
+
+// parseWindow разбирает окно "<с>-<до>" в минутах от полуночи из данных кнопки.
+func parseWindow(data string) (schedule.Clock, schedule.Clock, error) {
+	from, to, ok := strings.Cut(strings.TrimPrefix(data, utils.WaitlistJoinPrefix), "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("bad waitlist window %q", data)
+	}
+	fromMin, err := strconv.Atoi(from)
+	if err != nil {
+		return 0, 0, err
+	}
+	toMin, err := strconv.Atoi(to)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return schedule.Clock(time.Duration(fromMin) * time.Minute), schedule.Clock(time.Duration(toMin) * time.Minute), nil
+}
+
+func windowTitle(from, to schedule.Clock) string {
+	switch {
+	case from == 0 && to >= schedule.Clock(24*time.Hour):
+		return "любое время"
+	case from == 0:
+		return "до " + to.String()
+	default:
+		return "с " + from.String()
+	}
+}
+
+// JoinWaitlist ставит клиента в лист ожидания на выбранный день с выбранной услугой и автомобилем.
+func JoinWaitlist(b *gotgbot.Bot, ctx *ext.Context) error {
+	from, to, err := parseWindow(ctx.Update.CallbackQuery.Data)
+	if err != nil {
+		return fmt.Errorf("failed to parse waitlist window: %w", err)
+	}
+	var chosenDate time.Time
+	if err := getData(ctx, newRecordFlow.key("chosen_date"), &chosenDate); err != nil {
+		return err
+	}
+	chosenDate = chosenDate.In(conf.Shop.Location)
+	service, err := chosenService(ctx, newRecordFlow)
+	if err != nil {
+		return err
+	}
+	vehicle, err := chosenVehicle(ctx, newRecordFlow)
+	if err != nil {
+		return err
+	}
+
+	entry, err := waitlistRepo.AddWaitlist(db.WaitlistEntry{
+		UserId:    ctx.EffectiveChat.Id,
+		Day:       chosenDate.Unix(),
+		From:      from,
+		To:        to,
+		ServiceId: service.Id,
+		Duration:  service.Duration,
+		VehicleId: vehicle.Id,
+	}, utils.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("error while joining waitlist: %w", err)
+	}
+
+	if _, _, err := ctx.EffectiveMessage.EditText(
+		b,
+		fmt.Sprintf(
+			"Вы в листе ожидания на %s (%s), услуга: %s.\nЕсли время освободится, мы сразу напишем — успейте записаться.",
+			chosenDate.Format("02.01.2006"), windowTitle(from, to), service.Name,
+		),
+		&gotgbot.EditMessageTextOpts{ReplyMarkup: utils.GetWaitlistKeyboard(entry.Id)},
+	); err != nil {
+		return fmt.Errorf("error while joining waitlist: %w", err)
+	}
+	if _, err := ctx.EffectiveChat.SendMessage(
+		b,
+		"Возвращаемся в меню",
+		&gotgbot.SendMessageOpts{ReplyMarkup: utils.GetRecordsKeyboard()},
+	); err != nil {
+		return fmt.Errorf("error while back up to menu: %w", err)
+	}
+
+	return handlers.EndConversation()
+}
+
+// ClaimWaitlistSlot записывает клиента на предложенное из листа ожидания время.
+func ClaimWaitlistSlot(b *gotgbot.Bot, ctx *ext.Context) error {
+	id, err := utils.ParseCallbackId(ctx.Update.CallbackQuery.Data, utils.WaitlistClaimPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to parse waitlist id: %w", err)
+	}
+	record, err := waitlistRepo.ClaimWaitlist(id, ctx.EffectiveChat.Id, utils.Now().Unix(), conf.Shop.Bays)
+	if errors.Is(err, db.ErrNoWaitlist) {
+		if _, _, err := ctx.EffectiveMessage.EditText(b, "Это предложение уже не действует", nil); err != nil {
+			return fmt.Errorf("error while claiming waitlist slot: %w", err)
+		}
+		return nil
+	}
+	if errors.Is(err, db.ErrSlotTaken) {
+		if err := waitlistRepo.SetWaitlistStatus(id, db.WaitlistWaiting); err != nil {
+			return fmt.Errorf("error while returning to waitlist: %w", err)
+		}
+		if _, _, err := ctx.EffectiveMessage.EditText(
+			b,
+			"Это время уже заняли. Вы остаётесь в листе ожидания",
+			&gotgbot.EditMessageTextOpts{ReplyMarkup: utils.GetWaitlistKeyboard(id)},
+		); err != nil {
+			return fmt.Errorf("error while claiming waitlist slot: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error while claiming waitlist slot: %w", err)
+	}
+
+	service, err := catalogRepo.GetService(record.ServiceId)
+	if err != nil {
+		return fmt.Errorf("error while getting service: %w", err)
+	}
+	var vehicle db.Vehicle
+	if record.Vehicle.Id != 0 {
+		vehicle, err = vehicleRepo.GetVehicle(record.Vehicle.Id, record.UserId)
+		if err != nil && !errors.Is(err, db.ErrNoVehicle) {
+			return fmt.Errorf("error while getting vehicle: %w", err)
+		}
+	}
+
+	if _, _, err := ctx.EffectiveMessage.EditText(
+		b,
+		fmt.Sprintf("Вы успешно записались на %s!\nУслуга: %s", utils.FormatRecordTime(record.Datetime), service.Name),
+		nil,
+	); err != nil {
+		return fmt.Errorf("error while claiming waitlist slot: %w", err)
+	}
+
+	return notifyNewRecord(b, record, service, vehicle, "\nИз листа ожидания")
+}
+
+func LeaveWaitlist(b *gotgbot.Bot, ctx *ext.Context) error {
+	id, err := utils.ParseCallbackId(ctx.Update.CallbackQuery.Data, utils.WaitlistLeavePrefix)
+	if err != nil {
+		return fmt.Errorf("failed to parse waitlist id: %w", err)
+	}
+	if _, err := waitlistRepo.CancelWaitlist(id, ctx.EffectiveChat.Id); err != nil {
+		return fmt.Errorf("error while leaving waitlist: %w", err)
+	}
+
+	if _, _, err := ctx.EffectiveMessage.EditText(b, "Вы больше не в листе ожидания на этот день", nil); err != nil {
+		return fmt.Errorf("error while leaving waitlist: %w", err)
+	}
+
+	return nil
+}
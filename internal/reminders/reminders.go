@@ -0,0 +1,108 @@
+// Package reminders рассылает клиентам напоминания о предстоящих записях.
+package reminders
+
+import (
+	"automobile36/internal/clock"
+	"automobile36/internal/config"
+	"automobile36/internal/db"
+	"automobile36/internal/sender"
+	"automobile36/internal/utils"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+)
+
+type Scheduler struct {
+	sender   sender.Sender
+	repo     db.ReminderRepository
+	clock    clock.Clock
+	before   []time.Duration
+	interval time.Duration
+}
+
+func New(sender sender.Sender, repo db.ReminderRepository, clock clock.Clock, cfg config.Reminders) *Scheduler {
+	before := append([]time.Duration(nil), cfg.Before...)
+	sort.Slice(before, func(i, j int) bool { return before[i] < before[j] })
+
+	return &Scheduler{
+		sender:   sender,
+		repo:     repo,
+		clock:    clock,
+		before:   before,
+		interval: cfg.Interval,
+	}
+}
+
+// Run проверяет напоминания раз в interval и не возвращается. Если напоминания
+// отключены, сразу выходит.
+func (s *Scheduler) Run() {
+	if len(s.before) == 0 {
+		return
+	}
+
+	for ; ; time.Sleep(s.interval) {
+		if err := s.Tick(); err != nil {
+			log.Println("failed to send reminders:", err.Error())
+		}
+	}
+}
+
+// Tick отправляет все напоминания, которым пора уйти к текущему моменту.
+func (s *Scheduler) Tick() error {
+	now := s.clock.Now().Unix()
+
+	var errs []error
+	// От ближайшего напоминания к дальнему: если к записи пора отправить сразу
+	// несколько, клиент получит только последнее по смыслу.
+	for i, before := range s.before {
+		records, err := s.repo.DueReminders(now, before)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, record := range records {
+			ok, err := s.repo.MarkReminderSent(record.Id, before)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			for _, earlier := range s.before[i+1:] {
+				if _, err := s.repo.MarkReminderSent(record.Id, earlier); err != nil {
+					errs = append(errs, err)
+				}
+			}
+
+			// Клиент мог заблокировать бота; напоминание уже отмечено, повторять его не нужно.
+			if err := s.send(record); err != nil {
+				log.Printf("failed to remind customer %d: %s", record.UserId, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *Scheduler) send(record db.Record) error {
+	t := fmt.Sprintf("⏰ Напоминаем о записи на %s", utils.FormatRecordTime(record.Datetime))
+	if record.ServiceName != "" {
+		t += "\nУслуга: " + record.ServiceName
+	}
+	t += "\n\nПриедете?"
+
+	_, err := s.sender.SendMessage(record.UserId, t, &gotgbot.SendMessageOpts{
+		ReplyMarkup: utils.GetReminderKeyboard(record.Id),
+	})
+	if err != nil {
+		return fmt.Errorf("error while sending reminder: %w", err)
+	}
+
+	return nil
+}
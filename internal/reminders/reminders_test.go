@@ -0,0 +1,141 @@
+package reminders_test
+
+import (
+	"automobile36/internal/config"
+	"automobile36/internal/db"
+	"automobile36/internal/db/dbtest"
+	"automobile36/internal/reminders"
+	"automobile36/internal/utils"
+	"testing"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+// fakeSender запоминает, кому ушли сообщения.
+type fakeSender struct {
+	sent []int64
+}
+
+func (f *fakeSender) SendMessage(chatId int64, text string, opts *gotgbot.SendMessageOpts) (*gotgbot.Message, error) {
+	f.sent = append(f.sent, chatId)
+	return &gotgbot.Message{}, nil
+}
+
+var cfg = config.Reminders{Before: []time.Duration{24 * time.Hour, 2 * time.Hour}, Interval: time.Minute}
+
+func setup(t *testing.T, s *db.Store) (*fakeClock, *fakeSender) {
+	t.Helper()
+	utils.Init(&config.Config{Shop: config.Shop{Location: dbtest.Location, Bays: 1}}, s)
+
+	return &fakeClock{}, &fakeSender{}
+}
+
+func saveRecord(t *testing.T, s *db.Store, userId int64, at time.Time) db.Record {
+	t.Helper()
+
+	record, err := s.SaveRecord(userId, 0, at.Unix(), 0, time.Hour, 2, time.Now().Unix())
+	if err != nil {
+		t.Fatalf("SaveRecord(): %s", err)
+	}
+
+	return record
+}
+
+// tick переводит часы на at, запускает Tick и сверяет, кому ушли напоминания за всё время.
+func tick(t *testing.T, sch *reminders.Scheduler, clock *fakeClock, sender *fakeSender, at time.Time, want ...int64) {
+	t.Helper()
+
+	clock.now = at
+	if err := sch.Tick(); err != nil {
+		t.Fatalf("Tick() at %s: %s", at, err)
+	}
+	if len(sender.sent) != len(want) {
+		t.Fatalf("at %s sent to %v, want %v", at.In(dbtest.Location).Format(time.DateTime), sender.sent, want)
+	}
+	for i := range want {
+		if sender.sent[i] != want[i] {
+			t.Fatalf("at %s sent to %v, want %v", at.In(dbtest.Location).Format(time.DateTime), sender.sent, want)
+		}
+	}
+}
+
+func TestTickOffsets(t *testing.T) {
+	dbtest.Each(t, func(t *testing.T, s *db.Store) {
+		clock, sender := setup(t, s)
+		start := time.Now().Add(72 * time.Hour).Truncate(time.Minute)
+		saveRecord(t, s, 1, start)
+		sch := reminders.New(sender, s, clock, cfg)
+
+		tick(t, sch, clock, sender, start.Add(-25*time.Hour))
+		tick(t, sch, clock, sender, start.Add(-24*time.Hour), 1)
+		tick(t, sch, clock, sender, start.Add(-23*time.Hour), 1)
+		tick(t, sch, clock, sender, start.Add(-2*time.Hour-time.Minute), 1)
+		tick(t, sch, clock, sender, start.Add(-2*time.Hour), 1, 1)
+		tick(t, sch, clock, sender, start.Add(-time.Minute), 1, 1)
+		tick(t, sch, clock, sender, start.Add(time.Minute), 1, 1)
+	})
+}
+
+func TestTickBookedLate(t *testing.T) {
+	dbtest.Each(t, func(t *testing.T, s *db.Store) {
+		clock, sender := setup(t, s)
+		now := time.Now()
+		// Запись за 5 часов: напоминание за сутки уже не нужно, за 2 часа - нужно.
+		soon := saveRecord(t, s, 1, now.Add(5*time.Hour).Truncate(time.Minute))
+		// Запись через час: не нужно ни одно напоминание.
+		saveRecord(t, s, 2, now.Add(time.Hour).Truncate(time.Minute))
+		sch := reminders.New(sender, s, clock, cfg)
+
+		tick(t, sch, clock, sender, now)
+		tick(t, sch, clock, sender, now.Add(30*time.Minute))
+		tick(t, sch, clock, sender, time.Unix(soon.Datetime, 0).Add(-2*time.Hour), 1)
+	})
+}
+
+func TestTickRestart(t *testing.T) {
+	dbtest.Each(t, func(t *testing.T, s *db.Store) {
+		clock, sender := setup(t, s)
+		start := time.Now().Add(72 * time.Hour).Truncate(time.Minute)
+		saveRecord(t, s, 1, start)
+
+		// Пора отправить оба напоминания сразу: уходит одно, ближайшее.
+		tick(t, reminders.New(sender, s, clock, cfg), clock, sender, start.Add(-time.Hour), 1)
+		tick(t, reminders.New(sender, s, clock, cfg), clock, sender, start.Add(-time.Hour), 1)
+		tick(t, reminders.New(sender, s, clock, cfg), clock, sender, start.Add(-time.Minute), 1)
+	})
+}
+
+func TestTickMovedRecord(t *testing.T) {
+	dbtest.Each(t, func(t *testing.T, s *db.Store) {
+		clock, sender := setup(t, s)
+		start := time.Now().Add(72 * time.Hour).Truncate(time.Minute)
+		record := saveRecord(t, s, 1, start)
+		if _, err := s.SetStatus(record.Id, db.StatusConfirmed); err != nil {
+			t.Fatal(err)
+		}
+		sch := reminders.New(sender, s, clock, cfg)
+
+		tick(t, sch, clock, sender, start.Add(-23*time.Hour), 1)
+
+		moved := start.Add(48 * time.Hour)
+		if _, err := s.MoveRecord(record.Id, 1, moved.Unix(), 2, clock.now.Unix()); err != nil {
+			t.Fatalf("MoveRecord(): %s", err)
+		}
+		if r, err := s.GetRecordById(record.Id); err != nil || r.Status != db.StatusPending {
+			t.Fatalf("moved record status = %q, %v; want %q", r.Status, err, db.StatusPending)
+		}
+
+		tick(t, sch, clock, sender, start.Add(-22*time.Hour), 1)
+		tick(t, sch, clock, sender, moved.Add(-24*time.Hour), 1, 1)
+		tick(t, sch, clock, sender, moved.Add(-2*time.Hour), 1, 1, 1)
+	})
+}
@@ -0,0 +1,177 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Clock - время суток, отсчитываемое от полуночи. В конфиге задаётся строкой "15:04".
+type Clock time.Duration
+
+func ParseClock(s string) (Clock, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+
+	return Clock(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute), nil
+}
+
+func (c *Clock) UnmarshalText(text []byte) error {
+	parsed, err := ParseClock(string(text))
+	if err != nil {
+		return err
+	}
+	*c = parsed
+
+	return nil
+}
+
+func (c Clock) String() string {
+	d := time.Duration(c)
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+type Interval struct {
+	From Clock `yaml:"from"`
+	To   Clock `yaml:"to"`
+}
+
+// Day - часы работы в конкретный день недели.
+type Day struct {
+	Open   Clock      `yaml:"open"`
+	Close  Clock      `yaml:"close"`
+	Breaks []Interval `yaml:"breaks"`
+}
+
+// Schedule - график работы мастерской. Ключи Days - названия дней недели
+// в нижнем регистре ("monday" ... "sunday"); отсутствующий день считается выходным.
+type Schedule struct {
+	SlotMinutes int            `yaml:"slot_minutes"`
+	Days        map[string]Day `yaml:"days"`
+}
+
+// Default повторяет прежний график: ежедневно с 09:00 до 21:00, запись каждые полтора часа.
+func Default() Schedule {
+	day := Day{Open: Clock(9 * time.Hour), Close: Clock(21 * time.Hour)}
+	days := make(map[string]Day, 7)
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		days[dayKey(wd)] = day
+	}
+
+	return Schedule{SlotMinutes: 90, Days: days}
+}
+
+func dayKey(wd time.Weekday) string {
+	return strings.ToLower(wd.String())
+}
+
+func (s *Schedule) SlotLength() time.Duration {
+	return time.Duration(s.SlotMinutes) * time.Minute
+}
+
+// Slots возвращает начала всех слотов для дня недели wd в порядке возрастания.
+func (s *Schedule) Slots(wd time.Weekday) []Clock {
+	day, ok := s.Days[dayKey(wd)]
+	if !ok {
+		return nil
+	}
+
+	slot := Clock(s.SlotLength())
+	var slots []Clock
+	for start := day.Open; start+slot <= day.Close; {
+		end := start + slot
+		if br, ok := day.breakWithin(start, end); ok {
+			start = br.To
+			continue
+		}
+		slots = append(slots, start)
+		start = end
+	}
+
+	return slots
+}
+
+func (d Day) breakWithin(from, to Clock) (Interval, bool) {
+	for _, br := range d.Breaks {
+		if br.From < to && from < br.To {
+			return br, true
+		}
+	}
+
+	return Interval{}, false
+}
+
+// Fits сообщает, укладывается ли работа длительностью d, начатая в start,
+// в часы работы дня недели wd, не задевая перерывов.
+func (s *Schedule) Fits(wd time.Weekday, start Clock, d time.Duration) bool {
+	day, ok := s.Days[dayKey(wd)]
+	if !ok {
+		return false
+	}
+	end := start + Clock(d)
+	if start < day.Open || end > day.Close {
+		return false
+	}
+	_, overlaps := day.breakWithin(start, end)
+
+	return !overlaps
+}
+
+// IsSlot сообщает, является ли строка "15:04" началом слота хотя бы в один из дней.
+func (s *Schedule) IsSlot(t string) bool {
+	c, err := ParseClock(t)
+	if err != nil {
+		return false
+	}
+
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		for _, slot := range s.Slots(wd) {
+			if slot == c {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (s *Schedule) Validate() error {
+	var errs []error
+
+	if s.SlotMinutes <= 0 {
+		errs = append(errs, errors.New("schedule.slot_minutes must be positive"))
+	}
+
+	valid := make(map[string]bool, 7)
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		valid[dayKey(wd)] = true
+	}
+
+	keys := make([]string, 0, len(s.Days))
+	for key := range s.Days {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		day := s.Days[key]
+		if !valid[key] {
+			errs = append(errs, fmt.Errorf("schedule.days: unknown weekday %q", key))
+			continue
+		}
+		if day.Open >= day.Close {
+			errs = append(errs, fmt.Errorf("schedule.days.%s: open %s must be before close %s", key, day.Open, day.Close))
+		}
+		for _, br := range day.Breaks {
+			if br.From >= br.To || br.From < day.Open || br.To > day.Close {
+				errs = append(errs, fmt.Errorf("schedule.days.%s: break %s-%s is outside working hours", key, br.From, br.To))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
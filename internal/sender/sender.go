@@ -0,0 +1,16 @@
+// Package sender отделяет фоновые задачи от Telegram, чтобы их можно было
+// проверять без настоящего бота.
+package sender
+
+import "github.com/PaulSonOfLars/gotgbot/v2"
+
+// Sender отправляет сообщения; его реализует *gotgbot.Bot.
+type Sender interface {
+	SendMessage(chatId int64, text string, opts *gotgbot.SendMessageOpts) (*gotgbot.Message, error)
+}
+
+// PhotoSender отправляет ещё и фотографии.
+type PhotoSender interface {
+	Sender
+	SendPhoto(chatId int64, photo gotgbot.InputFile, opts *gotgbot.SendPhotoOpts) (*gotgbot.Message, error)
+}
@@ -3,7 +3,6 @@ package utils
 import (
 	"fmt"
 	"github.com/PaulSonOfLars/gotgbot/v2"
-	"github.com/patrickmn/go-cache"
 	"time"
 )
 
@@ -21,16 +20,24 @@ const (
 
 var weekDays = [7]string{"Пн", "Вт", "Ср", "Чт", "Пт", "Сб", "Вс"}
 var monthNames = [12]string{"Январь", "Февраль", "Март", "Апрель", "Май", "Июнь", "Июль", "Август", "Сентябрь", "Октябрь", "Ноябрь", "Декабрь"}
-var CalendarCache = cache.New(5*time.Minute, 10*time.Minute)
 
-func SimpleCalendar(userId string, year int, month time.Month) gotgbot.InlineKeyboardMarkup {
+// ShortWeekday - сокращённое название дня недели t, например "Пн".
+func ShortWeekday(t time.Time) string {
+	return weekDays[(int(t.Weekday())+6)%7]
+}
+
+// SimpleCalendar строит календарь на месяц. Закрытые дни и выходные по графику
+// выводятся зачёркнутыми и не нажимаются.
+func SimpleCalendar(userId string, year int, month time.Month) (gotgbot.InlineKeyboardMarkup, error) {
 	var kb [][]gotgbot.InlineKeyboardButton
 	data := CalendarCallback{
 		Year:  year,
 		Month: month,
 		day:   1,
 	}
-	CalendarCache.Set(userId, data, cache.DefaultExpiration)
+	if err := sessionRepo.SetSession(userId+"_calendar", data, conf.DB.SessionTTL); err != nil {
+		return gotgbot.InlineKeyboardMarkup{}, fmt.Errorf("error while saving calendar state: %w", err)
+	}
 
 	monthName := []gotgbot.InlineKeyboardButton{{Text: fmt.Sprintf("%s %d", monthNames[month-1], year), CallbackData: IGNORE}}
 	kb = append(kb, monthName)
@@ -47,6 +54,11 @@ func SimpleCalendar(userId string, year int, month time.Month) gotgbot.InlineKey
 		initWeekDay = 7 // Коррекция значения воскресенья на 7
 	}
 
+	closed, err := closedDays(year, month)
+	if err != nil {
+		return gotgbot.InlineKeyboardMarkup{}, err
+	}
+
 	calendar := [6][7]int{}
 	for i := 1; i <= totalDays; i++ {
 		row := (i - 1 + initWeekDay - 1) / 7
@@ -57,7 +69,10 @@ func SimpleCalendar(userId string, year int, month time.Month) gotgbot.InlineKey
 	for _, week := range calendar {
 		var row []gotgbot.InlineKeyboardButton
 		for _, day := range week {
-			if day != 0 {
+			if day != 0 && closed[day] {
+				button := gotgbot.InlineKeyboardButton{Text: strikethrough(fmt.Sprintf("%d", day)), CallbackData: IGNORE}
+				row = append(row, button)
+			} else if day != 0 {
 				button := gotgbot.InlineKeyboardButton{Text: fmt.Sprintf("%d", day), CallbackData: fmt.Sprintf("%d", day)}
 				row = append(row, button)
 			} else {
@@ -85,5 +100,67 @@ func SimpleCalendar(userId string, year int, month time.Month) gotgbot.InlineKey
 	}
 	kb = append(kb, selectMonthRow)
 
-	return gotgbot.InlineKeyboardMarkup{InlineKeyboard: kb}
+	return gotgbot.InlineKeyboardMarkup{InlineKeyboard: kb}, nil
+}
+
+// GetCalendarState возвращает месяц, который сейчас показан пользователю userId.
+func GetCalendarState(userId string) (CalendarCallback, error) {
+	var data CalendarCallback
+	found, err := sessionRepo.GetSession(userId+"_calendar", &data)
+	if err != nil {
+		return CalendarCallback{}, err
+	}
+	if !found {
+		return CalendarCallback{}, fmt.Errorf("calendar state for %s not found", userId)
+	}
+
+	return data, nil
+}
+
+// IsClosed сообщает, закрыт ли день для записи, и причину закрытия.
+func IsClosed(day time.Time) (string, bool, error) {
+	if len(conf.Schedule.Slots(day.Weekday())) == 0 {
+		return "выходной", true, nil
+	}
+
+	days, err := closedDayRepo.GetClosedDays(day.Unix(), day.Unix()+1)
+	if err != nil {
+		return "", false, err
+	}
+	if len(days) == 0 {
+		return "", false, nil
+	}
+
+	return days[0].Reason, true, nil
+}
+
+func closedDays(year int, month time.Month) (map[int]bool, error) {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, conf.Shop.Location)
+	next := first.AddDate(0, 1, 0)
+
+	closed := make(map[int]bool)
+	for d := first; d.Before(next); d = d.AddDate(0, 0, 1) {
+		if len(conf.Schedule.Slots(d.Weekday())) == 0 {
+			closed[d.Day()] = true
+		}
+	}
+
+	days, err := closedDayRepo.GetClosedDays(first.Unix(), next.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("error while getting closed days: %w", err)
+	}
+	for _, d := range days {
+		closed[time.Unix(d.Day, 0).In(conf.Shop.Location).Day()] = true
+	}
+
+	return closed, nil
+}
+
+func strikethrough(s string) string {
+	var out []rune
+	for _, r := range s {
+		out = append(out, r, '\u0336')
+	}
+
+	return string(out)
 }
@@ -4,12 +4,93 @@ import (
 	"github.com/PaulSonOfLars/gotgbot/v2"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/filters/message"
 	"strconv"
+	"strings"
 )
 
+const (
+	ServicePrefix       = "service:"
+	RecordPrefix        = "record:"
+	CancelPrefix        = "cancel:"
+	CancelConfirmPrefix = "cancel_yes:"
+	MovePrefix          = "move:"
+	VisitPrefix         = "visit:"
+	RecordsList         = "records_list"
+
+	// Автомобили клиента.
+	VehiclesList        = "vehicles_list"
+	VehicleAdd          = "vehicle_add"
+	VehiclePrefix       = "vehicle:"
+	VehicleEditPrefix   = "vehicle_edit:"
+	VehicleRemovePrefix = "vehicle_rm:"
+	RimPrefix           = "rim:"
+	// Выбор автомобиля при записи, id = 0 - без автомобиля.
+	RecordVehiclePrefix = "record_vehicle:"
+	// Другая дата вместо дня без свободного времени.
+	OtherDate = "other_date"
+
+	// Лист ожидания. Окно в WaitlistJoinPrefix - "<с>-<до>" в минутах от полуночи.
+	WaitlistJoinPrefix  = "waitlist_join:"
+	WaitlistClaimPrefix = "waitlist_claim:"
+	WaitlistLeavePrefix = "waitlist_leave:"
+
+	// Шины клиента на хранении.
+	StoragesList        = "storages_list"
+	StoragePrefix       = "storage:"
+	StoragePickupPrefix = "storage_pickup:"
+	// Привязка выдачи шин к записи: "<prefix><id договора>:<id записи>".
+	StorageLinkPrefix = "storage_link:"
+
+	// Кнопки под уведомлением о записи в группе сотрудников.
+	StaffConfirmPrefix = "staff_confirm:"
+	StaffRejectPrefix  = "staff_reject:"
+	StaffCallPrefix    = "staff_call:"
+	StaffArrivedPrefix = "staff_arrived:"
+	StaffStartPrefix   = "staff_start:"
+	StaffDonePrefix    = "staff_done:"
+	StaffNoShowPrefix  = "staff_noshow:"
+	// Выбор услуги для записи без Telegram.
+	StaffWalkInPrefix = "staff_walkin:"
+
+	// Рассылка клиентам.
+	BroadcastAudiencePrefix = "broadcast_audience:"
+	BroadcastSend           = "broadcast_send"
+	BroadcastCancel         = "broadcast_cancel"
+)
+
+// menuButtons - подписи кнопок главного меню и меню записи.
+var menuButtons = map[string]bool{
+	"Запись 📃":                  true,
+	"Прайс лист 💵":              true,
+	"Наши контакты ☎":           true,
+	"Мы на картах 🗺️":           true,
+	"Добавить запись 📝":         true,
+	"Изменить номер телефона 📱": true,
+	"Ваши записи 📜":             true,
+	"Мои автомобили 🚗":          true,
+	"Мои шины на хранении 🛞":    true,
+	"Назад 👈":                   true,
+}
+
+// MenuButton пропускает нажатие кнопки меню, чтобы диалог с вводом текста мог
+// на нём завершиться, а не принять подпись кнопки за ответ.
+func MenuButton(msg *gotgbot.Message) bool {
+	return message.Text(msg) && menuButtons[msg.Text]
+}
+
 func NoCommands(msg *gotgbot.Message) bool {
 	return message.Text(msg) && !message.Command(msg)
 }
 
+// PhoneInput пропускает присланный контакт или текст с номером.
+func PhoneInput(msg *gotgbot.Message) bool {
+	return message.Contact(msg) || NoCommands(msg)
+}
+
+// BroadcastInput пропускает текст или фото с подписью для рассылки.
+func BroadcastInput(msg *gotgbot.Message) bool {
+	return message.Photo(msg) || NoCommands(msg)
+}
+
 func Confirms(cq *gotgbot.CallbackQuery) bool {
 	return cq.Data == "yes" || cq.Data == "no"
 }
@@ -28,5 +109,14 @@ func DateSelection(cq *gotgbot.CallbackQuery) bool {
 }
 
 func TimeSelection(cq *gotgbot.CallbackQuery) bool {
-	return cq.Data == "09:00" || cq.Data == "10:30" || cq.Data == "12:00" || cq.Data == "13:30" || cq.Data == "15:00" || cq.Data == "16:30" || cq.Data == "18:00" || cq.Data == "19:30"
+	return conf.Schedule.IsSlot(cq.Data)
+}
+
+func ServiceSelection(cq *gotgbot.CallbackQuery) bool {
+	return strings.HasPrefix(cq.Data, ServicePrefix)
+}
+
+// ParseCallbackId достаёт числовой идентификатор из данных вида "<prefix><id>".
+func ParseCallbackId(data, prefix string) (int64, error) {
+	return strconv.ParseInt(strings.TrimPrefix(data, prefix), 10, 64)
 }
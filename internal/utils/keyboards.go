@@ -2,8 +2,10 @@ package utils
 
 import (
 	"automobile36/internal/db"
+	"automobile36/internal/schedule"
 	"fmt"
 	"github.com/PaulSonOfLars/gotgbot/v2"
+	"strconv"
 	"time"
 )
 
@@ -33,25 +35,41 @@ func GetMenuKeyboard() gotgbot.ReplyKeyboardMarkup {
 	}
 }
 
+// GetPhoneKeyboard предлагает отправить свой номер кнопкой вместо ввода вручную.
+func GetPhoneKeyboard() gotgbot.ReplyKeyboardMarkup {
+	return gotgbot.ReplyKeyboardMarkup{
+		ResizeKeyboard: true,
+		Keyboard: [][]gotgbot.KeyboardButton{
+			{{Text: "Поделиться номером 📱", RequestContact: true}},
+		},
+	}
+}
+
 func GetRecordsKeyboard() gotgbot.ReplyKeyboardMarkup {
 	b1 := gotgbot.KeyboardButton{Text: "Добавить запись 📝"}
 	b2 := gotgbot.KeyboardButton{Text: "Изменить номер телефона 📱"}
 	b3 := gotgbot.KeyboardButton{Text: "Ваши записи 📜"}
-	b4 := gotgbot.KeyboardButton{Text: "Назад 👈"}
+	b4 := gotgbot.KeyboardButton{Text: "Мои автомобили 🚗"}
+	b5 := gotgbot.KeyboardButton{Text: "Мои шины на хранении 🛞"}
+	b6 := gotgbot.KeyboardButton{Text: "Назад 👈"}
 
 	return gotgbot.ReplyKeyboardMarkup{
 		ResizeKeyboard: true,
 		Keyboard: [][]gotgbot.KeyboardButton{
 			{b1, b2},
 			{b3, b4},
+			{b5},
+			{b6},
 		},
 	}
 }
 
-func GetTimesKeyboard(result int64) (gotgbot.InlineKeyboardMarkup, error) {
+// GetTimesKeyboard - свободное время на день date для работы длительностью duration.
+// Переносимая запись exclude своё время не занимает.
+func GetTimesKeyboard(date time.Time, duration time.Duration, exclude int64) (gotgbot.InlineKeyboardMarkup, error) {
 	kb := [][]gotgbot.InlineKeyboardButton{{}}
 
-	times, err := db.GetAllTimes(result)
+	times, err := db.GetAllTimes(recordRepo, &conf.Schedule, conf.Shop.Bays, date, duration, exclude, Now())
 	if err != nil {
 		return gotgbot.InlineKeyboardMarkup{}, fmt.Errorf("error while getting times: %w", err)
 	}
@@ -76,15 +94,314 @@ func GetTimesKeyboard(result int64) (gotgbot.InlineKeyboardMarkup, error) {
 	}, nil
 }
 
-func GetAllUserRecordsKeyboard(records []int) gotgbot.InlineKeyboardMarkup {
+func GetServicesKeyboard() (gotgbot.InlineKeyboardMarkup, error) {
+	return servicesKeyboard(ServicePrefix)
+}
+
+// GetWalkInServicesKeyboard - выбор услуги сотрудником при записи клиента без Telegram.
+func GetWalkInServicesKeyboard() (gotgbot.InlineKeyboardMarkup, error) {
+	return servicesKeyboard(StaffWalkInPrefix)
+}
+
+func servicesKeyboard(prefix string) (gotgbot.InlineKeyboardMarkup, error) {
+	services, err := catalogRepo.GetServices()
+	if err != nil {
+		return gotgbot.InlineKeyboardMarkup{}, fmt.Errorf("error while getting services: %w", err)
+	}
+
+	var kb [][]gotgbot.InlineKeyboardButton
+	for _, s := range services {
+		text := fmt.Sprintf("%s — от %d ₽ (%d мин)", s.Name, s.Price, int(s.Duration.Minutes()))
+		kb = append(kb, []gotgbot.InlineKeyboardButton{{Text: text, CallbackData: prefix + strconv.FormatInt(s.Id, 10)}})
+	}
+
+	return gotgbot.InlineKeyboardMarkup{
+		InlineKeyboard: kb,
+	}, nil
+}
+
+// FormatRecordTime форматирует время записи для показа пользователю и сотрудникам.
+func FormatRecordTime(datetime int64) string {
+	return time.Unix(datetime, 0).In(conf.Shop.Location).Format("02.01.2006 15:04")
+}
+
+func GetAllUserRecordsKeyboard(records []db.Record) gotgbot.InlineKeyboardMarkup {
 	kb := [][]gotgbot.InlineKeyboardButton{{}}
 	for _, record := range records {
-		tm := time.Unix(int64(record), 0).Add(-3 * time.Hour)
-		textTime := tm.Format("02.01.2006 15:04")
-		kb = append(kb, []gotgbot.InlineKeyboardButton{{Text: textTime, CallbackData: IGNORE}})
+		textTime := StatusIcon(record.Status) + " " + FormatRecordTime(record.Datetime)
+		if record.ServiceName != "" {
+			textTime += " — " + record.ServiceName
+		}
+		kb = append(kb, []gotgbot.InlineKeyboardButton{{Text: textTime, CallbackData: RecordPrefix + strconv.FormatInt(record.Id, 10)}})
 	}
 
 	return gotgbot.InlineKeyboardMarkup{
 		InlineKeyboard: kb,
 	}
 }
+
+// GetRecordKeyboard - карточка записи клиента. Перенести и отменить можно только
+// запись, которая ещё не началась.
+func GetRecordKeyboard(record db.Record) gotgbot.InlineKeyboardMarkup {
+	idStr := strconv.FormatInt(record.Id, 10)
+
+	var kb [][]gotgbot.InlineKeyboardButton
+	if db.Changeable(record.Status) {
+		kb = append(kb,
+			[]gotgbot.InlineKeyboardButton{{Text: "Перенести 🔁", CallbackData: MovePrefix + idStr}},
+			[]gotgbot.InlineKeyboardButton{{Text: "Отменить запись ❌", CallbackData: CancelPrefix + idStr}},
+		)
+	}
+	kb = append(kb, []gotgbot.InlineKeyboardButton{{Text: "👈 К списку записей", CallbackData: RecordsList}})
+
+	return gotgbot.InlineKeyboardMarkup{InlineKeyboard: kb}
+}
+
+// GetStaffRecordKeyboard - действия сотрудников с записью id в статусе status.
+// Для завершённых записей кнопок нет.
+func GetStaffRecordKeyboard(id int64, status string) gotgbot.InlineKeyboardMarkup {
+	idStr := strconv.FormatInt(id, 10)
+	call := []gotgbot.InlineKeyboardButton{{Text: "Позвонить клиенту 📞", CallbackData: StaffCallPrefix + idStr}}
+	arrival := []gotgbot.InlineKeyboardButton{
+		{Text: "Клиент приехал 🚗", CallbackData: StaffArrivedPrefix + idStr},
+		{Text: "Не пришёл 🚫", CallbackData: StaffNoShowPrefix + idStr},
+	}
+
+	var kb [][]gotgbot.InlineKeyboardButton
+	switch status {
+	case db.StatusPending:
+		kb = [][]gotgbot.InlineKeyboardButton{
+			{
+				{Text: "Подтвердить ✅", CallbackData: StaffConfirmPrefix + idStr},
+				{Text: "Отклонить ❌", CallbackData: StaffRejectPrefix + idStr},
+			},
+			call,
+			arrival,
+		}
+	case db.StatusConfirmed:
+		kb = [][]gotgbot.InlineKeyboardButton{
+			{{Text: "Отклонить ❌", CallbackData: StaffRejectPrefix + idStr}},
+			call,
+			arrival,
+		}
+	case db.StatusArrived:
+		kb = [][]gotgbot.InlineKeyboardButton{
+			{
+				{Text: "Начать работу 🔧", CallbackData: StaffStartPrefix + idStr},
+				{Text: "Готово 🏁", CallbackData: StaffDonePrefix + idStr},
+			},
+			call,
+		}
+	case db.StatusInProgress:
+		kb = [][]gotgbot.InlineKeyboardButton{
+			{{Text: "Готово 🏁", CallbackData: StaffDonePrefix + idStr}},
+			call,
+		}
+	}
+
+	return gotgbot.InlineKeyboardMarkup{InlineKeyboard: kb}
+}
+
+// GetVehiclesKeyboard - автомобили клиента и кнопка добавления нового.
+func GetVehiclesKeyboard(vehicles []db.Vehicle) gotgbot.InlineKeyboardMarkup {
+	var kb [][]gotgbot.InlineKeyboardButton
+	for _, v := range vehicles {
+		kb = append(kb, []gotgbot.InlineKeyboardButton{{
+			Text:         "🚗 " + VehicleTitle(v),
+			CallbackData: VehiclePrefix + strconv.FormatInt(v.Id, 10),
+		}})
+	}
+	kb = append(kb, []gotgbot.InlineKeyboardButton{{Text: "Добавить автомобиль ➕", CallbackData: VehicleAdd}})
+
+	return gotgbot.InlineKeyboardMarkup{InlineKeyboard: kb}
+}
+
+func GetVehicleKeyboard(id int64) gotgbot.InlineKeyboardMarkup {
+	idStr := strconv.FormatInt(id, 10)
+
+	return gotgbot.InlineKeyboardMarkup{
+		InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+			{
+				{Text: "Изменить ✏️", CallbackData: VehicleEditPrefix + idStr},
+				{Text: "Удалить 🗑", CallbackData: VehicleRemovePrefix + idStr},
+			},
+			{{Text: "👈 К списку автомобилей", CallbackData: VehiclesList}},
+		},
+	}
+}
+
+// GetRecordVehicleKeyboard - выбор автомобиля при записи.
+func GetRecordVehicleKeyboard(vehicles []db.Vehicle) gotgbot.InlineKeyboardMarkup {
+	var kb [][]gotgbot.InlineKeyboardButton
+	for _, v := range vehicles {
+		kb = append(kb, []gotgbot.InlineKeyboardButton{{
+			Text:         "🚗 " + VehicleTitle(v),
+			CallbackData: RecordVehiclePrefix + strconv.FormatInt(v.Id, 10),
+		}})
+	}
+	kb = append(kb, []gotgbot.InlineKeyboardButton{{Text: "Без автомобиля", CallbackData: RecordVehiclePrefix + "0"}})
+
+	return gotgbot.InlineKeyboardMarkup{InlineKeyboard: kb}
+}
+
+func GetRimKeyboard() gotgbot.InlineKeyboardMarkup {
+	var row []gotgbot.InlineKeyboardButton
+	for _, rim := range []string{db.RimStamped, db.RimAlloy, db.RimForged} {
+		row = append(row, gotgbot.InlineKeyboardButton{Text: RimTitle(rim), CallbackData: RimPrefix + rim})
+	}
+
+	return gotgbot.InlineKeyboardMarkup{InlineKeyboard: [][]gotgbot.InlineKeyboardButton{row}}
+}
+
+// GetStoragesKeyboard - шины клиента на хранении.
+func GetStoragesKeyboard(contracts []db.StorageContract) gotgbot.InlineKeyboardMarkup {
+	var kb [][]gotgbot.InlineKeyboardButton
+	for _, c := range contracts {
+		kb = append(kb, []gotgbot.InlineKeyboardButton{{
+			Text:         "🛞 " + c.Description,
+			CallbackData: StoragePrefix + strconv.FormatInt(c.Id, 10),
+		}})
+	}
+
+	return gotgbot.InlineKeyboardMarkup{InlineKeyboard: kb}
+}
+
+func GetStorageKeyboard(id int64) gotgbot.InlineKeyboardMarkup {
+	return gotgbot.InlineKeyboardMarkup{
+		InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+			{{Text: "Заберу к записи 📅", CallbackData: StoragePickupPrefix + strconv.FormatInt(id, 10)}},
+			{{Text: "👈 К списку шин", CallbackData: StoragesList}},
+		},
+	}
+}
+
+// GetStoragePickupKeyboard - выбор записи, к которой клиент заберёт шины по договору id.
+func GetStoragePickupKeyboard(id int64, records []db.Record) gotgbot.InlineKeyboardMarkup {
+	idStr := strconv.FormatInt(id, 10)
+
+	var kb [][]gotgbot.InlineKeyboardButton
+	for _, record := range records {
+		text := FormatRecordTime(record.Datetime)
+		if record.ServiceName != "" {
+			text += " — " + record.ServiceName
+		}
+		kb = append(kb, []gotgbot.InlineKeyboardButton{{
+			Text:         text,
+			CallbackData: StorageLinkPrefix + idStr + ":" + strconv.FormatInt(record.Id, 10),
+		}})
+	}
+	kb = append(kb, []gotgbot.InlineKeyboardButton{{Text: "👈 Назад", CallbackData: StoragePrefix + idStr}})
+
+	return gotgbot.InlineKeyboardMarkup{InlineKeyboard: kb}
+}
+
+// AudienceTitle - название аудитории рассылки для сотрудников.
+func AudienceTitle(audience string) string {
+	switch audience {
+	case db.AudienceAll:
+		return "Все клиенты"
+	case db.AudienceStorage:
+		return "Шины на хранении"
+	case db.AudienceNotBooked:
+		return "Не записывались в этом сезоне"
+	}
+
+	return audience
+}
+
+// GetBroadcastAudienceKeyboard - выбор аудитории рассылки с числом получателей в каждой.
+func GetBroadcastAudienceKeyboard(counts map[string]int) gotgbot.InlineKeyboardMarkup {
+	var kb [][]gotgbot.InlineKeyboardButton
+	for _, audience := range []string{db.AudienceAll, db.AudienceStorage, db.AudienceNotBooked} {
+		kb = append(kb, []gotgbot.InlineKeyboardButton{{
+			Text:         fmt.Sprintf("%s (%d)", AudienceTitle(audience), counts[audience]),
+			CallbackData: BroadcastAudiencePrefix + audience,
+		}})
+	}
+	kb = append(kb, []gotgbot.InlineKeyboardButton{{Text: "Отмена ❌", CallbackData: BroadcastCancel}})
+
+	return gotgbot.InlineKeyboardMarkup{InlineKeyboard: kb}
+}
+
+func GetBroadcastConfirmKeyboard() gotgbot.InlineKeyboardMarkup {
+	return gotgbot.InlineKeyboardMarkup{
+		InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+			{
+				{Text: "Отправить ✅", CallbackData: BroadcastSend},
+				{Text: "Отмена ❌", CallbackData: BroadcastCancel},
+			},
+		},
+	}
+}
+
+// GetWaitlistJoinKeyboard предлагает встать в лист ожидания на любое время или,
+// если задана середина дня mid, только до неё или только после.
+func GetWaitlistJoinKeyboard(mid schedule.Clock) gotgbot.InlineKeyboardMarkup {
+	window := func(from, to schedule.Clock) string {
+		return fmt.Sprintf("%s%d-%d", WaitlistJoinPrefix, int(time.Duration(from).Minutes()), int(time.Duration(to).Minutes()))
+	}
+	day := schedule.Clock(24 * time.Hour)
+
+	kb := [][]gotgbot.InlineKeyboardButton{
+		{{Text: "Сообщить, если освободится 🔔", CallbackData: window(0, day)}},
+	}
+	if mid > 0 {
+		kb = append(kb, []gotgbot.InlineKeyboardButton{
+			{Text: "Только до " + mid.String(), CallbackData: window(0, mid)},
+			{Text: "Только с " + mid.String(), CallbackData: window(mid, day)},
+		})
+	}
+	kb = append(kb, []gotgbot.InlineKeyboardButton{{Text: "Выбрать другую дату 📅", CallbackData: OtherDate}})
+
+	return gotgbot.InlineKeyboardMarkup{InlineKeyboard: kb}
+}
+
+// GetWaitlistKeyboard - кнопка выхода из листа ожидания.
+func GetWaitlistKeyboard(id int64) gotgbot.InlineKeyboardMarkup {
+	return gotgbot.InlineKeyboardMarkup{
+		InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+			{{Text: "Больше не ждать ❌", CallbackData: WaitlistLeavePrefix + strconv.FormatInt(id, 10)}},
+		},
+	}
+}
+
+// GetWaitlistOfferKeyboard - кнопки под предложением освободившегося времени.
+func GetWaitlistOfferKeyboard(id int64) gotgbot.InlineKeyboardMarkup {
+	idStr := strconv.FormatInt(id, 10)
+
+	return gotgbot.InlineKeyboardMarkup{
+		InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+			{
+				{Text: "Записаться ✅", CallbackData: WaitlistClaimPrefix + idStr},
+				{Text: "Не нужно ❌", CallbackData: WaitlistLeavePrefix + idStr},
+			},
+		},
+	}
+}
+
+// GetReminderKeyboard - кнопки под напоминанием о записи id.
+func GetReminderKeyboard(id int64) gotgbot.InlineKeyboardMarkup {
+	idStr := strconv.FormatInt(id, 10)
+
+	return gotgbot.InlineKeyboardMarkup{
+		InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+			{
+				{Text: "Приеду ✅", CallbackData: VisitPrefix + idStr},
+				{Text: "Отменить ❌", CallbackData: CancelPrefix + idStr},
+			},
+		},
+	}
+}
+
+func GetCancelConfirmKeyboard(id int64) gotgbot.InlineKeyboardMarkup {
+	idStr := strconv.FormatInt(id, 10)
+
+	return gotgbot.InlineKeyboardMarkup{
+		InlineKeyboard: [][]gotgbot.InlineKeyboardButton{
+			{
+				{Text: "Да, отменить ✅", CallbackData: CancelConfirmPrefix + idStr},
+				{Text: "Нет ❌", CallbackData: RecordPrefix + idStr},
+			},
+		},
+	}
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"automobile36/internal/db"
+	"testing"
+)
+
+// TestGetRecordKeyboard проверяет, что клиент видит кнопки переноса и отмены
+// ровно у тех записей, которые ещё можно отменить.
+func TestGetRecordKeyboard(t *testing.T) {
+	tests := []struct {
+		status string
+		want   bool
+	}{
+		{db.StatusPending, true},
+		{db.StatusConfirmed, true},
+		{db.StatusArrived, false},
+		{db.StatusInProgress, false},
+		{db.StatusDone, false},
+		{db.StatusNoShow, false},
+		{db.StatusCancelledByShop, false},
+	}
+	for _, tt := range tests {
+		kb := GetRecordKeyboard(db.Record{Id: 7, Status: tt.status})
+
+		buttons := make(map[string]bool)
+		for _, row := range kb.InlineKeyboard {
+			for _, b := range row {
+				buttons[b.CallbackData] = true
+			}
+		}
+		move, cancel := buttons[MovePrefix+"7"], buttons[CancelPrefix+"7"]
+		if move != tt.want || cancel != tt.want {
+			t.Errorf("status %s: move button %v, cancel button %v; want %v", tt.status, move, cancel, tt.want)
+		}
+		if move != db.Changeable(tt.status) {
+			t.Errorf("status %s: buttons shown = %v, but Changeable() = %v", tt.status, move, db.Changeable(tt.status))
+		}
+		if !buttons[RecordsList] {
+			t.Errorf("status %s: no button back to the list", tt.status)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+)
+
+var (
+	ErrInvalidPhone   = errors.New("invalid phone number")
+	ErrForeignContact = errors.New("contact belongs to another user")
+)
+
+// NormalizePhone приводит российский номер к формату E.164 (+7XXXXXXXXXX).
+// Допускаются пробелы, скобки и дефисы, префиксы +7, 7 и 8 или номер без префикса.
+func NormalizePhone(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	plus := strings.HasPrefix(s, "+")
+	if plus {
+		s = s[1:]
+	}
+
+	var digits strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == ' ' || r == '-' || r == '(' || r == ')':
+		default:
+			return "", ErrInvalidPhone
+		}
+	}
+
+	national := digits.String()
+	switch {
+	case len(national) == 11 && national[0] == '7':
+		national = national[1:]
+	case len(national) == 11 && national[0] == '8' && !plus:
+		national = national[1:]
+	case len(national) == 10 && !plus:
+	default:
+		return "", ErrInvalidPhone
+	}
+
+	// Российские номера начинаются с кода 3xx, 4xx, 8xx (городские и бесплатные) или 9xx (мобильные).
+	if !strings.ContainsRune("3489", rune(national[0])) {
+		return "", ErrInvalidPhone
+	}
+
+	return "+7" + national, nil
+}
+
+// PhoneFromMessage достаёт номер из присланного контакта или текста и нормализует его.
+// Контакт принимается, только если это номер самого отправителя.
+func PhoneFromMessage(msg *gotgbot.Message) (string, error) {
+	if msg.Contact == nil {
+		return NormalizePhone(msg.Text)
+	}
+	if msg.From == nil || msg.Contact.UserId != msg.From.Id {
+		return "", ErrForeignContact
+	}
+
+	phone := msg.Contact.PhoneNumber
+	if !strings.HasPrefix(phone, "+") {
+		// Telegram присылает номер без плюса, но всегда с кодом страны.
+		phone = "+" + phone
+	}
+
+	return NormalizePhone(phone)
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  error
+	}{
+		{"89991234567", "+79991234567", nil},
+		{"+79991234567", "+79991234567", nil},
+		{"79991234567", "+79991234567", nil},
+		{"9991234567", "+79991234567", nil},
+		{"8 (999) 123-45-67", "+79991234567", nil},
+		{" +7 999 123 45 67 ", "+79991234567", nil},
+		{"+7(473)2-12-34-56", "+74732123456", nil},
+		{"8-800-555-35-35", "+78005553535", nil},
+		// С плюсом восьмёрка - уже не российский номер.
+		{"+89991234567", "", ErrInvalidPhone},
+		{"+9991234567", "", ErrInvalidPhone},
+		{"", "", ErrInvalidPhone},
+		{"123-45-67", "", ErrInvalidPhone},
+		{"899912345678", "", ErrInvalidPhone},
+		{"89991234", "", ErrInvalidPhone},
+		{"8 999 123 45 6x", "", ErrInvalidPhone},
+		{"+7 999.123.45.67", "", ErrInvalidPhone},
+		{"++79991234567", "", ErrInvalidPhone},
+		// Кодов 1xx, 2xx, 5xx, 6xx и 7xx у российских номеров нет.
+		{"+71234567890", "", ErrInvalidPhone},
+		{"87991234567", "", ErrInvalidPhone},
+	}
+	for _, tt := range tests {
+		got, err := NormalizePhone(tt.in)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("NormalizePhone(%q) = %q, %v; want %q, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"automobile36/internal/db"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// FormatPriceList собирает HTML прайс-лист: услуги сгруппированы по категориям,
+// для услуг с ценами по радиусам выводится таблица R13–R22.
+func FormatPriceList(services []db.Service, prices []db.Price) string {
+	byService := make(map[int64][]db.Price)
+	for _, p := range prices {
+		byService[p.ServiceId] = append(byService[p.ServiceId], p)
+	}
+
+	var categories []string
+	byCategory := make(map[string][]db.Service)
+	for _, s := range services {
+		if _, ok := byCategory[s.Category]; !ok {
+			categories = append(categories, s.Category)
+		}
+		byCategory[s.Category] = append(byCategory[s.Category], s)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<b>Прайс-лист</b>")
+	for _, category := range categories {
+		fmt.Fprintf(&sb, "\n\n<b>%s</b>", html.EscapeString(category))
+		for _, s := range byCategory[category] {
+			radiusPrices := byService[s.Id]
+			if len(radiusPrices) == 0 {
+				fmt.Fprintf(&sb, "\n%s — %d ₽", html.EscapeString(s.Name), s.Price)
+				continue
+			}
+
+			fmt.Fprintf(&sb, "\n%s\n<pre>", html.EscapeString(s.Name))
+			for i, p := range radiusPrices {
+				if i > 0 {
+					sb.WriteString("\n")
+				}
+				fmt.Fprintf(&sb, "R%-3d %6d ₽", p.Radius, p.Price)
+			}
+			sb.WriteString("</pre>")
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,29 @@
+package utils
+
+import "automobile36/internal/db"
+
+var statusNames = map[string]struct{ icon, title string }{
+	db.StatusPending:           {"⏳", "ожидает подтверждения"},
+	db.StatusConfirmed:         {"✅", "подтверждена"},
+	db.StatusCancelledByClient: {"✖️", "отменена вами"},
+	db.StatusCancelledByShop:   {"❌", "отклонена мастерской"},
+	db.StatusArrived:           {"🚗", "вы на месте"},
+	db.StatusInProgress:        {"🔧", "в работе"},
+	db.StatusDone:              {"🏁", "выполнена"},
+	db.StatusNoShow:            {"🚫", "неявка"},
+}
+
+// StatusIcon - значок статуса записи для списков.
+func StatusIcon(status string) string {
+	return statusNames[status].icon
+}
+
+// StatusTitle - статус записи для показа клиенту, например "✅ подтверждена".
+func StatusTitle(status string) string {
+	name, ok := statusNames[status]
+	if !ok {
+		return status
+	}
+
+	return name.icon + " " + name.title
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"automobile36/internal/clock"
+	"automobile36/internal/config"
+	"automobile36/internal/db"
+	"time"
+)
+
+var (
+	conf *config.Config
+	// clk - часы, по которым считаются «сейчас» и «сегодня»; в тестах подменяются.
+	clk clock.Clock = clock.System
+
+	recordRepo    db.RecordRepository
+	closedDayRepo db.ClosedDayRepository
+	catalogRepo   db.CatalogRepository
+	sessionRepo   db.SessionRepository
+)
+
+// Init передаёт клавиатурам и фильтрам загруженную конфигурацию и хранилище.
+func Init(cfg *config.Config, storage db.Storage) {
+	conf = cfg
+	recordRepo = storage
+	closedDayRepo = storage
+	catalogRepo = storage
+	sessionRepo = storage
+}
+
+// Now возвращает текущее время по часам мастерской.
+func Now() time.Time {
+	return clk.Now().In(conf.Shop.Location)
+}
+
+// Today возвращает полночь текущего дня по часам мастерской.
+func Today() time.Time {
+	return Day(Now())
+}
+
+// Day возвращает полночь дня t по часам мастерской.
+func Day(t time.Time) time.Time {
+	t = t.In(conf.Shop.Location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, conf.Shop.Location)
+}
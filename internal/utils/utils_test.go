@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"automobile36/internal/clock"
+	"automobile36/internal/config"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func TestTodayAndDay(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf = &config.Config{Shop: config.Shop{Location: moscow}}
+	t.Cleanup(func() { conf, clk = nil, clock.System })
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{"last minute of the day", time.Date(2026, 10, 18, 23, 59, 59, 0, moscow), time.Date(2026, 10, 18, 0, 0, 0, 0, moscow)},
+		{"local midnight", time.Date(2026, 10, 19, 0, 0, 0, 0, moscow), time.Date(2026, 10, 19, 0, 0, 0, 0, moscow)},
+		// В UTC ещё 18 октября, а в мастерской уже 19-е.
+		{"utc date is behind", time.Date(2026, 10, 18, 22, 30, 0, 0, time.UTC), time.Date(2026, 10, 19, 0, 0, 0, 0, moscow)},
+		{"utc midnight", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 19, 0, 0, 0, 0, moscow)},
+		{"utc last minute", time.Date(2026, 10, 18, 20, 59, 0, 0, time.UTC), time.Date(2026, 10, 18, 0, 0, 0, 0, moscow)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Day(tt.now); !got.Equal(tt.want) || got.Location() != moscow {
+				t.Errorf("Day(%s) = %s, want %s", tt.now, got, tt.want)
+			}
+
+			clk = fixedClock(tt.now)
+			if got := Today(); !got.Equal(tt.want) {
+				t.Errorf("Today() at %s = %s, want %s", tt.now, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"automobile36/internal/db"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var ErrInvalidTireSize = errors.New("invalid tire size")
+
+var rimTitles = map[string]string{
+	db.RimStamped: "штампованные",
+	db.RimAlloy:   "литые",
+	db.RimForged:  "кованые",
+}
+
+// RimTitle - тип дисков для показа, например "литые".
+func RimTitle(rim string) string {
+	if t, ok := rimTitles[rim]; ok {
+		return t
+	}
+
+	return rim
+}
+
+// tireSizeRe - ширина/профиль и посадочный диаметр: "205/55 R16", "205/55R16", "185/75 r16c".
+var tireSizeRe = regexp.MustCompile(`^(\d{3})\s*/\s*(\d{2})\s*Z?R\s*(\d{2}(?:[.,]5)?C?)$`)
+
+// NormalizeTireSize приводит типоразмер шины к виду "205/55 R16".
+func NormalizeTireSize(s string) (string, error) {
+	m := tireSizeRe.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(s)))
+	if m == nil {
+		return "", ErrInvalidTireSize
+	}
+
+	return fmt.Sprintf("%s/%s R%s", m[1], m[2], strings.ReplaceAll(m[3], ",", ".")), nil
+}
+
+// NormalizePlate убирает из госномера пробелы и приводит буквы к верхнему регистру.
+func NormalizePlate(s string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(s), ""))
+}
+
+// VehicleTitle - автомобиль одной строкой: "Kia Rio, А123ВС136, 205/55 R16, литые".
+func VehicleTitle(v db.Vehicle) string {
+	parts := []string{strings.TrimSpace(v.Make + " " + v.Model)}
+	for _, p := range []string{v.Plate, v.TireSize, RimTitle(v.RimType)} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,157 @@
+// Package waitlist предлагает освободившееся время клиентам из листа ожидания
+// в порядке очереди.
+package waitlist
+
+import (
+	"automobile36/internal/clock"
+	"automobile36/internal/config"
+	"automobile36/internal/db"
+	"automobile36/internal/schedule"
+	"automobile36/internal/sender"
+	"automobile36/internal/utils"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+)
+
+// maxMissedOffers - после стольких пропущенных предложений заявка снимается,
+// чтобы не предлагать время клиенту, который уже не ждёт.
+const maxMissedOffers = 3
+
+// Repository - данные, которые нужны листу ожидания.
+type Repository interface {
+	db.WaitlistRepository
+	db.RecordRepository
+	db.ClosedDayRepository
+	db.CatalogRepository
+}
+
+type Scheduler struct {
+	sender sender.Sender
+	repo   Repository
+	clock  clock.Clock
+	cfg    *config.Config
+}
+
+func New(sender sender.Sender, repo Repository, clock clock.Clock, cfg *config.Config) *Scheduler {
+	return &Scheduler{sender: sender, repo: repo, clock: clock, cfg: cfg}
+}
+
+// Run проверяет лист ожидания раз в waitlist.interval и не возвращается.
+func (s *Scheduler) Run() {
+	for ; ; time.Sleep(s.cfg.Waitlist.Interval) {
+		if err := s.Tick(); err != nil {
+			log.Println("failed to process waitlist:", err.Error())
+		}
+	}
+}
+
+// Tick снимает заявки на прошедшие дни и возвращает в конец очереди заявки с
+// истёкшим предложением, затем предлагает свободное время ожидающим заявкам по
+// порядку. Предложенное время придержано до конца срока предложения: его не видят
+// ни другие заявки, ни новые записи.
+func (s *Scheduler) Tick() error {
+	entries, err := s.repo.Waitlist()
+	if err != nil {
+		return err
+	}
+	now := s.clock.Now()
+
+	var (
+		errs    []error
+		waiting []db.WaitlistEntry
+	)
+	for _, e := range entries {
+		switch {
+		case time.Unix(e.Day, 0).In(s.cfg.Shop.Location).AddDate(0, 0, 1).Before(now),
+			e.Status == db.WaitlistOffered && e.OfferExpiresAt <= now.Unix() && e.MissedOffers+1 >= maxMissedOffers:
+			if err := s.repo.SetWaitlistStatus(e.Id, db.WaitlistExpired); err != nil {
+				errs = append(errs, err)
+			}
+		case e.Status == db.WaitlistOffered && e.OfferExpiresAt <= now.Unix():
+			if err := s.repo.RequeueWaitlist(e.Id, now.Unix()); err != nil {
+				errs = append(errs, err)
+			}
+		case e.Status == db.WaitlistWaiting:
+			waiting = append(waiting, e)
+		}
+	}
+
+	for _, e := range waiting {
+		datetime, ok, err := s.freeTime(e, now)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if err := s.offer(e, datetime, now); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// freeTime ищет в окне заявки первое свободное к моменту now время.
+func (s *Scheduler) freeTime(e db.WaitlistEntry, now time.Time) (int64, bool, error) {
+	day := time.Unix(e.Day, 0).In(s.cfg.Shop.Location)
+	closed, err := s.repo.GetClosedDays(e.Day, e.Day+1)
+	if err != nil {
+		return 0, false, err
+	}
+	if len(closed) > 0 {
+		return 0, false, nil
+	}
+
+	times, err := db.GetAllTimes(s.repo, &s.cfg.Schedule, s.cfg.Shop.Bays, day, e.Duration, 0, now)
+	if err != nil {
+		return 0, false, err
+	}
+	for _, t := range times {
+		slot, err := schedule.ParseClock(t)
+		if err != nil {
+			return 0, false, err
+		}
+		if e.Fits(slot) {
+			return db.SlotStart(day, slot).Unix(), true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+func (s *Scheduler) offer(e db.WaitlistEntry, datetime int64, now time.Time) error {
+	expiresAt := now.Add(s.cfg.Waitlist.ClaimTime)
+	err := s.repo.OfferWaitlist(e.Id, datetime, expiresAt.Unix())
+	if errors.Is(err, db.ErrNoWaitlist) {
+		// Клиент успел снять заявку, пока искали время.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	t := fmt.Sprintf("Освободилось время: %s", utils.FormatRecordTime(datetime))
+	if service, err := s.repo.GetService(e.ServiceId); err == nil {
+		t += "\nУслуга: " + service.Name
+	}
+	t += fmt.Sprintf("\nЗаписаться можно до %s, потом время предложим следующему в очереди.",
+		expiresAt.In(s.cfg.Shop.Location).Format("15:04"))
+
+	if _, err := s.sender.SendMessage(e.UserId, t, &gotgbot.SendMessageOpts{
+		ReplyMarkup: utils.GetWaitlistOfferKeyboard(e.Id),
+	}); err != nil {
+		// Клиент недоступен - время достанется следующему.
+		if err := s.repo.SetWaitlistStatus(e.Id, db.WaitlistCancelled); err != nil {
+			return err
+		}
+		return fmt.Errorf("failed to offer waitlist slot to %d: %w", e.UserId, err)
+	}
+
+	return nil
+}
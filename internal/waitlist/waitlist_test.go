@@ -0,0 +1,219 @@
+package waitlist_test
+
+import (
+	"automobile36/internal/config"
+	"automobile36/internal/db"
+	"automobile36/internal/db/dbtest"
+	"automobile36/internal/schedule"
+	"automobile36/internal/utils"
+	"automobile36/internal/waitlist"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+// fakeSender запоминает, кому ушли сообщения.
+type fakeSender struct {
+	sent []int64
+}
+
+func (f *fakeSender) SendMessage(chatId int64, text string, opts *gotgbot.SendMessageOpts) (*gotgbot.Message, error) {
+	f.sent = append(f.sent, chatId)
+	return &gotgbot.Message{}, nil
+}
+
+// day - понедельник с двумя слотами, 09:00 и 10:30, на одном посту.
+var day = time.Date(2026, 10, 19, 0, 0, 0, 0, dbtest.Location)
+
+func newConfig() *config.Config {
+	return &config.Config{
+		Shop: config.Shop{Location: dbtest.Location, Bays: 1},
+		Schedule: schedule.Schedule{
+			SlotMinutes: 90,
+			Days: map[string]schedule.Day{
+				"monday": {Open: schedule.Clock(9 * time.Hour), Close: schedule.Clock(12 * time.Hour)},
+			},
+		},
+		Waitlist: config.Waitlist{ClaimTime: 30 * time.Minute, Interval: time.Minute},
+	}
+}
+
+// setup занимает оба слота дня и ставит в очередь клиентов users по порядку.
+// Возвращает запись на 09:00, чтобы тест мог её отменить.
+func setup(t *testing.T, s *db.Store, now time.Time, users ...int64) (db.Record, *config.Config) {
+	t.Helper()
+	cfg := newConfig()
+	utils.Init(cfg, s)
+
+	var first db.Record
+	for i, at := range []time.Duration{9 * time.Hour, 10*time.Hour + 30*time.Minute} {
+		r, err := s.SaveRecord(100, 0, day.Add(at).Unix(), 0, time.Hour, 1, now.Unix())
+		if err != nil {
+			t.Fatalf("SaveRecord(): %s", err)
+		}
+		if i == 0 {
+			first = r
+		}
+	}
+	for i, userId := range users {
+		if _, err := s.AddWaitlist(db.WaitlistEntry{
+			UserId:   userId,
+			Day:      day.Unix(),
+			To:       schedule.Clock(24 * time.Hour),
+			Duration: time.Hour,
+		}, now.Unix()+int64(i)); err != nil {
+			t.Fatalf("AddWaitlist(): %s", err)
+		}
+	}
+
+	return first, cfg
+}
+
+// tick переводит часы на at, запускает Tick и сверяет, кому ушли предложения за всё время.
+func tick(t *testing.T, sch *waitlist.Scheduler, clock *fakeClock, sender *fakeSender, at time.Time, want ...int64) {
+	t.Helper()
+
+	clock.now = at
+	if err := sch.Tick(); err != nil {
+		t.Fatalf("Tick() at %s: %s", at, err)
+	}
+	if len(sender.sent) != len(want) {
+		t.Fatalf("at %s offered to %v, want %v", at.Format(time.DateTime), sender.sent, want)
+	}
+	for i := range want {
+		if sender.sent[i] != want[i] {
+			t.Fatalf("at %s offered to %v, want %v", at.Format(time.DateTime), sender.sent, want)
+		}
+	}
+}
+
+// TestTickRequeue проверяет, что пропущенное предложение переходит следующему в
+// очереди, а заявка встаёт в конец и снимается после maxMissedOffers пропусков.
+func TestTickRequeue(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, dbtest.Location)
+
+	dbtest.Each(t, func(t *testing.T, s *db.Store) {
+		first, cfg := setup(t, s, now, 1, 2)
+		clock, sender := &fakeClock{}, &fakeSender{}
+		sch := waitlist.New(sender, s, clock, cfg)
+
+		tick(t, sch, clock, sender, now)
+		if err := s.CancelRecord(first.Id, first.UserId); err != nil {
+			t.Fatal(err)
+		}
+
+		claim := cfg.Waitlist.ClaimTime
+		tick(t, sch, clock, sender, now.Add(time.Minute), 1)
+		// Время придержано за первым, второму его не предлагают.
+		tick(t, sch, clock, sender, now.Add(claim), 1)
+		tick(t, sch, clock, sender, now.Add(claim+time.Minute), 1, 2)
+		tick(t, sch, clock, sender, now.Add(2*claim+time.Minute), 1, 2, 1)
+		tick(t, sch, clock, sender, now.Add(3*claim+time.Minute), 1, 2, 1, 2)
+		tick(t, sch, clock, sender, now.Add(4*claim+time.Minute), 1, 2, 1, 2, 1)
+		// Третий пропуск первого снимает его заявку, время уходит второму.
+		tick(t, sch, clock, sender, now.Add(5*claim+time.Minute), 1, 2, 1, 2, 1, 2)
+		tick(t, sch, clock, sender, now.Add(6*claim+time.Minute), 1, 2, 1, 2, 1, 2)
+
+		entries, err := s.Waitlist()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("Waitlist() after three missed offers each = %+v, want empty", entries)
+		}
+	})
+}
+
+func TestTickClaimAfterExpiry(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, dbtest.Location)
+
+	dbtest.Each(t, func(t *testing.T, s *db.Store) {
+		first, cfg := setup(t, s, now, 1)
+		clock, sender := &fakeClock{}, &fakeSender{}
+		sch := waitlist.New(sender, s, clock, cfg)
+		if err := s.CancelRecord(first.Id, first.UserId); err != nil {
+			t.Fatal(err)
+		}
+
+		tick(t, sch, clock, sender, now, 1)
+		entries, err := s.Waitlist()
+		if err != nil || len(entries) != 1 {
+			t.Fatalf("Waitlist() = %+v, %v; want one entry", entries, err)
+		}
+		id := entries[0].Id
+
+		expired := now.Add(cfg.Waitlist.ClaimTime)
+		if _, err := s.ClaimWaitlist(id, 1, expired.Unix(), cfg.Shop.Bays); !errors.Is(err, db.ErrNoWaitlist) {
+			t.Fatalf("ClaimWaitlist() after expiry: err = %v, want ErrNoWaitlist", err)
+		}
+		// Клиент возвращается в очередь, а следующая проверка предлагает ему то же время заново.
+		tick(t, sch, clock, sender, expired, 1)
+		if _, err := s.ClaimWaitlist(id, 1, expired.Unix(), cfg.Shop.Bays); !errors.Is(err, db.ErrNoWaitlist) {
+			t.Fatalf("ClaimWaitlist() of a requeued entry: err = %v, want ErrNoWaitlist", err)
+		}
+		tick(t, sch, clock, sender, expired.Add(time.Minute), 1, 1)
+		if _, err := s.ClaimWaitlist(id, 1, expired.Add(time.Minute).Unix(), cfg.Shop.Bays); err != nil {
+			t.Fatalf("ClaimWaitlist() of the new offer: %s", err)
+		}
+	})
+}
+
+func TestTickPastDay(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, dbtest.Location)
+
+	dbtest.Each(t, func(t *testing.T, s *db.Store) {
+		_, cfg := setup(t, s, now, 1)
+		clock, sender := &fakeClock{}, &fakeSender{}
+		sch := waitlist.New(sender, s, clock, cfg)
+
+		tick(t, sch, clock, sender, day.AddDate(0, 0, 1).Add(time.Minute))
+		entries, err := s.Waitlist()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("Waitlist() after the day passed = %+v, want empty", entries)
+		}
+	})
+}
+
+// cancellingRepo снимает заявки сразу после того, как Tick их прочитал, - как клиент,
+// нажавший «Выйти из листа ожидания», пока искалось время.
+type cancellingRepo struct {
+	*db.Store
+}
+
+func (r cancellingRepo) Waitlist() ([]db.WaitlistEntry, error) {
+	entries, err := r.Store.Waitlist()
+	for _, e := range entries {
+		if _, err := r.CancelWaitlist(e.Id, e.UserId); err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, err
+}
+
+func TestTickCancelledDuringOffer(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, dbtest.Location)
+
+	dbtest.Each(t, func(t *testing.T, s *db.Store) {
+		first, cfg := setup(t, s, now, 1)
+		clock, sender := &fakeClock{}, &fakeSender{}
+		if err := s.CancelRecord(first.Id, first.UserId); err != nil {
+			t.Fatal(err)
+		}
+
+		tick(t, waitlist.New(sender, cancellingRepo{s}, clock, cfg), clock, sender, now)
+	})
+}